@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bibService/pkg/josiah"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type contextKey string
+
+const clientContextKey contextKey = "apiClient"
+
+var authenticator josiah.Authenticator
+var auditLog josiah.AuditLog
+
+// handle registers the handler for the given path and makes sure only
+// clients with the indicated role can call it. Requests to the path are
+// recorded in the metrics.
+func handle(path string, role string, handler http.HandlerFunc) {
+	http.HandleFunc(path, instrument(path, requireRole(role, handler)))
+}
+
+// requireRole wraps a handler so that requests are rejected with a 401
+// when the client cannot be authenticated and with a 403 when the client
+// does not have the required role. Rejected requests are recorded in the
+// audit log (see unauthenticatedAudit for the limit on the 401s recorded),
+// granted ones are not since the handlers that change data record their
+// own operations (see AuditLog.RecordOperation).
+func requireRole(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if role == josiah.RolePublic {
+			handler(resp, req)
+			return
+		}
+
+		client, err := authenticator.Authenticate(req)
+		if err != nil {
+			unauthenticatedAudit(req, err.Error())
+			renderError(resp, unauthorized(err.Error()), "auth")
+			return
+		}
+
+		if !client.HasRole(role) {
+			msg := "Client " + client.Name + " does not have the " + role + " role"
+			authAudit(req, client.Name, "auth.denied", http.StatusForbidden, msg)
+			renderError(resp, forbidden(msg), "auth")
+			return
+		}
+
+		ctx := context.WithValue(req.Context(), clientContextKey, client)
+		handler(resp, req.WithContext(ctx))
+	}
+}
+
+// requestClient returns the name of the client that issued the request.
+func requestClient(req *http.Request) string {
+	client, ok := req.Context().Value(clientContextKey).(josiah.APIClient)
+	if !ok {
+		return ""
+	}
+	return client.Name
+}
+
+func authAudit(req *http.Request, client string, action string, status int, msg string) {
+	entry := josiah.AuditEntry{
+		Client:     client,
+		Action:     action,
+		Route:      req.Method + " " + req.URL.RequestURI(),
+		RemoteAddr: req.RemoteAddr,
+		Status:     status,
+		Message:    msg,
+	}
+	err := auditLog.Record(entry)
+	if err != nil {
+		log.Printf("ERROR writing to audit log: %s", err)
+	}
+}
+
+// Max number of unauthenticated requests (401) recorded in the audit log
+// per minute. Anyone can send these so beyond this limit we only count
+// them (to keep the audit log from being flooded) and record the count
+// once the minute is over.
+const maxDeniedPerMinute = 30
+
+var deniedMutex sync.Mutex
+var deniedWindow time.Time
+var deniedRecorded, deniedSuppressed int
+
+func unauthenticatedAudit(req *http.Request, msg string) {
+	window := time.Now().Truncate(time.Minute)
+	deniedMutex.Lock()
+	suppressed := 0
+	if !window.Equal(deniedWindow) {
+		suppressed = deniedSuppressed
+		deniedWindow, deniedRecorded, deniedSuppressed = window, 0, 0
+	}
+	record := deniedRecorded < maxDeniedPerMinute
+	if record {
+		deniedRecorded++
+	} else {
+		deniedSuppressed++
+	}
+	deniedMutex.Unlock()
+
+	if suppressed > 0 {
+		summary := josiah.AuditEntry{
+			Action:  "auth.denied.suppressed",
+			Status:  http.StatusUnauthorized,
+			Message: fmt.Sprintf("%d unauthenticated requests were not recorded (over %d per minute)", suppressed, maxDeniedPerMinute),
+		}
+		if err := auditLog.Record(summary); err != nil {
+			log.Printf("ERROR writing to audit log: %s", err)
+		}
+	}
+	if record {
+		authAudit(req, "", "auth.denied", http.StatusUnauthorized, msg)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bibService/pkg/josiah"
+	"bibService/pkg/sierra"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// httpError is an error that knows the HTTP status (and error code) that
+// should be reported to the client.
+type httpError struct {
+	Status int
+	Code   string
+	Msg    string
+}
+
+func (e httpError) Error() string {
+	return e.Msg
+}
+
+// badRequest returns an error for requests with missing or invalid
+// parameters.
+func badRequest(msg string) error {
+	return httpError{Status: http.StatusBadRequest, Code: "bad_request", Msg: msg}
+}
+
+// notFound returns an error for resources that do not exist.
+func notFound(msg string) error {
+	return httpError{Status: http.StatusNotFound, Code: "not_found", Msg: msg}
+}
+
+// unauthorized returns an error for requests without valid credentials.
+func unauthorized(msg string) error {
+	return httpError{Status: http.StatusUnauthorized, Code: "unauthorized", Msg: msg}
+}
+
+// forbidden returns an error for requests from clients that are not
+// allowed to perform the operation requested.
+func forbidden(msg string) error {
+	return httpError{Status: http.StatusForbidden, Code: "forbidden", Msg: msg}
+}
+
+// errMustPost is returned by endpoints that change data when they are
+// not called via HTTP POST.
+var errMustPost = httpError{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Msg: "Must use HTTP POST"}
+
+// errorEnvelope is the JSON body returned to clients when there is an error:
+//
+//	{"error":{"code":"not_found","message":"...","requestId":"..."}}
+type errorEnvelope struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	RequestID string      `json:"requestId"`
+	Details   interface{} `json:"details,omitempty"` // e.g. why a hold was rejected
+}
+
+// errorStatus returns the HTTP status and error code for an error.
+//
+// Operations aborted because they would delete too many records and holds
+// rejected (by us or by Sierra) are reported as 409. Sierra "not found"
+// errors are reported as 404 so that clients can tell a missing record
+// from an outage, other errors from Sierra (or errors reaching it, Solr,
+// or the database) are reported as 502 since the problem is upstream, and
+// Sierra rate limiting is reported as 503 since retrying later will work.
+func errorStatus(err error) (int, string) {
+	var httpErr httpError
+	if errors.As(err, &httpErr) {
+		return httpErr.Status, httpErr.Code
+	}
+
+	if errors.Is(err, josiah.ErrInvalidPatronID) {
+		return http.StatusBadRequest, "bad_request"
+	}
+
+	var thresholdErr josiah.ThresholdError
+	if errors.As(err, &thresholdErr) {
+		return http.StatusConflict, "threshold_exceeded"
+	}
+
+	var conflictErr josiah.JobConflictError
+	if errors.As(err, &conflictErr) {
+		return http.StatusConflict, "job_running"
+	}
+
+	var holdErr sierra.HoldError
+	if errors.As(err, &holdErr) {
+		switch holdErr.Reason {
+		case sierra.HoldReasonNotFound:
+			return http.StatusNotFound, "not_found"
+		case sierra.HoldReasonInvalidRequest, sierra.HoldReasonInvalidPickup:
+			return http.StatusBadRequest, "bad_request"
+		}
+		return http.StatusConflict, "hold_rejected"
+	}
+
+	var apiErr sierra.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.IsNotFound() {
+			return http.StatusNotFound, "not_found"
+		}
+		if apiErr.IsRateLimited() {
+			return http.StatusServiceUnavailable, "sierra_rate_limited"
+		}
+		return http.StatusBadGateway, "sierra_error"
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return http.StatusBadGateway, "upstream_unavailable"
+	}
+
+	// Connection failures to Postgres/MySQL surface as *net.OpError (or
+	// the driver's ErrBadConn) rather than *url.Error.
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) {
+		return http.StatusBadGateway, "upstream_unavailable"
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+// logError logs the error and returns the HTTP status and the envelope to
+// report it to the client. The request ID is included in both the log and
+// the envelope so that errors reported by users can be matched against
+// the log.
+func logError(err error, info string) (int, errorEnvelope) {
+	status, code := errorStatus(err)
+	requestID := newRequestID()
+	log.Printf("ERROR (%s) [%s] %d: %s", info, requestID, status, err)
+	envelope := errorEnvelope{Error: errorBody{Code: code, Message: err.Error(), RequestID: requestID}}
+	var holdErr sierra.HoldError
+	if errors.As(err, &holdErr) {
+		envelope.Error.Details = holdErr
+	}
+	return status, envelope
+}
+
+// renderError outputs the error to the client with the appropriate HTTP
+// status in our standard error envelope.
+func renderError(resp http.ResponseWriter, err error, info string) {
+	status, envelope := logError(err, info)
+	requestID := envelope.Error.RequestID
+	json, errJSON := toJSON(envelope, false)
+	if errJSON != nil {
+		json = fmt.Sprintf(`{"error":{"code":"%s","message":"","requestId":"%s"}}`, envelope.Error.Code, requestID)
+	}
+
+	// Notice that headers must be set before calling WriteHeader()
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("X-Request-Id", requestID)
+	resp.WriteHeader(status)
+	fmt.Fprint(resp, json)
+}
+
+func newRequestID() string {
+	bytes := make([]byte, 8)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(bytes)
+}
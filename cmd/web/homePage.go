@@ -32,6 +32,11 @@ func homePage(resp http.ResponseWriter, req *http.Request) {
 		<li> <a href="/bibutils/marc/?bib=b8060910">MARC data for a BIB Record</a>
 	</ul>
 
+	<h2>Solr</h2>
+	<ul>
+		<li> <a href="/bibutils/solr/doc/?bib=b8060910">Solr document for a BIB Record</a>
+	</ul>
+
 	<h2>Pull Slips</h2>
 	<ul>
 		<li> <a href="/bibutils/pullSlips?id=171">Pull Slips (for Sierra List 171)</a>
@@ -43,7 +48,9 @@ func homePage(resp http.ResponseWriter, req *http.Request) {
 		<li> <a href="/collection/import?id=334">Import collection data into Josiah (for Sierra List 334)</a>
 	</ul>
 
-	<p>Troubleshooting: <a href="/status">/status</a></p>
+	<p>Notice that all these endpoints require an API key (X-API-Key header) or a signed request.</p>
+
+	<p>Troubleshooting: <a href="/status">/status</a>, <a href="/status/deep">/status/deep</a>, <a href="/metrics">/metrics</a></p>
 	`
 	if settings.RootURL != "" {
 		html = strings.Replace(html, "/bibutils/", settings.RootURL, -1)
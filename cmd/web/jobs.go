@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bibService/pkg/josiah"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var jobRunner *josiah.JobRunner
+var scheduler *josiah.Scheduler
+
+// initJobs creates the job runner, registers the job types, and starts
+// the scheduler.
+func initJobs() {
+	var err error
+	jobRunner, err = josiah.NewJobRunner(settings)
+	if err != nil {
+		log.Fatal("Failed to load jobs: ", err)
+	}
+	jobRunner.Register("collectionImport", collectionImportJob)
+	jobRunner.Register("marcDownload", marcDownloadJob)
+	jobRunner.Register("solrDelete", solrDeleteJob)
+	jobRunner.Register("solrSync", solrSyncJob)
+	jobRunner.Register("bestBetsUpdate", bestBetsUpdateJob)
+	jobRunner.Register("locationsReload", locationsReloadJob)
+
+	scheduler, err = josiah.NewScheduler(settings, jobRunner)
+	if err != nil {
+		log.Fatal("Invalid schedule: ", err)
+	}
+	scheduler.Start()
+}
+
+// Downloads into Josiah's database the data for one or more collections.
+// Params: listId or listIds (comma delimited)
+func collectionImportJob(jc *josiah.JobContext) error {
+	listIDs := []int{}
+	for _, value := range strings.Split(jc.Params["listId"]+","+jc.Params["listIds"], ",") {
+		listID, _ := strconv.Atoi(strings.TrimSpace(value))
+		if listID != 0 {
+			listIDs = append(listIDs, listID)
+		}
+	}
+	if len(listIDs) == 0 {
+		return badRequest("No listId parameter was received")
+	}
+
+	e := josiah.NewEcosystem(sierraConnString(), josiahConnString())
+	for i, listID := range listIDs {
+		started := time.Now()
+		stats, err := e.DownloadCollection(listID)
+		params := map[string]string{"listId": strconv.Itoa(listID)}
+		auditLog.RecordOperation(jc.Client, "collection.import", params, stats, started, err)
+		if err != nil {
+			return fmt.Errorf("Error importing list %d: %s", listID, err)
+		}
+		jc.SetProgress(i+1, len(listIDs))
+		jc.Logf("Imported %d records for list %d (previously %d)", stats.CountAfter, listID, stats.CountBefore)
+	}
+	return nil
+}
+
+// Deletes from Solr the BIB records deleted/suppressed in Sierra.
+// Params: days or from/to
+func solrDeleteJob(jc *josiah.JobContext) error {
+	from := jc.Params["from"]
+	to := jc.Params["to"]
+	days, _ := strconv.Atoi(jc.Params["days"])
+	if days != 0 {
+		from, to = RangeFromDays(days)
+	}
+	if from == "" || to == "" {
+		return badRequest("No days or from/to parameters were received")
+	}
+	model := josiah.NewBatchBibModel(settings)
+	started := time.Now()
+	stats, err := model.Delete(from, to, false)
+	params := map[string]string{"from": from, "to": to}
+	auditLog.RecordOperation(jc.Client, "solr.delete", params, stats, started, err)
+	if err == nil {
+		jc.Logf("Deleted %d documents from Solr (%s - %s)", stats.CountBefore-stats.CountAfter, from, to)
+	}
+	return err
+}
+
+// Downloads all the BIB records from Sierra as MARC files.
+// Params: toc (true/false)
+func marcDownloadJob(jc *josiah.JobContext) error {
+	toc := jc.Params["toc"] == "true"
+	d := josiah.NewDownloader(settings)
+	d.AddDefaultBatches()
+	total := len(d.Tracker.Batches)
+	for i, batch := range d.Tracker.Batches {
+		err := d.DownloadBatch(batch, toc)
+		if err != nil {
+			return fmt.Errorf("Error downloading batch %s - %s: %s", batch.StartBib, batch.EndBib, err)
+		}
+		jc.SetProgress(i+1, total)
+		if (i+1)%100 == 0 {
+			jc.Logf("Downloaded %d of %d batches (last: %s)", i+1, total, batch.Filename)
+		}
+	}
+	return nil
+}
+
+// Updates Solr with the changes in Sierra since the last successful sync.
+func solrSyncJob(jc *josiah.JobContext) error {
+	model := josiah.NewBatchBibModel(settings)
+	state, err := model.Sync()
+	if err == nil {
+		jc.SetProgress(state.Indexed, state.Indexed)
+		jc.Logf("Synced up to %s, indexed %d", state.LastSync, state.Indexed)
+	}
+	return err
+}
+
+// Refreshes the BestBets in Solr with the data in the Google Sheet.
+func bestBetsUpdateJob(jc *josiah.JobContext) error {
+	stats, err := updateBestBets(jc.Client)
+	if err == nil {
+		jc.Logf("Updated BestBets in Solr (%d documents, previously %d)", stats.CountAfter, stats.CountBefore)
+	}
+	return err
+}
+
+// Returns the status of the scheduled tasks
+func scheduleController(resp http.ResponseWriter, req *http.Request) {
+	renderJSON(resp, scheduler.Status(), nil, "schedule")
+}
+
+// Handles /jobs (list and submit) and /jobs/{id} (status of a job)
+func jobsController(resp http.ResponseWriter, req *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(req.URL.Path, "/jobs"), "/")
+	if id != "" {
+		job, found := jobRunner.Get(id)
+		if !found {
+			renderError(resp, notFound("Job not found: "+id), "jobs")
+			return
+		}
+		renderJSON(resp, job, nil, "jobs")
+		return
+	}
+
+	if req.Method == "POST" {
+		jobType := qsParam("type", req)
+		if jobType == "" {
+			err := badRequest("No type parameter was received (valid types: " + strings.Join(jobRunner.Types(), ", ") + ")")
+			renderError(resp, err, "jobs")
+			return
+		}
+		params := map[string]string{}
+		for key, values := range req.URL.Query() {
+			if key != "type" && len(values) > 0 {
+				params[key] = values[0]
+			}
+		}
+		submitJob(resp, req, jobType, params)
+		return
+	}
+
+	renderJSON(resp, jobRunner.List(), nil, "jobs")
+}
+
+// submitJob starts a job and returns it to the client with an HTTP 202
+// (Accepted) and the URL to check its status.
+func submitJob(resp http.ResponseWriter, req *http.Request, jobType string, params map[string]string) {
+	job, err := jobRunner.Submit(jobType, params, requestClient(req))
+	if err != nil {
+		if _, running := err.(josiah.JobConflictError); !running {
+			err = badRequest(err.Error())
+		}
+		renderError(resp, err, "jobs")
+		return
+	}
+	log.Printf("Submitted job %s (%s) %v", job.ID, job.Type, job.Params)
+
+	json, err := toJSON(job, true)
+	if err != nil {
+		renderError(resp, err, "jobs")
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Location", "/jobs/"+job.ID)
+	resp.WriteHeader(http.StatusAccepted)
+	fmt.Fprint(resp, json)
+}
+
+// Reloads the location/building mappings from the Sierra DB and/or file.
+func locationsReloadJob(jc *josiah.JobContext) error {
+	status, err := josiah.ReloadLocations(settings, sierraConnString())
+	if err == nil {
+		jc.Logf("Loaded %d locations from %s", status.Count, status.Source)
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bibService/pkg/josiah"
+	"bibService/pkg/sierra"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// loadLocations loads the location/building mappings indicated in the
+// settings. If they cannot be loaded we log the error and keep using the
+// built-in mappings.
+func loadLocations(settings josiah.Settings) {
+	status, err := josiah.ReloadLocations(settings, sierraConnStringFor(settings))
+	if err != nil {
+		log.Printf("ERROR loading locations: %s", err)
+		return
+	}
+	log.Printf("Using %s locations (%d)", status.Source, status.Count)
+}
+
+// Handles /admin/locations (status of the locations table),
+// /admin/locations/reload (POST to reload it), and
+// /admin/locations/unmapped (location codes seen that are not mapped,
+// optionally only those seen in the last N hours).
+func locationsController(resp http.ResponseWriter, req *http.Request) {
+	action := strings.Trim(strings.TrimPrefix(req.URL.Path, "/admin/locations"), "/")
+	switch action {
+	case "":
+		renderJSON(resp, josiah.CurrentLocations(), nil, "locations")
+	case "reload":
+		if req.Method != "POST" {
+			renderError(resp, badRequest("Use POST to reload the locations"), "locations")
+			return
+		}
+		started := time.Now()
+		before := josiah.CurrentLocations()
+		status, err := josiah.ReloadLocations(settings, sierraConnString())
+		stats := josiah.OperationStats{CountBefore: before.Count, CountAfter: status.Count}
+		auditLog.RecordOperation(requestClient(req), "locations.reload", nil, stats, started, err)
+		renderJSON(resp, status, err, "locations")
+	case "unmapped":
+		since := time.Time{}
+		if hours := qsParamInt("hours", req); hours > 0 {
+			since = time.Now().Add(-time.Duration(hours) * time.Hour)
+		}
+		renderJSON(resp, sierra.UnmappedLocations(since), nil, "locations")
+	default:
+		renderError(resp, notFound("Unknown action: "+action), "locations")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 )
 
 func main() {
@@ -32,19 +33,24 @@ func main() {
 		return
 	}
 
+	sync := len(os.Args) == 3 && os.Args[2] == "sync"
+	if sync {
+		syncSolr(settingsFile)
+		return
+	}
+
 	StartWebServer(settingsFile)
 }
 
 func deleteBib(settingsFile string) {
-	settings, err := josiah.LoadSettings(settingsFile)
-	if err != nil {
-		log.Fatal(err)
-	}
-	log.Printf("%#v", settings)
+	settings := loadSettings(settingsFile)
 
-	model := josiah.NewBibModel(settings)
+	model := josiah.NewBatchBibModel(settings)
 	from, to := RangeFromDays(10)
-	err = model.Delete(from, to)
+	started := time.Now()
+	stats, err := model.Delete(from, to, false)
+	params := map[string]string{"from": from, "to": to}
+	josiah.NewAuditLog(settings).RecordOperation("cli", "solr.delete", params, stats, started, err)
 	if err != nil {
 		log.Printf("%#v", err)
 		return
@@ -52,17 +58,26 @@ func deleteBib(settingsFile string) {
 	log.Printf("OK")
 }
 
-func downloadMarc(settingsFile string) {
-	settings, err := josiah.LoadSettings(settingsFile)
+func syncSolr(settingsFile string) {
+	settings := loadSettings(settingsFile)
+	loadLocations(settings)
+
+	model := josiah.NewBatchBibModel(settings)
+	state, err := model.Sync()
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("%#v", err)
+		return
 	}
-	log.Printf("%#v", settings)
+	log.Printf("OK (synced up to %s, indexed %d)", state.LastSync, state.Indexed)
+}
+
+func downloadMarc(settingsFile string) {
+	settings := loadSettings(settingsFile)
 
 	d := josiah.NewDownloader(settings)
 	d.AddDefaultBatches()
 	toc := false
-	err = d.DownloadAll(toc)
+	err := d.DownloadAll(toc)
 	if err != nil {
 		log.Printf("%#v", err)
 		return
@@ -71,11 +86,26 @@ func downloadMarc(settingsFile string) {
 }
 
 func smokeTest(settingsFile string) {
+	settings := loadSettings(settingsFile)
+	if len(settings.Validate()) > 0 {
+		os.Exit(1)
+	}
+	log.Printf("Settings OK")
+}
+
+// loadSettings loads the settings (or aborts if they cannot be loaded),
+// logs them (without the secrets), and reports any problems found in them.
+func loadSettings(settingsFile string) josiah.Settings {
+	log.Printf("Loading settings from: %s", settingsFile)
 	settings, err := josiah.LoadSettings(settingsFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Printf("%#v", settings)
+	for _, problem := range settings.Validate() {
+		log.Printf("WARN: Invalid settings: %s", problem)
+	}
+	return settings
 }
 
 func displayHelp(msg string) {
@@ -98,9 +128,12 @@ By default a web server will be loaded to process requests. The [action]
 parameter can be used to perform one-off individual actions rather than
 loading the web server. The valid actions are:
 
-	smoketest - loads the settings file and print its values
+	smoketest - loads the settings file, prints its values (secrets are redacted),
+	            and validates them
 	download - downloads from Sierra all bib records as MARC files (takes 20+ hours)
 	deleteBib - deletes from Solr bib records deleted from Sierra in the last 10 days
+	sync - updates Solr with the bib records updated/deleted/suppressed in Sierra
+	       since the last successful sync (tracked in cachedDataPath)
 	`
 	fmt.Printf("%s%s\r\n", msg, syntax)
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bibService/pkg/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusRecorder captures the status code sent to the client.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+// Flush allows streaming handlers (e.g. renderBibsStream) to keep flushing
+// their output through the recorder.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// instrument wraps a handler so that the number of requests and their
+// duration are recorded for the route. The route is the path the handler
+// was registered with (rather than the URL requested) to keep the number
+// of series small.
+func instrument(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: resp}
+		handler(recorder, req)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		metrics.HTTPRequests.Inc(route, req.Method, strconv.Itoa(recorder.status))
+		metrics.HTTPRequestDuration.ObserveSince(started, route)
+	}
+}
+
+// Returns the metrics in the Prometheus text format.
+func metricsController(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.WriteText(resp)
+}
@@ -4,87 +4,132 @@ import (
 	"bibService/pkg/josiah"
 	"bibService/pkg/sierra"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var settings josiah.Settings
 
 // StartWebServer runs the web server.
 func StartWebServer(settingsFile string) {
-	var err error
+	settings = loadSettings(settingsFile)
 
-	log.Printf("Loading settings from: %s", settingsFile)
-	settings, err = josiah.LoadSettings(settingsFile)
-	if err != nil {
-		log.Fatal(err)
+	authenticator = josiah.NewAuthenticator(settings)
+	auditLog = josiah.NewAuditLog(settings)
+	loadLocations(settings)
+	initJobs()
+	if len(settings.APIClients) == 0 {
+		log.Printf("WARN: No apiClients defined in the settings, only public endpoints will be available")
 	}
-	log.Printf("%#v", settings)
 
 	// Solr
-	http.HandleFunc("/bibutils/solr/delete/", solrDelete)
+	handle("/bibutils/solr/delete/", josiah.RoleAdmin, solrDelete)
+	handle("/bibutils/solr/doc/", josiah.RoleCatalog, solrDoc)
+	handle("/bibutils/solr/index/", josiah.RoleAdmin, solrIndex)
 
 	// Bib and Item level operation
-	http.HandleFunc("/bibutils/bib/updated/", bibUpdated)
-	http.HandleFunc("/bibutils/bib/deleted/", bibDeleted)
-	http.HandleFunc("/bibutils/bib/suppressed/", bibSuppressed)
-	http.HandleFunc("/bibutils/bib/", bibOne)
-	http.HandleFunc("/bibutils/bibs/", bibRange)
-	http.HandleFunc("/bibutils/item/", itemController)
+	handle("/bibutils/bib/updated/", josiah.RoleCatalog, bibUpdated)
+	handle("/bibutils/bib/deleted/", josiah.RoleCatalog, bibDeleted)
+	handle("/bibutils/bib/suppressed/", josiah.RoleCatalog, bibSuppressed)
+	handle("/bibutils/bib/", josiah.RoleCatalog, bibOne)
+	handle("/bibutils/bibs/", josiah.RoleCatalog, bibRange)
+	handle("/bibutils/item/", josiah.RoleCatalog, itemController)
 
 	// Patron operations
-	http.HandleFunc("/bibutils/patron/checkout/", checkoutController)
+	handle("/bibutils/patron/checkout/", josiah.RolePatron, checkoutController)
+	handle("/bibutils/patron/holds/", josiah.RolePatron, holdsController)
+	handle("/bibutils/patron/fines/", josiah.RolePatron, finesController)
+	handle("/bibutils/patron/summary/", josiah.RolePatron, patronSummaryController)
 
 	// MARC operations
-	http.HandleFunc("/bibutils/marc/", marcController)
+	handle("/bibutils/marc/", josiah.RoleCatalog, marcController)
 
 	// Collection Dashboard
-	http.HandleFunc("/collection/details", collectionDetails)
-	http.HandleFunc("/collection/import", collectionImport)
+	handle("/collection/details", josiah.RoleCatalog, collectionDetails)
+	handle("/collection/import", josiah.RoleAdmin, collectionImport)
 
 	// BestBets
-	http.HandleFunc("/bestbets/download", bbDownload)
-	http.HandleFunc("/bestbets/update", bbUpdate)
+	handle("/bestbets/download", josiah.RoleCatalog, bbDownload)
+	handle("/bestbets/update", josiah.RoleAdmin, bbUpdate)
 
 	// Misc
-	http.HandleFunc("/bibutils/pullSlips", pullSlips)
-	http.HandleFunc("/status", status)
-	http.HandleFunc("/", homePage)
+	handle("/bibutils/pullSlips", josiah.RoleCatalog, pullSlips)
+	handle("/admin/audit", josiah.RoleAdmin, adminAudit)
+	handle("/admin/locations", josiah.RoleAdmin, locationsController)
+	handle("/admin/locations/", josiah.RoleAdmin, locationsController)
+	handle("/jobs", josiah.RoleAdmin, jobsController)
+	handle("/jobs/", josiah.RoleAdmin, jobsController)
+	handle("/schedule", josiah.RoleAdmin, scheduleController)
+	handle("/status", josiah.RolePublic, status)
+	handle("/status/deep", josiah.RoleMonitor, statusDeep)
+	handle("/metrics", josiah.RoleMonitor, metricsController)
+	handle("/", josiah.RolePublic, homePage)
 	log.Printf("Listening for requests at: http://%s", settings.ServerAddress)
-	err = http.ListenAndServe(settings.ServerAddress, nil)
+	err := http.ListenAndServe(settings.ServerAddress, nil)
 	if err != nil {
 		log.Fatal("Failed to start the web server: ", err)
 	}
 }
 
+// Returns the entries in the audit log (newest first)
+func adminAudit(resp http.ResponseWriter, req *http.Request) {
+	page := qsParamInt("page", req)
+	if page < 1 {
+		page = 1
+	}
+	pageSize := qsParamInt("pageSize", req)
+	if pageSize < 1 || pageSize > 1000 {
+		pageSize = 100
+	}
+	entries, hasMore, err := auditLog.Entries((page-1)*pageSize, pageSize)
+	data := map[string]interface{}{
+		"page":     page,
+		"pageSize": pageSize,
+		"hasMore":  hasMore,
+		"entries":  entries,
+	}
+	renderJSON(resp, data, err, "adminAudit")
+}
+
 func status(resp http.ResponseWriter, req *http.Request) {
 	fmt.Fprint(resp, "OK")
 }
 
+// Checks that all the backends that we depend on are available. Returns
+// HTTP 503 if any of them is not.
+func statusDeep(resp http.ResponseWriter, req *http.Request) {
+	timeout := 5 * time.Second
+	if seconds := qsParamInt("timeout", req); seconds > 0 && seconds <= 60 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	report := josiah.DeepHealth(settings, sierraConnString(), josiahConnString(), timeout)
+	json, err := toJSON(report, true)
+	if err != nil {
+		renderError(resp, err, "statusDeep")
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	if report.Status != josiah.HealthOK {
+		log.Printf("ERROR (statusDeep): %s", josiah.ToJSON(report))
+		resp.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprint(resp, json)
+}
+
 func pullSlips(resp http.ResponseWriter, req *http.Request) {
 	listID := qsParamInt("id", req)
 	if listID == 0 {
-		err := errors.New("No id parameter was received")
+		err := badRequest("No id parameter was received")
 		renderJSON(resp, nil, err, "pullSlips")
 		return
 	}
 
 	rows, err := sierra.PullSlipsForList(sierraConnString(), listID)
-	if err != nil {
-		log.Printf("ERROR getting data from Sierra: %s", err)
-		resp.WriteHeader(http.StatusInternalServerError)
-		resp.Header().Add("Content-Type", "application/json")
-		fmt.Fprint(resp, "[]")
-		return
-	}
-
-	bytes, err := json.Marshal(rows)
-	json := string(bytes)
-	resp.Header().Add("Content-Type", "application/json")
-	fmt.Fprint(resp, json)
+	renderJSON(resp, rows, err, "pullSlips")
 }
 
 // Downloads the data from the BestBets Google Sheet
@@ -92,7 +137,7 @@ func bbDownload(resp http.ResponseWriter, req *http.Request) {
 	bb := josiah.NewBestBets(settings.BestBetsAPIKey, settings.BestBetsDocID)
 	table, err := bb.Download("A2:E1000")
 	if err != nil {
-		log.Printf("ERROR getting BestBets data: %s", err)
+		renderError(resp, err, "bbDownload")
 		return
 	}
 	log.Printf("Downloaded BestBets data from Google Sheet")
@@ -101,81 +146,70 @@ func bbDownload(resp http.ResponseWriter, req *http.Request) {
 	fmt.Fprint(resp, json)
 }
 
-// Downloads the data from the BestBets Google Sheet
+// Updates the BestBets in Solr with the data from the Google Sheet
 func bbUpdate(resp http.ResponseWriter, req *http.Request) {
+	_, err := updateBestBets(requestClient(req))
+	if err != nil {
+		renderError(resp, err, "bbUpdate")
+		return
+	}
+	json := "{ \"status\": \"OK\" }"
+	resp.Header().Add("Content-Type", "application/json")
+	fmt.Fprint(resp, json)
+}
+
+func updateBestBets(client string) (josiah.OperationStats, error) {
 	bb := josiah.NewBestBets(settings.BestBetsAPIKey, settings.BestBetsDocID)
 	table, err := bb.Download("A2:E1000")
 	if err != nil {
-		log.Printf("ERROR getting BestBets data: %s", err)
-		return
+		return josiah.OperationStats{}, err
 	}
-	deleteAll := len(table.Rows) >= 100
+	deleteAll := len(table.Rows) >= settings.MinBestBetsRows()
 	if !deleteAll {
-		log.Printf("WARN: Skipped delete all because row count is too low (%d)", len(table.Rows))
+		log.Printf("WARN: Skipped delete all because row count is too low (%d, minimum is %d)", len(table.Rows), settings.MinBestBetsRows())
 	}
-	err = bb.UpdateSolr(table, settings.BestBetsSolrURL, deleteAll)
+	started := time.Now()
+	stats, err := bb.UpdateSolr(table, settings.BestBetsSolrURL, deleteAll)
+	params := map[string]string{"rows": strconv.Itoa(len(table.Rows)), "deleteAll": strconv.FormatBool(deleteAll)}
+	auditLog.RecordOperation(client, "bestbets.update", params, stats, started, err)
 	if err != nil {
-		log.Printf("ERROR updating BestBets in Solr: %s", err)
-		return
+		return stats, err
 	}
 	log.Printf("Updated BestBets data in Solr")
-	json := "{ \"status\": \"OK\" }"
-	resp.Header().Add("Content-Type", "application/json")
-	fmt.Fprint(resp, json)
+	return stats, nil
 }
 
 // Downloads into Josiah's database the data for a collection
-// (defined as a Sierra List)
+// (defined as a Sierra List). This can take several minutes for large
+// lists so it runs as a background job, use /jobs/{id} to check on it.
 func collectionImport(resp http.ResponseWriter, req *http.Request) {
 	listID := qsParamInt("id", req)
 	if listID == 0 {
-		err := errors.New("No id parameter was received")
+		err := badRequest("No id parameter was received")
 		renderJSON(resp, nil, err, "collectionImport")
 		return
 	}
-
-	e := josiah.NewEcosystem(sierraConnString(), josiahConnString())
-	err := e.DownloadCollection(listID)
-	if err != nil {
-		log.Printf("ERROR downloading collection %d: %s", listID, err)
-		resp.WriteHeader(http.StatusInternalServerError)
-		resp.Header().Add("Content-Type", "application/json")
-		fmt.Fprint(resp, "[]")
-		return
-	}
-
-	resp.Header().Add("Content-Type", "application/json")
-	fmt.Fprint(resp, "[]")
+	params := map[string]string{"listId": strconv.Itoa(listID)}
+	submitJob(resp, req, "collectionImport", params)
 }
 
 // Returns the data for a collection (defined as a Sierra List)
 func collectionDetails(resp http.ResponseWriter, req *http.Request) {
 	listID := qsParamInt("id", req)
 	if listID == 0 {
-		err := errors.New("No id parameter was received")
+		err := badRequest("No id parameter was received")
 		renderJSON(resp, nil, err, "collectionDetails")
 		return
 	}
 
 	rows, err := sierra.CollectionItemsForList(sierraConnString(), listID)
-	if err != nil {
-		log.Printf("ERROR getting data from Sierra: %s", err)
-		resp.WriteHeader(http.StatusInternalServerError)
-		resp.Header().Add("Content-Type", "application/json")
-		fmt.Fprint(resp, "[]")
-		return
-	}
-
-	bytes, err := json.Marshal(rows)
-	json := string(bytes)
-	resp.Header().Add("Content-Type", "application/json")
-	fmt.Fprint(resp, json)
+	renderJSON(resp, rows, err, "collectionDetails")
 }
 
 func bibOne(resp http.ResponseWriter, req *http.Request) {
 	bib := qsParam("bib", req)
 	if bib == "" {
-		err := errors.New("No bib parameter was received")
+		err := badRequest("No bib parameter was received")
 		renderJSON(resp, nil, err, "bibOne")
 		return
 	}
@@ -195,28 +229,40 @@ func bibRange(resp http.ResponseWriter, req *http.Request) {
 	from := qsParam("from", req)
 	to := qsParam("to", req)
 	if from == "" || to == "" {
-		err := errors.New("No from/to parameters were received")
+		err := badRequest("No from/to parameters were received")
 		renderJSON(resp, nil, err, "bibRange")
 		return
 	}
 	model := josiah.NewBibModel(settings)
 	log.Printf("Fetching BIB data for bibs: %s - %s", from, to)
-	bibs, err := model.GetBibRange(from, to)
-	renderJSON(resp, bibs, err, "bibRange")
+	if qsParam("format", req) == "json" {
+		bibs, err := model.GetBibRange(from, to)
+		renderJSON(resp, bibs, err, "bibRange")
+		return
+	}
+	renderBibsStream(resp, "bibRange", func(fn josiah.BibPageFunc) error {
+		return model.EachBibRange(req.Context(), from, to, fn)
+	})
 }
 
 func bibUpdated(resp http.ResponseWriter, req *http.Request) {
 	from := qsParam("from", req)
 	to := qsParam("to", req)
 	if from == "" || to == "" {
-		err := errors.New("No from/to parameters were received")
+		err := badRequest("No from/to parameters were received")
 		renderJSON(resp, nil, err, "bibUpdated")
 		return
 	}
 	log.Printf("Fetching BIB updated (%s - %s)", from, to)
 	model := josiah.NewBibModel(settings)
-	body, err := model.GetBibsUpdated(from, to, true)
-	renderJSON(resp, body, err, "bibUpdated")
+	if qsParam("format", req) == "json" {
+		body, err := model.GetBibsUpdated(from, to, true)
+		renderJSON(resp, body, err, "bibUpdated")
+		return
+	}
+	renderBibsStream(resp, "bibUpdated", func(fn josiah.BibPageFunc) error {
+		return model.EachBibUpdated(req.Context(), from, to, true, fn)
+	})
 }
 
 func bibDeleted(resp http.ResponseWriter, req *http.Request) {
@@ -227,7 +273,7 @@ func bibDeleted(resp http.ResponseWriter, req *http.Request) {
 		from, to = RangeFromDays(days)
 	}
 	if from == "" || to == "" {
-		err := errors.New("No from/to parameters were received")
+		err := badRequest("No from/to parameters were received")
 		renderJSON(resp, nil, err, "bibDeleted")
 		return
 	}
@@ -245,7 +291,7 @@ func bibSuppressed(resp http.ResponseWriter, req *http.Request) {
 		from, to = RangeFromDays(days)
 	}
 	if from == "" || to == "" {
-		err := errors.New("No from/to parameters were received")
+		err := badRequest("No from/to parameters were received")
 		renderJSON(resp, nil, err, "bibSuppressed")
 		return
 	}
@@ -256,26 +302,80 @@ func bibSuppressed(resp http.ResponseWriter, req *http.Request) {
 }
 
 func solrDelete(resp http.ResponseWriter, req *http.Request) {
-	if req.Method != "POST" {
-		renderJSON(resp, "", errors.New("Must use HTTP POST"), "solrDelete")
-		return
-	}
 	from := qsParam("from", req)
 	to := qsParam("to", req)
 	days, _ := strconv.Atoi(qsParam("days", req))
 	if days != 0 {
 		from, to = RangeFromDays(days)
 	}
-	log.Printf("Deleting from Solr (%s - %s)", from, to)
+	if from == "" || to == "" {
+		err := badRequest("No date range was received (use the from and to parameters, or days)")
+		renderJSON(resp, nil, err, "solrDelete")
+		return
+	}
 	model := josiah.NewBibModel(settings)
-	err := model.Delete(from, to)
-	renderJSON(resp, "OK", err, "solrDelete")
+
+	if qsParam("dryRun", req) == "true" {
+		// Notice that dry runs are allowed via HTTP GET
+		log.Printf("Deleting from Solr (%s - %s) (dry run)", from, to)
+		plan, err := model.DeletePreview(from, to)
+		renderJSON(resp, plan, err, "solrDelete")
+		return
+	}
+
+	if req.Method != "POST" {
+		renderError(resp, errMustPost, "solrDelete")
+		return
+	}
+	force := qsParam("force", req) == "true"
+	log.Printf("Deleting from Solr (%s - %s) (force: %t)", from, to, force)
+	started := time.Now()
+	stats, err := model.Delete(from, to, force)
+	params := map[string]string{"from": from, "to": to, "force": strconv.FormatBool(force)}
+	auditLog.RecordOperation(requestClient(req), "solr.delete", params, stats, started, err)
+	renderJSON(resp, stats, err, "solrDelete")
+}
+
+func solrDoc(resp http.ResponseWriter, req *http.Request) {
+	bib := qsParam("bib", req)
+	if bib == "" {
+		err := badRequest("No bib parameter was received")
+		renderJSON(resp, nil, err, "solrDoc")
+		return
+	}
+	log.Printf("Fetching Solr document for bib: %s", bib)
+	model := josiah.NewBibModel(settings)
+	doc, err := model.SolrDoc(bib)
+	renderJSON(resp, doc, err, "solrDoc")
+}
+
+func solrIndex(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		renderError(resp, errMustPost, "solrIndex")
+		return
+	}
+	bib := qsParam("bib", req)
+	from := qsParam("from", req)
+	to := qsParam("to", req)
+	model := josiah.NewBibModel(settings)
+	var count int
+	var err error
+	if bib != "" {
+		log.Printf("Indexing in Solr bib: %s", bib)
+		count, err = model.Index(bib)
+	} else if from != "" && to != "" {
+		log.Printf("Indexing in Solr bibs: %s - %s", from, to)
+		count, err = model.IndexRange(from, to)
+	} else {
+		err = badRequest("No bib or from/to parameters were received")
+	}
+	renderJSON(resp, map[string]int{"indexed": count}, err, "solrIndex")
 }
 
 func itemController(resp http.ResponseWriter, req *http.Request) {
 	bib := qsParam("bib", req)
 	if bib == "" {
-		err := errors.New("No bib parameter was received")
+		err := badRequest("No bib parameter was received")
 		renderJSON(resp, nil, err, "itemController")
 		return
 	}
@@ -286,7 +386,7 @@ func itemController(resp http.ResponseWriter, req *http.Request) {
 		renderJSON(resp, body, err, "itemController")
 	} else {
 		log.Printf("Fetching item data for bib: %s", bib)
-		items, err := model.Items(bib)
+		items, err := model.Items(bib, qsParamInt("offset", req))
 		renderJSON(resp, items, err, "itemController")
 	}
 }
@@ -294,25 +394,98 @@ func itemController(resp http.ResponseWriter, req *http.Request) {
 func checkoutController(resp http.ResponseWriter, req *http.Request) {
 	patronID := qsParam("patronId", req)
 	if patronID == "" {
-		err := errors.New("No patronId parameter was received")
+		err := badRequest("No patronId parameter was received")
 		renderJSON(resp, nil, err, "checkoutController")
 		return
 	}
 	log.Printf("Fetching checkout information for patronId: %s", patronID)
 	model := josiah.NewPatronModel(settings)
 	checkouts, err := model.CheckedoutBibs(patronID)
-	if err != nil {
-		log.Printf("ERROR (checkoutController): %s", err)
-		fmt.Fprint(resp, "Error fetching patron checkouts")
+	renderJSON(resp, checkouts, err, "checkoutController")
+}
+
+// Handles /bibutils/patron/holds/ (GET to list the holds and POST to place
+// a hold) and /bibutils/patron/holds/{holdId} (POST to change the pickup
+// location and DELETE to cancel the hold).
+func holdsController(resp http.ResponseWriter, req *http.Request) {
+	patronID := qsParam("patronId", req)
+	if patronID == "" {
+		err := badRequest("No patronId parameter was received")
+		renderJSON(resp, nil, err, "holdsController")
 		return
 	}
-	renderJSON(resp, checkouts, err, "checkoutController")
+	holdID := strings.Trim(strings.TrimPrefix(req.URL.Path, "/bibutils/patron/holds"), "/")
+	model := josiah.NewPatronModel(settings)
+
+	switch {
+	case req.Method == "GET" && holdID == "":
+		log.Printf("Fetching holds information for patronId: %s", patronID)
+		holds, err := model.Holds(patronID)
+		renderJSON(resp, holds, err, "holdsController")
+	case req.Method == "POST" && holdID == "":
+		placement := josiah.HoldPlacement{
+			BibID:          qsParam("bib", req),
+			ItemID:         qsParam("item", req),
+			PickupLocation: qsParam("pickupLocation", req),
+			NeededBy:       qsParam("neededBy", req),
+		}
+		log.Printf("Placing hold for patronId: %s (%+v)", patronID, placement)
+		started := time.Now()
+		err := model.PlaceHold(patronID, placement)
+		params := map[string]string{"patronId": patronID, "bib": placement.BibID, "item": placement.ItemID, "pickupLocation": placement.PickupLocation}
+		auditLog.RecordOperation(requestClient(req), "hold.place", params, josiah.OperationStats{}, started, err)
+		renderJSON(resp, map[string]string{"status": "placed"}, err, "holdsController")
+	case req.Method == "POST":
+		pickupLocation := qsParam("pickupLocation", req)
+		log.Printf("Changing pickup location for hold %s (patronId: %s) to %s", holdID, patronID, pickupLocation)
+		started := time.Now()
+		err := model.ChangePickupLocation(patronID, holdID, pickupLocation)
+		params := map[string]string{"patronId": patronID, "holdId": holdID, "pickupLocation": pickupLocation}
+		auditLog.RecordOperation(requestClient(req), "hold.update", params, josiah.OperationStats{}, started, err)
+		renderJSON(resp, map[string]string{"status": "updated"}, err, "holdsController")
+	case req.Method == "DELETE" && holdID != "":
+		log.Printf("Cancelling hold %s (patronId: %s)", holdID, patronID)
+		started := time.Now()
+		err := model.CancelHold(patronID, holdID)
+		params := map[string]string{"patronId": patronID, "holdId": holdID}
+		auditLog.RecordOperation(requestClient(req), "hold.cancel", params, josiah.OperationStats{}, started, err)
+		renderJSON(resp, map[string]string{"status": "cancelled"}, err, "holdsController")
+	default:
+		err := httpError{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Msg: "Method not allowed: " + req.Method}
+		renderError(resp, err, "holdsController")
+	}
+}
+
+func finesController(resp http.ResponseWriter, req *http.Request) {
+	patronID := qsParam("patronId", req)
+	if patronID == "" {
+		err := badRequest("No patronId parameter was received")
+		renderJSON(resp, nil, err, "finesController")
+		return
+	}
+	log.Printf("Fetching fines information for patronId: %s", patronID)
+	model := josiah.NewPatronModel(settings)
+	fines, err := model.Fines(patronID)
+	renderJSON(resp, fines, err, "finesController")
+}
+
+func patronSummaryController(resp http.ResponseWriter, req *http.Request) {
+	patronID := qsParam("patronId", req)
+	if patronID == "" {
+		err := badRequest("No patronId parameter was received")
+		renderJSON(resp, nil, err, "patronSummaryController")
+		return
+	}
+	log.Printf("Fetching account summary for patronId: %s", patronID)
+	model := josiah.NewPatronModel(settings)
+	summary, err := model.Summary(patronID)
+	renderJSON(resp, summary, err, "patronSummaryController")
 }
 
 func marcController(resp http.ResponseWriter, req *http.Request) {
 	bib := qsParam("bib", req)
 	if bib == "" {
-		err := errors.New("No bib parameter was received")
+		err := badRequest("No bib parameter was received")
 		renderJSON(resp, nil, err, "marcController")
 		return
 	}
@@ -320,8 +493,7 @@ func marcController(resp http.ResponseWriter, req *http.Request) {
 	model := josiah.NewBibModel(settings)
 	marcData, err := model.Marc(bib)
 	if err != nil {
-		log.Printf("ERROR (marcController): %s", err)
-		fmt.Fprint(resp, "Error fetching MARC data")
+		renderError(resp, err, "marcController")
 		return
 	}
 	fmt.Fprint(resp, marcData)
@@ -329,9 +501,7 @@ func marcController(resp http.ResponseWriter, req *http.Request) {
 
 func renderJSON(resp http.ResponseWriter, data interface{}, errFetch error, info string) {
 	if errFetch != nil {
-		log.Printf("ERROR (%s): %s", info, errFetch)
-		// Tweak this, sometimes we want to provide more information to the client.
-		fmt.Fprint(resp, "Error retrieving information")
+		renderError(resp, errFetch, info)
 		return
 	}
 
@@ -344,15 +514,58 @@ func renderJSON(resp http.ResponseWriter, data interface{}, errFetch error, info
 	// Convert the object to a string with the JSON representation
 	json, err := toJSON(data, true)
 	if err != nil {
-		log.Printf("ERROR (%s): %s", info, err)
-		fmt.Fprint(resp, "Error converting response to JSON")
+		renderError(resp, err, info)
 		return
 	}
 	resp.Header().Add("Content-Type", "application/json")
 	fmt.Fprint(resp, json)
 }
 
+// renderBibsStream outputs the BIB records as newline-delimited JSON (one
+// record per line) as they are fetched from Sierra so that clients can
+// process them as they arrive. Errors found before we start streaming are
+// reported with the proper HTTP status, but errors found afterwards (when
+// the HTTP status has already been sent) are reported as a final JSON line.
+func renderBibsStream(resp http.ResponseWriter, info string, iterate func(josiah.BibPageFunc) error) {
+	started := false
+	encoder := json.NewEncoder(resp)
+	flusher, canFlush := resp.(http.Flusher)
+	err := iterate(func(bibs []sierra.Bib) error {
+		if !started {
+			resp.Header().Add("Content-Type", "application/x-ndjson")
+			started = true
+		}
+		for _, bib := range bibs {
+			err := encoder.Encode(bib)
+			if err != nil {
+				return err
+			}
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		// No records found
+		resp.Header().Add("Content-Type", "application/x-ndjson")
+		return
+	}
+	if err != nil && !started {
+		renderError(resp, err, info)
+		return
+	}
+	if err != nil {
+		_, envelope := logError(err, info)
+		encoder.Encode(envelope)
+	}
+}
+
 func sierraConnString() string {
+	return sierraConnStringFor(settings)
+}
+
+func sierraConnStringFor(settings josiah.Settings) string {
 	timeout := 300 // seconds
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=require connect_timeout=%d",
 		settings.DbHost, settings.DbPort, settings.DbUser, settings.DbPassword, settings.DbName, timeout)
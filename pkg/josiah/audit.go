@@ -0,0 +1,234 @@
+package josiah
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Name of the file (under CachedDataPath) where we keep the audit log when
+// no auditLogFile is indicated in the settings.
+const auditLogFile = "audit_log.jsonl"
+
+// The audit log is shared by all requests.
+var auditMutex sync.Mutex
+
+// Maximum size of a line in the audit log. Entries for operations that
+// delete many records can be large because they include the IDs deleted.
+const auditMaxLineSize = 64 * 1024 * 1024
+
+// Size (in MB) at which the audit log is rotated by default.
+const defaultAuditLogMaxSize = 100
+
+// AuditEntry represents one entry in the audit log.
+type AuditEntry struct {
+	Time       time.Time         `json:"time"`
+	Client     string            `json:"client"` // who triggered the operation
+	Action     string            `json:"action"`
+	Route      string            `json:"route,omitempty"`
+	RemoteAddr string            `json:"remoteAddr,omitempty"`
+	Status     int               `json:"status,omitempty"` // HTTP status
+	Message    string            `json:"message,omitempty"`
+	Params     map[string]string `json:"params,omitempty"` // e.g. date range, list ID
+	Stats      *OperationStats   `json:"stats,omitempty"`
+	DurationMs int64             `json:"durationMs,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// OperationStats describes the changes made by an operation that removes
+// data (e.g. deleting documents from Solr)
+type OperationStats struct {
+	DeletedIDs  []string `json:"deletedIds,omitempty"`
+	CountBefore int      `json:"countBefore"`
+	CountAfter  int      `json:"countAfter"`
+}
+
+// AuditLog is an append-only log (one JSON entry per line) of security
+// relevant events and of the operations that remove data from Solr or
+// from the Josiah database. When the file reaches maxSize it is renamed
+// (e.g. audit_log.jsonl.20201001-093000) and a new file is started, the
+// rotated files are never deleted.
+type AuditLog struct {
+	filename string
+	maxSize  int64
+}
+
+// NewAuditLog returns the audit log indicated in the settings.
+func NewAuditLog(settings Settings) AuditLog {
+	filename := settings.AuditLogFile
+	if filename == "" && settings.CachedDataPath != "" {
+		filename = filepath.Join(settings.CachedDataPath, auditLogFile)
+	}
+	return AuditLog{filename: filename, maxSize: settings.AuditLogMaxBytes()}
+}
+
+// Record appends an entry to the audit log. A summary of the entry is also
+// written to the standard log so that there is a trace even if the audit
+// log file is not available.
+func (a AuditLog) Record(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	log.Printf("AUDIT %s client=%q route=%q status=%d %s", entry.Action, entry.Client, entry.Route, entry.Status, entry.Error)
+	if a.filename == "" {
+		return nil
+	}
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	err = a.rotate()
+	if err != nil {
+		// not fatal, we keep appending to the current file
+		log.Printf("ERROR rotating audit log: %s", err)
+	}
+	file, err := os.OpenFile(a.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(bytes, '\n'))
+	return err
+}
+
+// RecordOperation records in the audit log an operation that removes data
+// along with who triggered it, its parameters, and its outcome.
+func (a AuditLog) RecordOperation(client string, action string, params map[string]string, stats OperationStats, started time.Time, opErr error) {
+	entry := AuditEntry{
+		Client:     client,
+		Action:     action,
+		Params:     params,
+		Stats:      &stats,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+	err := a.Record(entry)
+	if err != nil {
+		log.Printf("ERROR writing to audit log: %s", err)
+	}
+}
+
+// rotate renames the audit log file if it has reached the max size. The
+// caller must hold auditMutex.
+func (a AuditLog) rotate() error {
+	if a.maxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(a.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil || info.Size() < a.maxSize {
+		return err
+	}
+	rotated := a.filename + "." + time.Now().Format("20060102-150405")
+	log.Printf("Rotating audit log to %s", rotated)
+	return os.Rename(a.filename, rotated)
+}
+
+// Entries returns the entries in the audit log, newest first, skipping
+// the first `offset` entries. Also returns whether there are more entries
+// after these. Only the current file is read (not the rotated ones) and
+// the entries are read from the end of the file so that we don't have to
+// load all of them to return the most recent ones.
+//
+// auditMutex is only held while opening the file, entries appended while
+// we read it are not returned (and don't block the read).
+func (a AuditLog) Entries(offset, limit int) ([]AuditEntry, bool, error) {
+	entries := []AuditEntry{}
+	if a.filename == "" {
+		return entries, false, nil
+	}
+
+	auditMutex.Lock()
+	file, err := os.Open(a.filename)
+	var info os.FileInfo
+	if err == nil {
+		info, err = file.Stat()
+	}
+	auditMutex.Unlock()
+	if os.IsNotExist(err) {
+		return entries, false, nil
+	}
+	if file != nil {
+		defer file.Close()
+	}
+	if err != nil {
+		return entries, false, err
+	}
+
+	lines, err := lastLines(file, info.Size(), offset+limit+1)
+	if err != nil {
+		return entries, false, err
+	}
+	hasMore := len(lines) > offset+limit
+	if hasMore {
+		lines = lines[:offset+limit]
+	}
+
+	for i := offset; i < len(lines); i++ {
+		var entry AuditEntry
+		err := json.Unmarshal(lines[i], &entry)
+		if err != nil {
+			log.Printf("Skipped invalid audit log entry (%d from the end): %s", i+1, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, hasMore, nil
+}
+
+// lastLines returns up to n lines from the end of the file, the last line
+// first. The file is read backwards in chunks.
+func lastLines(file *os.File, size int64, n int) ([][]byte, error) {
+	lines := [][]byte{}
+	pending := []byte{} // the (incomplete) first line of what we have read
+	pos := size
+	for len(lines) < n {
+		i := bytes.LastIndexByte(pending, '\n')
+		if i != -1 {
+			if i < len(pending)-1 || pos+int64(len(pending)) < size {
+				lines = append(lines, pending[i+1:])
+			}
+			pending = pending[:i]
+			continue
+		}
+		if pos == 0 {
+			if len(pending) > 0 {
+				lines = append(lines, pending)
+			}
+			break
+		}
+		if len(pending) > auditMaxLineSize {
+			return nil, fmt.Errorf("Audit log entry is longer than %d bytes", auditMaxLineSize)
+		}
+
+		// read the previous chunk, doubling the size while we don't find a
+		// line break so that long lines are read in a few reads
+		chunkSize := int64(64 * 1024)
+		if int64(len(pending)) > chunkSize {
+			chunkSize = int64(len(pending))
+		}
+		if chunkSize > pos {
+			chunkSize = pos
+		}
+		pos -= chunkSize
+		chunk := make([]byte, chunkSize, chunkSize+int64(len(pending)))
+		_, err := file.ReadAt(chunk, pos)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(chunk, pending...)
+	}
+	return lines, nil
+}
@@ -0,0 +1,100 @@
+package josiah
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLogEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	audit := NewAuditLog(Settings{CachedDataPath: dir})
+
+	entries, hasMore, err := audit.Entries(0, 10)
+	if err != nil || hasMore || len(entries) != 0 {
+		t.Errorf("Unexpected entries in empty log: %#v %v %v", entries, hasMore, err)
+	}
+
+	started := time.Now()
+	stats := OperationStats{DeletedIDs: []string{"b1", "b2"}, CountBefore: 10, CountAfter: 8}
+	audit.RecordOperation("cron", "solr.delete", map[string]string{"from": "2020-01-01"}, stats, started, nil)
+	audit.RecordOperation("cron", "collection.import", map[string]string{"listId": "334"}, OperationStats{}, started, errors.New("boom"))
+	audit.Record(AuditEntry{Client: "josiah", Action: "auth.denied", Status: 403})
+
+	entries, hasMore, err = audit.Entries(0, 2)
+	if err != nil || !hasMore || len(entries) != 2 {
+		t.Fatalf("Unexpected entries: %#v %v %v", entries, hasMore, err)
+	}
+	if entries[0].Action != "auth.denied" || entries[1].Error != "boom" {
+		t.Errorf("Entries not returned newest first: %#v", entries)
+	}
+
+	entries, hasMore, _ = audit.Entries(2, 2)
+	if len(entries) != 1 || hasMore || entries[0].Params["from"] != "2020-01-01" ||
+		entries[0].Stats == nil || len(entries[0].Stats.DeletedIDs) != 2 || entries[0].Stats.CountAfter != 8 {
+		t.Errorf("Unexpected last page: %#v", entries)
+	}
+}
+
+func TestAuditLogLongEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	audit := NewAuditLog(Settings{CachedDataPath: dir})
+
+	// entries larger than the chunks read from the end of the file
+	for i := 0; i < 5; i++ {
+		ids := []string{}
+		for j := 0; j < 20000*i; j++ {
+			ids = append(ids, fmt.Sprintf("b%d", j))
+		}
+		audit.RecordOperation("cron", fmt.Sprintf("op%d", i), nil, OperationStats{DeletedIDs: ids}, time.Now(), nil)
+	}
+	file, _ := os.OpenFile(filepath.Join(dir, auditLogFile), os.O_APPEND|os.O_WRONLY, 0640)
+	file.WriteString("not json\n")
+	file.Close()
+	audit.Record(AuditEntry{Action: "last"})
+
+	entries, hasMore, err := audit.Entries(0, 3)
+	if err != nil || !hasMore || len(entries) != 2 || entries[0].Action != "last" || entries[1].Action != "op4" {
+		t.Fatalf("Unexpected entries: %d %v %v", len(entries), hasMore, err)
+	}
+	if len(entries[1].Stats.DeletedIDs) != 80000 {
+		t.Errorf("Long entry not read completely: %d", len(entries[1].Stats.DeletedIDs))
+	}
+	entries, hasMore, _ = audit.Entries(3, 10)
+	if len(entries) != 4 || hasMore || entries[0].Action != "op3" || entries[3].Action != "op0" {
+		t.Errorf("Unexpected last page: %d", len(entries))
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	audit := NewAuditLog(Settings{CachedDataPath: dir})
+	audit.maxSize = 100
+
+	audit.Record(AuditEntry{Action: "first", Message: "an entry that makes the file reach the max size for the log"})
+	audit.Record(AuditEntry{Action: "second"})
+	entries, hasMore, err := audit.Entries(0, 10)
+	if err != nil || hasMore || len(entries) != 1 || entries[0].Action != "second" {
+		t.Errorf("Audit log was not rotated: %#v %v %v", entries, hasMore, err)
+	}
+	rotated, _ := filepath.Glob(filepath.Join(dir, auditLogFile+".*"))
+	if len(rotated) != 1 {
+		t.Errorf("Rotated file not found: %v", rotated)
+	}
+}
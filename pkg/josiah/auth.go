@@ -0,0 +1,177 @@
+package josiah
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Roles that routes can require.
+const (
+	RolePublic  = "public"  // no credentials required
+	RoleCatalog = "catalog" // read-only access to catalog data
+	RolePatron  = "patron"  // access to patron data
+	RoleAdmin   = "admin"   // operations that change data (Solr, Josiah DB)
+	RoleMonitor = "monitor" // read-only access to metrics and health checks
+)
+
+// Maximum difference allowed between the timestamp of a signed request
+// and our clock.
+const maxSignatureSkew = 5 * time.Minute
+
+// Errors returned when a request cannot be authenticated.
+var (
+	ErrNoCredentials      = errors.New("No credentials were received")
+	ErrInvalidCredentials = errors.New("Invalid credentials")
+	ErrExpiredSignature   = errors.New("Request signature has expired")
+	ErrReplayedSignature  = errors.New("Request signature has already been used")
+)
+
+// APIClient represents a client (e.g. Josiah's front end, a cron job)
+// allowed to call the service. A client can authenticate by passing its
+// API key in the X-API-Key header or by signing the request with its
+// secret (see SignRequest).
+type APIClient struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Secret string   `json:"secret"`
+	Roles  []string `json:"roles"`
+}
+
+// HasRole returns true if the client has been granted the given role.
+// Admin clients are also allowed to do what monitor clients do.
+func (c APIClient) HasRole(role string) bool {
+	if role == RoleMonitor && in(c.Roles, RoleAdmin) {
+		return true
+	}
+	return role == RolePublic || in(c.Roles, role)
+}
+
+// Authenticator identifies the client that issued a request.
+type Authenticator interface {
+	Authenticate(req *http.Request) (APIClient, error)
+}
+
+// ClientAuthenticator authenticates requests against a list of known
+// clients. Requests can be authenticated via an API key:
+//
+//	X-API-Key: the-key
+//
+// or via an HMAC signature (see SignRequest):
+//
+//	X-Client-Id: client-name
+//	X-Timestamp: 1600000000
+//	X-Signature: hex-encoded-signature
+//
+// A signature is only accepted once so that a signed request cannot be
+// replayed while its timestamp is still valid.
+type ClientAuthenticator struct {
+	clients []APIClient
+	now     func() time.Time
+	used    *usedSignatures
+}
+
+// NewAuthenticator returns the authenticator for the clients configured
+// in the settings.
+func NewAuthenticator(settings Settings) ClientAuthenticator {
+	return ClientAuthenticator{clients: settings.APIClients, now: time.Now, used: newUsedSignatures()}
+}
+
+func (a ClientAuthenticator) Authenticate(req *http.Request) (APIClient, error) {
+	key := req.Header.Get("X-API-Key")
+	if key != "" {
+		return a.authenticateKey(key)
+	}
+	if req.Header.Get("X-Signature") != "" {
+		return a.authenticateSignature(req)
+	}
+	return APIClient{}, ErrNoCredentials
+}
+
+func (a ClientAuthenticator) authenticateKey(key string) (APIClient, error) {
+	for _, client := range a.clients {
+		if client.Key != "" && subtle.ConstantTimeCompare([]byte(client.Key), []byte(key)) == 1 {
+			return client, nil
+		}
+	}
+	return APIClient{}, ErrInvalidCredentials
+}
+
+func (a ClientAuthenticator) authenticateSignature(req *http.Request) (APIClient, error) {
+	name := req.Header.Get("X-Client-Id")
+	timestamp := req.Header.Get("X-Timestamp")
+	signature := req.Header.Get("X-Signature")
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return APIClient{}, ErrInvalidCredentials
+	}
+	skew := a.now().Sub(time.Unix(seconds, 0))
+	if skew > maxSignatureSkew || skew < -maxSignatureSkew {
+		return APIClient{}, ErrExpiredSignature
+	}
+
+	for _, client := range a.clients {
+		if client.Name != name || client.Secret == "" {
+			continue
+		}
+		expected := SignRequest(client.Secret, req.Method, req.URL.RequestURI(), timestamp)
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			expires := time.Unix(seconds, 0).Add(maxSignatureSkew)
+			if !a.used.use(client.Name+" "+signature, a.now(), expires) {
+				return APIClient{}, ErrReplayedSignature
+			}
+			return client, nil
+		}
+	}
+	return APIClient{}, ErrInvalidCredentials
+}
+
+// SignRequest returns the signature for a request: the hex-encoded
+// HMAC-SHA256 (using the client's secret) of the HTTP method, the request
+// URI (path and query string), and the Unix timestamp separated by
+// newlines. For example:
+//
+//	POST\n/bibutils/solr/delete/?days=3\n1600000000
+func SignRequest(secret, method, requestURI, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// usedSignatures keeps the signatures accepted until they expire.
+type usedSignatures struct {
+	mutex     sync.Mutex
+	expires   map[string]time.Time
+	lastSweep time.Time
+}
+
+func newUsedSignatures() *usedSignatures {
+	return &usedSignatures{expires: map[string]time.Time{}}
+}
+
+// use records that the signature has been used and returns false if it
+// had already been used. Expired signatures are dropped once a minute.
+func (u *usedSignatures) use(signature string, now time.Time, expires time.Time) bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if now.Sub(u.lastSweep) > time.Minute {
+		for key, value := range u.expires {
+			if now.After(value) {
+				delete(u.expires, key)
+			}
+		}
+		u.lastSweep = now
+	}
+	if _, used := u.expires[signature]; used {
+		return false
+	}
+	u.expires[signature] = expires
+	return true
+}
@@ -0,0 +1,100 @@
+package josiah
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testAuthenticator() ClientAuthenticator {
+	clients := []APIClient{
+		{Name: "josiah", Key: "key1", Roles: []string{RoleCatalog}},
+		{Name: "cron", Secret: "secret1", Roles: []string{RoleCatalog, RoleAdmin}},
+	}
+	return NewAuthenticator(Settings{APIClients: clients})
+}
+
+func TestAuthenticateKey(t *testing.T) {
+	auth := testAuthenticator()
+
+	req := httptest.NewRequest("GET", "/bibutils/bib/?bib=b1", nil)
+	_, err := auth.Authenticate(req)
+	if err != ErrNoCredentials {
+		t.Errorf("Expected no credentials error, got: %v", err)
+	}
+
+	req.Header.Set("X-API-Key", "key1")
+	client, err := auth.Authenticate(req)
+	if err != nil || client.Name != "josiah" {
+		t.Errorf("Expected josiah client, got: %#v %v", client, err)
+	}
+	if !client.HasRole(RoleCatalog) || client.HasRole(RoleAdmin) || !client.HasRole(RolePublic) {
+		t.Errorf("Unexpected roles: %#v", client.Roles)
+	}
+
+	req.Header.Set("X-API-Key", "bad-key")
+	_, err = auth.Authenticate(req)
+	if err != ErrInvalidCredentials {
+		t.Errorf("Expected invalid credentials error, got: %v", err)
+	}
+}
+
+func TestMonitorRole(t *testing.T) {
+	monitor := APIClient{Name: "prometheus", Roles: []string{RoleMonitor}}
+	if !monitor.HasRole(RoleMonitor) || monitor.HasRole(RoleAdmin) || monitor.HasRole(RoleCatalog) {
+		t.Errorf("Unexpected roles for monitor client: %#v", monitor.Roles)
+	}
+
+	admin := APIClient{Name: "cron", Roles: []string{RoleAdmin}}
+	if !admin.HasRole(RoleMonitor) {
+		t.Errorf("Admin clients should be able to monitor")
+	}
+}
+
+func TestAuthenticateSignature(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	auth := testAuthenticator()
+	auth.now = func() time.Time { return now }
+
+	uri := "/bibutils/solr/delete/?days=3"
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req := httptest.NewRequest("POST", uri, nil)
+	req.Header.Set("X-Client-Id", "cron")
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Signature", SignRequest("secret1", "POST", uri, timestamp))
+	client, err := auth.Authenticate(req)
+	if err != nil || client.Name != "cron" || !client.HasRole(RoleAdmin) {
+		t.Errorf("Expected cron client, got: %#v %v", client, err)
+	}
+
+	// The same signature cannot be used again
+	_, err = auth.Authenticate(req)
+	if err != ErrReplayedSignature {
+		t.Errorf("Expected replayed signature error, got: %v", err)
+	}
+
+	// Signatures are forgotten once they expire
+	now = now.Add(maxSignatureSkew + 2*time.Minute)
+	auth.used.use("cron other", now, now.Add(time.Minute))
+	if len(auth.used.expires) != 1 {
+		t.Errorf("Expired signatures were not dropped: %d", len(auth.used.expires))
+	}
+	now = time.Unix(1600000000, 0)
+
+	// Signature for a different URI
+	req.Header.Set("X-Signature", SignRequest("secret1", "POST", "/bibutils/solr/delete/?days=300", timestamp))
+	_, err = auth.Authenticate(req)
+	if err != ErrInvalidCredentials {
+		t.Errorf("Expected invalid credentials error, got: %v", err)
+	}
+
+	// Old signature
+	old := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+	req.Header.Set("X-Timestamp", old)
+	req.Header.Set("X-Signature", SignRequest("secret1", "POST", uri, old))
+	_, err = auth.Authenticate(req)
+	if err != ErrExpiredSignature {
+		t.Errorf("Expected expired signature error, got: %v", err)
+	}
+}
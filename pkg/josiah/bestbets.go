@@ -1,6 +1,7 @@
 package josiah
 
 import (
+	"bibService/pkg/metrics"
 	"context"
 	"strings"
 
@@ -112,13 +113,24 @@ func (bb BestBets) Download(rangeStr string) (BestBetsTable, error) {
 	return table, nil
 }
 
-func (bb BestBets) UpdateSolr(data BestBetsTable, solrURL string, deleteAll bool) error {
+// UpdateSolr posts the BestBets data to Solr. When deleteAll is true the
+// existing BestBets are deleted first. Returns the number of documents in
+// Solr before and after the update.
+func (bb BestBets) UpdateSolr(data BestBetsTable, solrURL string, deleteAll bool) (OperationStats, error) {
+	stats := OperationStats{}
 	solrCore := solr.New(solrURL, false)
+	count, err := solrCore.Count()
+	if err != nil {
+		return stats, err
+	}
+	stats.CountBefore = count
+	stats.CountAfter = count
 
 	if deleteAll {
 		err := solrCore.DeleteAll()
+		metrics.ObserveSolr(solrURL, "deleteAll", 0, err)
 		if err != nil {
-			return err
+			return stats, err
 		}
 	}
 
@@ -132,6 +144,12 @@ func (bb BestBets) UpdateSolr(data BestBetsTable, solrURL string, deleteAll bool
 		doc.Data["term"] = row.Terms()
 		docs = append(docs, doc)
 	}
-	err := solrCore.PostDocs(docs)
-	return err
+	err = solrCore.PostDocs(docs)
+	metrics.ObserveSolr(solrURL, "post", len(docs), err)
+	if err != nil {
+		return stats, err
+	}
+
+	stats.CountAfter, err = solrCore.Count()
+	return stats, err
 }
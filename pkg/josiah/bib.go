@@ -1,11 +1,15 @@
 package josiah
 
 import (
+	"bibService/pkg/marcimport"
+	"bibService/pkg/metrics"
 	"bibService/pkg/sierra"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"strconv"
+	"net/url"
 	"strings"
 
 	"github.com/hectorcorrea/solr"
@@ -14,6 +18,9 @@ import (
 // 2000 seems to be the limit that Sierra imposes
 const pageSize = 1000
 
+// Number of documents to submit to Solr on each HTTP POST
+const solrBatchSize = 500
+
 type ShelfResp struct {
 	Aisle        string `json:"aisle"`
 	DisplayAisle string `json:"display_aisle"`
@@ -28,21 +35,39 @@ type JosiahItem struct {
 	Callnumber string    `json:"callnumber"`
 	Location   string    `json:"location"`
 	MapUrl     string    `json:"map"`
+	Requests   []string  `json:"requests"` // requests allowed (annex, hold, scan)
 	Shelf      ShelfResp `json:"shelf"`
 	Status     string    `json:"status"`
 }
 
+// JosiahItems are the items for a BIB. Requestable, Requests, and Summary
+// account for all the items in the BIB even when only a page of them is
+// returned in Items.
 type JosiahItems struct {
-	HasMore     bool         `json:"has_more"`
-	Items       []JosiahItem `json:"items"`
-	MoreLink    string       `json:"more_link"`
-	Requestable bool         `json:"requestable"`
-	Summary     []string     `json:"summary"`
+	HasMore     bool            `json:"has_more"`
+	Items       []JosiahItem    `json:"items"`
+	MoreLink    string          `json:"more_link"`
+	Requestable bool            `json:"requestable"`
+	Requests    []string        `json:"requests"` // requests allowed for any of the items
+	Summary     []string        `json:"summary"`
+	Total       int             `json:"total"`
+	Holdings    []JosiahHolding `json:"holdings"` // only for serials
+}
+
+// JosiahHolding is a holdings record for a serial, e.g. "v.1-45 (1990-2020)"
+// at "Rockefeller Stacks"
+type JosiahHolding struct {
+	Location   string   `json:"location"`
+	Statements []string `json:"statements"`
 }
 
+// Default number of items returned per request (see Settings.ItemsLimit)
+const defaultItemsPageSize = 100
+
 type BibModel struct {
 	settings Settings
-	api      sierra.Sierra
+	api      *sierra.Sierra
+	cache    *ResponseCache
 	solrUrl  string
 }
 
@@ -53,87 +78,193 @@ type Range struct {
 
 func NewBibModel(settings Settings) BibModel {
 	model := BibModel{settings: settings}
-	model.api = sierra.NewSierra(model.settings.SierraURL, model.settings.KeySecret, model.settings.SessionFile)
-	model.api.Verbose = settings.Verbose
+	model.api = sierraClient(settings)
+	model.cache = responseCache(settings)
 	model.solrUrl = settings.SolrURL
 	return model
 }
 
+// NewBatchBibModel creates a BibModel for jobs and command line actions,
+// its calls to Sierra wait for the rate limit to reset rather than failing.
+func NewBatchBibModel(settings Settings) BibModel {
+	model := NewBibModel(settings)
+	model.api = sierraBatchClient(settings)
+	return model
+}
+
 func (model BibModel) GetBibs(bibs string) (sierra.Bibs, error) {
 	ids := idsFromBib(bibs)
 	if ids == "" {
 		return sierra.Bibs{}, errors.New("No ID was received")
 	}
 
-	params := map[string]string{
-		"id": ids,
+	if strings.Contains(ids, "[") {
+		// ranges are not cached
+		query := sierra.BibQuery{IDs: strings.Split(ids, ",")}
+		return model.api.GetBibs(query, true)
 	}
-	sierraBibs, err := model.api.GetBibs(params, true)
-	if err != nil {
-		return sierra.Bibs{}, err
-	}
-	return sierraBibs, err
+	return model.getBibsCached(strings.Split(ids, ","))
 }
 
-func (model BibModel) GetBibRange(fromBib, toBib string) (sierra.Bibs, error) {
-	bibs := sierra.Bibs{}
-	fromId := idFromBib(fromBib)
-	toId := idFromBib(toBib)
-	pageNum := 0
-	for {
-		pageNum += 1
-		page, err := model.bibRangePaginated(fromId, toId, pageNum)
+// getBibsCached fetches the BIB records (and their items) for the given
+// IDs. Only the records (or items) that are not in the cache are requested
+// from Sierra.
+func (model BibModel) getBibsCached(ids []string) (sierra.Bibs, error) {
+	bibs := map[string]sierra.Bib{}
+	missing := []string{}
+	for _, id := range ids {
+		var bib sierra.Bib
+		if model.cache.Get(CacheBib, id, &bib) {
+			bibs[id] = bib
+		} else if !in(missing, id) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		fetched, err := model.api.GetBibs(sierra.BibQuery{IDs: missing}, false)
 		if err != nil {
 			return sierra.Bibs{}, err
 		}
-		for _, entry := range page.Entries {
-			if !entry.Deleted {
-				bibs.Total += 1
-				bibs.Entries = append(bibs.Entries, entry)
-			}
+		for _, bib := range fetched.Entries {
+			// drop the items and MARC cached for a previous version of the BIB
+			model.cache.Invalidate(bib.Id, bib.UpdatedDateTime)
+			model.cache.Set(CacheBib, bib.Id, bib.UpdatedDateTime, bib)
+			bibs[bib.Id] = bib
 		}
-		if page.Total < pageSize {
-			break
+	}
+
+	missing = []string{}
+	for id, bib := range bibs {
+		if bib.Deleted {
+			continue
+		}
+		if !model.cache.Get(CacheItems, id, &bib.Items) {
+			missing = append(missing, id)
+		}
+		bibs[id] = bib
+	}
+	if len(missing) > 0 {
+		items, err := model.api.ItemsForBibs(missing)
+		if err != nil {
+			return sierra.Bibs{}, err
 		}
+		for _, id := range missing {
+			bib := bibs[id]
+			bib.Items = items.ForBib(id)
+			model.cache.Set(CacheItems, id, bib.UpdatedDateTime, bib.Items)
+			bibs[id] = bib
+		}
+	}
+
+	result := sierra.Bibs{}
+	for _, id := range ids {
+		bib, found := bibs[id]
+		if found {
+			result.Entries = append(result.Entries, bib)
+			delete(bibs, id)
+		}
+	}
+	result.Total = len(result.Entries)
+	return result, nil
+}
+
+// BibPageFunc is called with each page of (non-deleted) BIB records fetched
+// from Sierra. Returning an error stops the iteration.
+type BibPageFunc func(bibs []sierra.Bib) error
+
+func (model BibModel) GetBibRange(fromBib, toBib string) (sierra.Bibs, error) {
+	bibs := sierra.Bibs{}
+	err := model.EachBibRange(context.Background(), fromBib, toBib, collectBibs(&bibs))
+	if err != nil {
+		return sierra.Bibs{}, err
 	}
 	return bibs, nil
 }
 
-func (model BibModel) bibRangePaginated(fromBib, toBib string, page int) (sierra.Bibs, error) {
-	offset := (page - 1) * pageSize
-	params := map[string]string{
-		"offset": strconv.Itoa(offset),
-		"limit":  strconv.Itoa(pageSize),
+// EachBibRange fetches the BIB records in the given range page by page
+// and calls fn for each page. This allows callers to process large ranges
+// without holding all the records in memory.
+func (model BibModel) EachBibRange(ctx context.Context, fromBib, toBib string, fn BibPageFunc) error {
+	fromId := idFromBib(fromBib)
+	toId := idFromBib(toBib)
+	fetch := func(pageNum int) (sierra.Bibs, error) {
+		return model.bibRangePaginated(fromId, toId, pageNum)
 	}
+	return eachPage(ctx, fetch, fn)
+}
 
-	if fromBib == "" && fromBib == "" {
+func (model BibModel) bibRangePaginated(fromBib, toBib string, page int) (sierra.Bibs, error) {
+	if fromBib == "" && toBib == "" {
 		return sierra.Bibs{}, errors.New("No BIB range was received")
-	} else {
-		params["id"] = fmt.Sprintf("[%s,%s]", fromBib, toBib)
 	}
-	return model.api.GetBibs(params, true)
+	query := sierra.BibQuery{
+		FromID: fromBib,
+		ToID:   toBib,
+		Offset: (page - 1) * pageSize,
+		Limit:  pageSize,
+	}
+	return model.api.GetBibs(query, true)
 }
 
 func (model BibModel) GetBibsUpdated(fromDate, toDate string, includeItems bool) (sierra.Bibs, error) {
 	bibs := sierra.Bibs{}
+	err := model.EachBibUpdated(context.Background(), fromDate, toDate, includeItems, collectBibs(&bibs))
+	if err != nil {
+		return sierra.Bibs{}, err
+	}
+	return bibs, nil
+}
+
+// EachBibUpdated fetches the BIB records updated in the given date range
+// page by page and calls fn for each page.
+func (model BibModel) EachBibUpdated(ctx context.Context, fromDate, toDate string, includeItems bool, fn BibPageFunc) error {
+	fetch := func(pageNum int) (sierra.Bibs, error) {
+		return model.bibsUpdatedPaginated(fromDate, toDate, pageNum, includeItems)
+	}
+	return eachPage(ctx, fetch, fn)
+}
+
+// eachPage fetches pages (via the fetch function) until there are no more
+// pages or the context is cancelled and calls fn with the non-deleted BIB
+// records on each page.
+func eachPage(ctx context.Context, fetch func(pageNum int) (sierra.Bibs, error), fn BibPageFunc) error {
 	pageNum := 0
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		pageNum += 1
-		page, err := model.bibsUpdatedPaginated(fromDate, toDate, pageNum, includeItems)
+		page, err := fetch(pageNum)
 		if err != nil {
-			return sierra.Bibs{}, err
+			return err
 		}
+		bibs := []sierra.Bib{}
 		for _, entry := range page.Entries {
 			if !entry.Deleted {
-				bibs.Total += 1
-				bibs.Entries = append(bibs.Entries, entry)
+				bibs = append(bibs, entry)
+			}
+		}
+		if len(bibs) > 0 {
+			err = fn(bibs)
+			if err != nil {
+				return err
 			}
 		}
 		if page.Total < pageSize {
 			break
 		}
 	}
-	return bibs, nil
+	return nil
+}
+
+// collectBibs returns a BibPageFunc that accumulates the BIB records
+// in the given Bibs.
+func collectBibs(bibs *sierra.Bibs) BibPageFunc {
+	return func(page []sierra.Bib) error {
+		bibs.Total += len(page)
+		bibs.Entries = append(bibs.Entries, page...)
+		return nil
+	}
 }
 
 func (model BibModel) GetBibsSuppressed(fromDate, toDate string) ([]string, error) {
@@ -162,7 +293,7 @@ func (model BibModel) GetBibsDeleted(fromDate, toDate string) ([]string, error)
 		pageNum += 1
 		page, err := model.bibsDeletedPaginated(fromDate, toDate, pageNum)
 		if err != nil {
-			if err.Error() == "Status code 404" {
+			if sierra.IsNotFound(err) {
 				// nothing to delete, no big deal
 				return bibs, nil
 			}
@@ -179,61 +310,174 @@ func (model BibModel) GetBibsDeleted(fromDate, toDate string) ([]string, error)
 }
 
 // Delete removes from Solr the IDs of the records that have been deleted
-// in Sierra or that have been marked as Suppressed in Sierra.
-func (model BibModel) Delete(fromDate, toDate string) error {
-	solrClient := solr.New(model.solrUrl, true)
-	beginCount, err := solrClient.Count()
+// in Sierra or that have been marked as Suppressed in Sierra. Returns the
+// IDs deleted and the number of documents in Solr before and after.
+//
+// Nothing is deleted (and a ThresholdError is returned) if the number of
+// documents to delete goes over the limits in the settings, unless force
+// is true.
+func (model BibModel) Delete(fromDate, toDate string, force bool) (OperationStats, error) {
+	stats := OperationStats{}
+	plan, err := model.DeletePreview(fromDate, toDate)
 	if err != nil {
-		return err
+		return stats, err
 	}
+	stats.CountBefore = plan.SolrCount
+	stats.CountAfter = plan.SolrCount
 
-	deleted, err := model.GetBibsDeleted(fromDate, toDate)
-	if err != nil {
-		return err
-	}
+	// The records are deleted/suppressed in Sierra whether or not we remove
+	// them from Solr (see below) so we stop serving them from the cache.
+	// Notice that DeletePreview does not touch the cache.
+	model.uncache(plan)
 
-	if len(deleted) != 0 {
-		err = solrClient.Delete(deleted)
+	if !force {
+		err = model.settings.DeleteLimits().Check("Solr delete", plan.Count(), plan.SolrCount)
 		if err != nil {
-			log.Printf("Error deleting from Solr deleted records in Sierra (%d)", len(deleted))
-			return err
+			log.Printf("Skipped deleting from Solr (D=%d, S=%d): %s", len(plan.Deleted), len(plan.Suppressed), err)
+			return stats, err
 		}
+	} else if plan.LimitExceeded != "" {
+		log.Printf("WARN: Deleting from Solr even though the limit was exceeded (forced): %s", plan.LimitExceeded)
 	}
 
-	suppressed, err := model.GetBibsSuppressed(fromDate, toDate)
-	if err != nil {
-		return err
+	solrClient := solr.New(model.solrUrl, true)
+	if len(plan.Deleted) != 0 {
+		err = solrClient.Delete(plan.Deleted)
+		metrics.ObserveSolr(model.solrUrl, "delete", len(plan.Deleted), err)
+		if err != nil {
+			log.Printf("Error deleting from Solr deleted records in Sierra (%d)", len(plan.Deleted))
+			return stats, err
+		}
+		stats.DeletedIDs = append(stats.DeletedIDs, plan.Deleted...)
 	}
 
-	if len(suppressed) != 0 {
-		err = solrClient.Delete(suppressed)
+	if len(plan.Suppressed) != 0 {
+		err = solrClient.Delete(plan.Suppressed)
+		metrics.ObserveSolr(model.solrUrl, "delete", len(plan.Suppressed), err)
 		if err != nil {
-			log.Printf("Error deleting from Solr suppressed records in Sierra (%d)", len(suppressed))
-			return err
+			log.Printf("Error deleting from Solr suppressed records in Sierra (%d)", len(plan.Suppressed))
+			return stats, err
 		}
+		stats.DeletedIDs = append(stats.DeletedIDs, plan.Suppressed...)
 	}
 
-	endCount, err := solrClient.Count()
+	endCount, err := model.solrCount()
 	if err != nil {
-		return err
+		return stats, err
 	}
+	stats.CountAfter = endCount
 
 	// It's possible that the totals don't add up. For example, running the delete
 	// for the same date range twice will report 0 records deleted in Solr the
 	// second time (even if Sierra reports that there are records deleted and
 	// suppressed)
-	log.Printf("Deleted %d documents from Solr (D=%d, S=%d)", beginCount-endCount, len(deleted), len(suppressed))
-	return nil
+	log.Printf("Deleted %d documents from Solr (D=%d, S=%d)", plan.SolrCount-endCount, len(plan.Deleted), len(plan.Suppressed))
+	return stats, nil
+}
+
+// uncache removes from the response cache the records in the plan.
+func (model BibModel) uncache(plan SolrDeletePlan) {
+	for _, bib := range plan.Deleted {
+		model.cache.Remove(idFromBib(bib))
+	}
+	for _, bib := range plan.Suppressed {
+		model.cache.Remove(idFromBib(bib))
+	}
+}
+
+func (model BibModel) solrCount() (int, error) {
+	solrClient := solr.New(model.solrUrl, true)
+	return solrClient.Count()
+}
+
+// SolrDoc fetches a BIB record from Sierra and returns the Solr document
+// for it.
+func (model BibModel) SolrDoc(bib string) (marcimport.SolrDoc, error) {
+	bibs, err := model.GetBibs(bib)
+	if err != nil {
+		return marcimport.SolrDoc{}, err
+	}
+	docs := NewSolrDocs(bibs)
+	if len(docs) == 0 {
+		return marcimport.SolrDoc{}, sierra.NewNotFoundError(fmt.Sprintf("No BIB was found for %s", bib))
+	}
+	return docs[0], nil
+}
+
+// Index fetches from Sierra the BIB records indicated (a comma delimited
+// list) and updates them in Solr. Returns the number of documents posted.
+func (model BibModel) Index(bibs string) (int, error) {
+	sierraBibs, err := model.GetBibs(bibs)
+	if err != nil {
+		return 0, err
+	}
+	return model.indexBibs(sierraBibs)
+}
+
+// IndexRange fetches from Sierra the BIB records in the given range and
+// updates them in Solr. Returns the number of documents posted.
+func (model BibModel) IndexRange(fromBib, toBib string) (int, error) {
+	count := 0
+	err := model.EachBibRange(context.Background(), fromBib, toBib, func(page []sierra.Bib) error {
+		indexed, err := model.indexBibs(sierra.Bibs{Total: len(page), Entries: page})
+		count += indexed
+		return err
+	})
+	return count, err
 }
 
+func (model BibModel) indexBibs(bibs sierra.Bibs) (int, error) {
+	docs := NewSolrDocs(bibs)
+	solrClient := solr.New(model.solrUrl, model.settings.Verbose)
+	for start := 0; start < len(docs); start += solrBatchSize {
+		end := start + solrBatchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		bytes, err := json.Marshal(docs[start:end])
+		if err != nil {
+			return start, err
+		}
+		err = solrClient.PostString(string(bytes))
+		metrics.ObserveSolr(model.solrUrl, "post", end-start, err)
+		if err != nil {
+			log.Printf("Error posting to Solr documents %d-%d", start, end)
+			return start, err
+		}
+	}
+	log.Printf("Indexed %d documents in Solr", len(docs))
+
+	// Bibs deleted or suppressed since they were indexed are removed right
+	// away rather than waiting for Delete (which can be skipped when there
+	// are too many records to delete).
+	removed := solrRemovedIDs(bibs)
+	if len(removed) != 0 {
+		err := solrClient.Delete(removed)
+		metrics.ObserveSolr(model.solrUrl, "delete", len(removed), err)
+		if err != nil {
+			log.Printf("Error deleting from Solr deleted/suppressed records in Sierra (%d)", len(removed))
+			return len(docs), err
+		}
+		for _, bib := range bibs.Entries {
+			if notInSolr(bib) {
+				model.cache.Remove(bib.Id)
+			}
+		}
+		log.Printf("Deleted %d deleted/suppressed documents from Solr", len(removed))
+	}
+	return len(docs), nil
+}
+
+// GetSolrDeleteQuery returns the Solr XML delete command for the records
+// that Delete would remove for the given date range.
 func (model BibModel) GetSolrDeleteQuery(fromDate, toDate string) (string, error) {
-	bibs, err := model.GetBibsDeleted(fromDate, toDate)
-	if len(bibs) == 0 || err != nil {
+	plan, err := model.DeletePreview(fromDate, toDate)
+	if plan.Count() == 0 || err != nil {
 		return "", err
 	}
 
 	query := "<delete>\r\n"
-	for _, bib := range bibs {
+	for _, bib := range append(plan.Deleted, plan.Suppressed...) {
 		query += fmt.Sprintf("<id>%s</id>\r\n", bib)
 	}
 	query += "</delete>"
@@ -241,40 +485,41 @@ func (model BibModel) GetSolrDeleteQuery(fromDate, toDate string) (string, error
 }
 
 func (model BibModel) bibsDeletedPaginated(fromDate, toDate string, page int) (sierra.Bibs, error) {
-	offset := (page - 1) * pageSize
-	params := map[string]string{
-		"offset": strconv.Itoa(offset),
-		"limit":  strconv.Itoa(pageSize),
-	}
-
 	if fromDate == "" && toDate == "" {
 		return sierra.Bibs{}, errors.New("No date range was received")
-	} else {
-		params["deletedDate"] = dateRange(fromDate, toDate)
 	}
-	return model.api.GetBibs(params, false)
+	query := sierra.BibQuery{
+		DeletedFrom: fromDate,
+		DeletedTo:   toDate,
+		Offset:      (page - 1) * pageSize,
+		Limit:       pageSize,
+	}
+	return model.api.GetBibs(query, false)
 }
 
 func (model BibModel) bibsUpdatedPaginated(fromDate, toDate string, page int, includeItems bool) (sierra.Bibs, error) {
-	offset := (page - 1) * pageSize
-	params := map[string]string{
-		"offset":      strconv.Itoa(offset),
-		"limit":       strconv.Itoa(pageSize),
-		"updatedDate": dateRange(fromDate, toDate),
+	query := sierra.BibQuery{
+		UpdatedFrom: fromDate,
+		UpdatedTo:   toDate,
+		Offset:      (page - 1) * pageSize,
+		Limit:       pageSize,
 	}
-
-	return model.api.GetBibs(params, includeItems)
+	bibs, err := model.api.GetBibs(query, includeItems)
+	for _, bib := range bibs.Entries {
+		model.cache.Invalidate(bib.Id, bib.UpdatedDateTime)
+	}
+	return bibs, err
 }
 
 func (model BibModel) bibsSuppressedPaginated(fromDate, toDate string, page int) (sierra.Bibs, error) {
-	offset := (page - 1) * pageSize
-	params := map[string]string{
-		"offset":      strconv.Itoa(offset),
-		"limit":       strconv.Itoa(pageSize),
-		"suppressed":  "true",
-		"updatedDate": dateRange(fromDate, toDate),
-	}
-	return model.api.GetBibsMinimal(params)
+	query := sierra.BibQuery{
+		UpdatedFrom: fromDate,
+		UpdatedTo:   toDate,
+		Suppressed:  true,
+		Offset:      (page - 1) * pageSize,
+		Limit:       pageSize,
+	}
+	return model.api.GetBibsMinimal(query)
 }
 
 func (model BibModel) GetBibRaw(bib string) (string, error) {
@@ -283,10 +528,7 @@ func (model BibModel) GetBibRaw(bib string) (string, error) {
 		return "", errors.New("No ID was detected on BIB")
 	}
 
-	params := map[string]string{
-		"id": id,
-	}
-	return model.api.GetRaw(params, "")
+	return model.api.GetRaw(queryForID(id))
 }
 
 func (model BibModel) Marc(bib string) (string, error) {
@@ -295,9 +537,18 @@ func (model BibModel) Marc(bib string) (string, error) {
 		return "", errors.New("No ID was detected on BIB")
 	}
 
+	var marcData string
+	if model.cache.Get(CacheMarc, id, &marcData) {
+		return marcData, nil
+	}
+
 	limit := idRangeLimit(id)
 	toc := true
-	return model.api.Marc(id, limit, toc)
+	marcData, err := model.api.Marc(id, limit, toc)
+	if err == nil {
+		model.cache.Set(CacheMarc, id, model.cache.UpdatedDate(id), marcData)
+	}
+	return marcData, err
 }
 
 func (model BibModel) ItemsRaw(bib string) (string, error) {
@@ -309,31 +560,164 @@ func (model BibModel) ItemsRaw(bib string) (string, error) {
 	return model.api.ItemsRaw(id)
 }
 
-func (model BibModel) Items(bib string) (JosiahItems, error) {
+// Items returns the items for a BIB starting at offset. The number of
+// items returned is limited by the itemsPageSize setting, HasMore and
+// MoreLink indicate how to fetch the rest.
+func (model BibModel) Items(bib string, offset int) (JosiahItems, error) {
 	id := idFromBib(bib)
 	if id == "" {
 		return JosiahItems{}, errors.New("No ID was detected on BIB")
 	}
 
-	sierraItems, err := model.api.Items(id)
+	sierraItems, err := model.bibItems(id)
 	if err != nil {
 		return JosiahItems{}, err
 	}
 
-	var items JosiahItems
-	for _, sierraItem := range sierraItems.Entries {
-		if sierraItem.IsForBib(id) {
-			item := JosiahItem{
-				Barcode:    sierraItem.BarcodeClean(),
-				Callnumber: "",
-				Location:   sierraItem.LocationName(),
-				MapUrl:     "",
-				Status:     sierraItem.StatusDisplay(),
+	rules := requestRules(model.settings)
+	items := JosiahItems{
+		Items:    []JosiahItem{},
+		Requests: []string{},
+		Summary:  itemsSummary(sierraItems),
+		Total:    len(sierraItems),
+		Holdings: []JosiahHolding{},
+	}
+	holdings, err := model.serialHoldings(id)
+	if err != nil {
+		// holdings are supplementary, we still return the items
+		log.Printf("ERROR fetching holdings for %s: %s", id, err)
+	}
+	for _, holding := range holdings {
+		statements := holding.Statements()
+		if len(statements) == 0 {
+			continue
+		}
+		items.Holdings = append(items.Holdings, JosiahHolding{Location: holding.LocationName(), Statements: statements})
+		items.Summary = append(items.Summary, holdingsSummary(holding.LocationName(), statements))
+	}
+	for _, sierraItem := range sierraItems {
+		for _, request := range rules.Allowed(sierraItem) {
+			if !in(items.Requests, request) {
+				items.Requests = append(items.Requests, request)
 			}
-			items.Items = append(items.Items, item)
 		}
 	}
-	return items, err
+	items.Requestable = len(items.Requests) > 0
+
+	if offset < 0 {
+		offset = 0
+	}
+	page := []sierra.Item{}
+	if offset < len(sierraItems) {
+		page = sierraItems[offset:]
+	}
+	if limit := model.settings.ItemsLimit(); limit > 0 && len(page) > limit {
+		page = page[:limit]
+		items.HasMore = true
+		items.MoreLink = model.itemsLink(bib, offset+limit)
+	}
+
+	locator := shelfLocator(model.settings)
+	for _, sierraItem := range page {
+		callnumber := sierraItem.CallNumber()
+		shelf := locator.Locate(sierraItem.BuildingName(), sierraItem.LocationCode(), callnumber)
+		item := JosiahItem{
+			Barcode:    sierraItem.BarcodeClean(),
+			Callnumber: strings.TrimSpace(callnumber + " " + sierraItem.Volume()),
+			Location:   sierraItem.LocationName(),
+			MapUrl:     locator.MapURL(shelf, callnumber),
+			Requests:   rules.Allowed(sierraItem),
+			Shelf:      shelf,
+			Status:     sierraItem.StatusDisplay(),
+		}
+		items.Items = append(items.Items, item)
+	}
+	return items, nil
+}
+
+// itemsLink returns the link to fetch the items for a BIB starting at
+// offset (honoring rootUrl like the home page does).
+func (model BibModel) itemsLink(bib string, offset int) string {
+	link := fmt.Sprintf("/bibutils/item/?bib=%s&offset=%d", url.QueryEscape(bib), offset)
+	if model.settings.RootURL != "" {
+		link = strings.Replace(link, "/bibutils/", model.settings.RootURL, 1)
+	}
+	return link
+}
+
+func (model BibModel) bibItems(id string) ([]sierra.Item, error) {
+	var items []sierra.Item
+	if model.cache.Get(CacheItems, id, &items) {
+		return items, nil
+	}
+
+	// Sierra returns only 50 items when no limit is given, ItemsForBibs
+	// fetches all of them page by page.
+	sierraItems, err := model.api.ItemsForBibs([]string{id})
+	if err != nil {
+		return nil, err
+	}
+	items = sierraItems.ForBib(id)
+	model.cache.Set(CacheItems, id, model.cache.UpdatedDate(id), items)
+	return items, nil
+}
+
+// serialHoldings returns the holdings for a BIB if it is a serial (format
+// BP, only serials have holdings). We use the cached BIB to tell when it's
+// available, otherwise we fetch only the varFields (needed to calculate the
+// format) from Sierra and cache an empty list of holdings for non-serials so
+// that we don't ask again.
+func (model BibModel) serialHoldings(id string) ([]sierra.Holding, error) {
+	var bib sierra.Bib
+	if model.cache.Get(CacheBib, id, &bib) {
+		if bib.FormatCode() != "BP" {
+			return nil, nil
+		}
+		return model.bibHoldings(id)
+	}
+
+	var holdings []sierra.Holding
+	if model.cache.Get(CacheHoldings, id, &holdings) {
+		return holdings, nil
+	}
+
+	query := sierra.BibQuery{IDs: []string{id}, Limit: 1, Fields: []string{"varFields"}}
+	bibs, err := model.api.GetBibs(query, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(bibs.Entries) == 0 || bibs.Entries[0].FormatCode() != "BP" {
+		model.cache.Set(CacheHoldings, id, model.cache.UpdatedDate(id), []sierra.Holding{})
+		return nil, nil
+	}
+	return model.bibHoldings(id)
+}
+
+// bibHoldings returns the holdings for a BIB (from the cache when
+// possible).
+func (model BibModel) bibHoldings(id string) ([]sierra.Holding, error) {
+	var holdings []sierra.Holding
+	if model.cache.Get(CacheHoldings, id, &holdings) {
+		return holdings, nil
+	}
+
+	sierraHoldings, err := model.api.Holdings(id)
+	if err != nil {
+		return nil, err
+	}
+	holdings = sierraHoldings.ForBib(id)
+	model.cache.Set(CacheHoldings, id, model.cache.UpdatedDate(id), holdings)
+	return holdings, nil
+}
+
+// holdingsSummary returns the summary for the holdings at a location, e.g.
+// "Library has: v.1-45 (1990-2020) (Rockefeller Stacks)"
+func holdingsSummary(location string, statements []string) string {
+	summary := "Library has: " + strings.Join(statements, "; ")
+	if location != "" {
+		summary += " (" + location + ")"
+	}
+	return summary
 }
 
 func idsFromBib(bibs string) string {
@@ -387,9 +771,14 @@ func idRangeLimit(rangeStr string) int {
 	return limit
 }
 
-func dateRange(fromDate, toDate string) string {
-	// It seems that we cannot pass a time with the date. From what I gather
-	// Sierra automatically appends "00:00:00" to the fromDate and "23:59:59"
-	// to the `toDate`.
-	return "[" + fromDate + "," + toDate + "]"
+// queryForID returns the query to fetch the BIB records for an ID value
+// as returned by idFromBib (i.e. a single ID or a range in the form "[a,b]")
+func queryForID(id string) sierra.BibQuery {
+	if isBibRange(id) {
+		tokens := strings.Split(id[1:len(id)-1], ",")
+		if len(tokens) == 2 {
+			return sierra.BibQuery{FromID: tokens[0], ToID: tokens[1]}
+		}
+	}
+	return sierra.BibQuery{IDs: []string{id}}
 }
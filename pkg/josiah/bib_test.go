@@ -0,0 +1,161 @@
+package josiah
+
+import (
+	"bibService/pkg/marc"
+	"bibService/pkg/sierra"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEachPage(t *testing.T) {
+	fetched := 0
+	fetch := func(pageNum int) (sierra.Bibs, error) {
+		fetched++
+		page := sierra.Bibs{}
+		count := pageSize
+		if pageNum == 3 {
+			count = 10
+		}
+		for i := 0; i < count; i++ {
+			page.Entries = append(page.Entries, sierra.Bib{Id: "1", Deleted: i == 0})
+		}
+		page.Total = count
+		return page, nil
+	}
+
+	bibs := sierra.Bibs{}
+	err := eachPage(context.Background(), fetch, collectBibs(&bibs))
+	if err != nil || fetched != 3 || bibs.Total != (2*pageSize+10)-3 {
+		t.Errorf("Unexpected result: %v, %d, %d", err, fetched, bibs.Total)
+	}
+
+	// Stops fetching once the context is cancelled
+	fetched = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	err = eachPage(ctx, fetch, func(page []sierra.Bib) error {
+		cancel()
+		return nil
+	})
+	if err != context.Canceled || fetched != 1 {
+		t.Errorf("Iteration was not cancelled: %v, %d", err, fetched)
+	}
+}
+
+func TestItemsSerialHoldings(t *testing.T) {
+	settings := Settings{}
+	model := BibModel{settings: settings, cache: NewResponseCache(settings)}
+	serial := sierra.Bib{Id: "1000002", VarFields: marc.MarcFields{
+		{FieldTag: "_", Content: "00000nas a2200445 i 4500"},
+	}}
+	holdings := []sierra.Holding{{
+		Location: map[string]string{"name": "Rockefeller Stacks"},
+		Fields: []marc.MarcField{
+			{MarcTag: "866", Subfields: []map[string]string{{"tag": "a", "content": "v.1-45 (1990-2020)"}}},
+		},
+	}}
+	model.cache.Set(CacheBib, "1000002", "", serial)
+	model.cache.Set(CacheItems, "1000002", "", []sierra.Item{})
+	model.cache.Set(CacheHoldings, "1000002", "", holdings)
+
+	items, err := model.Items("b1000002", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items.Holdings) != 1 || items.Holdings[0].Statements[0] != "v.1-45 (1990-2020)" {
+		t.Errorf("Unexpected holdings: %v", items.Holdings)
+	}
+	if strings.Join(items.Summary, "|") != "Library has: v.1-45 (1990-2020) (Rockefeller Stacks)" {
+		t.Errorf("Unexpected summary: %v", items.Summary)
+	}
+
+	// holdings are only used for serials
+	model.cache.Set(CacheBib, "1000003", "", sierra.Bib{Id: "1000003"})
+	model.cache.Set(CacheItems, "1000003", "", []sierra.Item{})
+	model.cache.Set(CacheHoldings, "1000003", "", holdings)
+	items, err = model.Items("b1000003", 0)
+	if err != nil || len(items.Holdings) != 0 || len(items.Summary) != 0 {
+		t.Errorf("Unexpected holdings for a non-serial: %v, %v", items.Holdings, err)
+	}
+}
+
+func TestItemsSerialHoldingsUncached(t *testing.T) {
+	bibCalls := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+		case "/bibs":
+			bibCalls = append(bibCalls, r.URL.Query().Get("fields"))
+			leader := "00000nam a2200445 i 4500"
+			if r.URL.Query().Get("id") == "1000005" {
+				leader = "00000nas a2200445 i 4500"
+			}
+			fmt.Fprintf(w, `{"total":1,"entries":[{"id":"%s","varFields":[{"fieldTag":"_","content":"%s"}]}]}`, r.URL.Query().Get("id"), leader)
+		case "/holdings":
+			fmt.Fprint(w, `{"total":1,"entries":[{"id":"1","bibIds":["1000005"],"location":{"name":"Rockefeller Stacks"},
+				"varFields":[{"marcTag":"866","subfields":[{"tag":"a","content":"v.1-45"}]}]}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	settings := Settings{}
+	model := BibModel{settings: settings, api: sierra.NewSierra(server.URL, "key:secret", ""), cache: NewResponseCache(settings)}
+	model.cache.Set(CacheItems, "1000004", "", []sierra.Item{})
+	model.cache.Set(CacheItems, "1000005", "", []sierra.Item{})
+
+	// only the varFields are fetched and non-serials are not fetched again
+	for i := 0; i < 2; i++ {
+		items, err := model.Items("b1000004", 0)
+		if err != nil || len(items.Holdings) != 0 {
+			t.Errorf("Unexpected holdings for a non-serial: %v, %v", items.Holdings, err)
+		}
+	}
+	if len(bibCalls) != 1 || bibCalls[0] != "varFields" {
+		t.Errorf("Unexpected calls to /bibs: %v", bibCalls)
+	}
+
+	items, err := model.Items("b1000005", 0)
+	if err != nil || len(items.Holdings) != 1 || items.Holdings[0].Statements[0] != "v.1-45" {
+		t.Errorf("Unexpected holdings for a serial: %v, %v", items.Holdings, err)
+	}
+}
+
+func TestItemsAllPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+		case "/items":
+			// like Sierra, return 50 items when no limit is given
+			count := 50
+			if r.URL.Query().Get("limit") != "" {
+				count = 1000
+				if r.URL.Query().Get("offset") != "0" {
+					count = 20
+				}
+			}
+			entries := []string{}
+			for i := 0; i < count; i++ {
+				entries = append(entries, `{"id":"1","bibIds":["1000006"],"status":{"code":"-"}}`)
+			}
+			fmt.Fprintf(w, `{"total":%d,"entries":[%s]}`, count, strings.Join(entries, ","))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	settings := Settings{}
+	model := BibModel{settings: settings, api: sierra.NewSierra(server.URL, "key:secret", ""), cache: NewResponseCache(settings)}
+	model.cache.Set(CacheHoldings, "1000006", "", []sierra.Holding{})
+	items, err := model.Items("b1000006", 0)
+	if err != nil || items.Total != 1020 || !items.HasMore {
+		t.Errorf("Not all the items were fetched: %d, %v, %v", items.Total, items.HasMore, err)
+	}
+}
@@ -0,0 +1,350 @@
+package josiah
+
+import (
+	"bibService/pkg/metrics"
+	"container/list"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Resources that we cache. Each one has its own TTL (see CacheTTLs).
+const (
+	CacheBib      = "bib"      // BIB record (without items)
+	CacheItems    = "items"    // items for a BIB record
+	CacheMarc     = "marc"     // MARC record for a BIB
+	CacheHoldings = "holdings" // holdings (checkin) records for a serial BIB
+)
+
+// Name of the directory (under CachedDataPath) for the disk cache.
+const cacheDir = "cache"
+
+// How often expired entries are removed from the disk tier.
+const cacheSweepInterval = 1 * time.Hour
+
+// Default number of entries kept in memory.
+const defaultCacheMemoryEntries = 1000
+
+// Default TTLs. Items are cached for a short time since their status
+// changes (e.g. when checked out) without the BIB being updated.
+var defaultCacheTTLs = map[string]time.Duration{
+	CacheBib:      1 * time.Hour,
+	CacheItems:    5 * time.Minute,
+	CacheMarc:     24 * time.Hour,
+	CacheHoldings: 1 * time.Hour,
+}
+
+// Only IDs like these are cached (this keeps file names safe)
+var reCacheID = regexp.MustCompile(`^[0-9]+$`)
+
+// cacheEntry is a cached response. UpdatedDate is the updatedDate of the
+// BIB record when the response was fetched from Sierra.
+type cacheEntry struct {
+	Resource    string          `json:"resource"`
+	ID          string          `json:"id"`
+	Stored      time.Time       `json:"stored"`
+	UpdatedDate string          `json:"updatedDate"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// ResponseCache caches the responses from Sierra for individual BIB
+// records so that popular records don't spend the Sierra API quota. It
+// has two tiers: the most recently used entries are kept in memory and
+// all entries are saved to disk (under CachedDataPath) so that they
+// survive restarts.
+//
+// Entries expire after the TTL for their resource and are invalidated
+// when we see a BIB record with a different updatedDate than the one
+// recorded in the entry (see Invalidate).
+type ResponseCache struct {
+	mutex      sync.Mutex
+	path       string // empty when there is no disk tier
+	ttls       map[string]time.Duration
+	maxEntries int
+	lru        *list.List               // most recently used at the front
+	entries    map[string]*list.Element // values are *cacheEntry
+}
+
+var responseCaches = map[string]*ResponseCache{}
+var responseCachesMutex sync.Mutex
+
+// responseCache returns the cache for the given settings. Like the Sierra
+// client the cache is shared by all the models in the process.
+func responseCache(settings Settings) *ResponseCache {
+	responseCachesMutex.Lock()
+	defer responseCachesMutex.Unlock()
+	cache, ok := responseCaches[settings.CachedDataPath]
+	if !ok {
+		cache = NewResponseCache(settings)
+		responseCaches[settings.CachedDataPath] = cache
+		if cache.path != "" {
+			go cache.sweepEvery(cacheSweepInterval)
+		}
+	}
+	return cache
+}
+
+// NewResponseCache creates a cache with the TTLs indicated in the settings.
+// The disk tier is only used when CachedDataPath is set.
+func NewResponseCache(settings Settings) *ResponseCache {
+	cache := &ResponseCache{
+		ttls:       settings.CacheTTLs(),
+		maxEntries: settings.CacheMemoryEntries,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+	}
+	if cache.maxEntries == 0 {
+		cache.maxEntries = defaultCacheMemoryEntries
+	} else if cache.maxEntries < 0 {
+		// disk tier only
+		cache.maxEntries = 0
+	}
+	if settings.CachedDataPath != "" {
+		cache.path = filepath.Join(settings.CachedDataPath, cacheDir)
+	}
+	return cache
+}
+
+// Enabled returns true if the given resource is cached.
+func (c *ResponseCache) Enabled(resource string) bool {
+	return c != nil && c.ttls[resource] > 0
+}
+
+// Get fetches the cached value for a resource/ID into value. Returns false
+// if the value is not cached or it has expired.
+func (c *ResponseCache) Get(resource, id string, value interface{}) bool {
+	if !c.Enabled(resource) || !reCacheID.MatchString(id) {
+		return false
+	}
+	entry, tier := c.lookup(resource, id)
+	if entry == nil {
+		metrics.CacheRequests.Inc(resource, "miss")
+		return false
+	}
+	err := json.Unmarshal(entry.Data, value)
+	if err != nil {
+		log.Printf("ERROR reading cached %s %s: %s", resource, id, err)
+		c.remove(resource, id)
+		metrics.CacheRequests.Inc(resource, "miss")
+		return false
+	}
+	metrics.CacheRequests.Inc(resource, tier)
+	return true
+}
+
+// Set caches the value for a resource/ID. updatedDate is the updatedDate
+// of the BIB record the value belongs to (empty if unknown).
+func (c *ResponseCache) Set(resource, id, updatedDate string, value interface{}) {
+	if !c.Enabled(resource) || !reCacheID.MatchString(id) {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("ERROR caching %s %s: %s", resource, id, err)
+		return
+	}
+	entry := &cacheEntry{Resource: resource, ID: id, Stored: time.Now(), UpdatedDate: updatedDate, Data: data}
+
+	c.mutex.Lock()
+	c.setMemory(entry)
+	c.mutex.Unlock()
+
+	if c.path != "" {
+		err = os.MkdirAll(filepath.Join(c.path, resource), 0755)
+		if err == nil {
+			err = saveJSON(c.filename(resource, id), entry)
+		}
+		if err != nil {
+			log.Printf("ERROR saving cached %s %s: %s", resource, id, err)
+		}
+	}
+}
+
+// UpdatedDate returns the updatedDate of the BIB record as recorded in
+// its cached entry (empty if the BIB is not cached).
+func (c *ResponseCache) UpdatedDate(id string) string {
+	if !c.Enabled(CacheBib) || !reCacheID.MatchString(id) {
+		return ""
+	}
+	entry, _ := c.lookup(CacheBib, id)
+	if entry == nil {
+		return ""
+	}
+	return entry.UpdatedDate
+}
+
+// Invalidate removes the entries for the BIB that were cached when the
+// BIB had a different updatedDate than the one given (i.e. the BIB has
+// been updated in Sierra since they were cached).
+func (c *ResponseCache) Invalidate(id, updatedDate string) {
+	if c == nil || !reCacheID.MatchString(id) {
+		return
+	}
+	for resource := range defaultCacheTTLs {
+		stored, found := c.storedUpdatedDate(resource, id)
+		if found && stored != updatedDate {
+			c.remove(resource, id)
+			metrics.CacheInvalidations.Inc(resource)
+		}
+	}
+}
+
+// storedUpdatedDate returns the updatedDate recorded in the entry for the
+// resource/ID (expired or not). Since most BIBs seen during a sync are not
+// cached we only read the file when the entry is not in memory and the
+// file exists.
+func (c *ResponseCache) storedUpdatedDate(resource, id string) (string, bool) {
+	c.mutex.Lock()
+	if element, ok := c.entries[resource+"/"+id]; ok {
+		updatedDate := element.Value.(*cacheEntry).UpdatedDate
+		c.mutex.Unlock()
+		return updatedDate, true
+	}
+	c.mutex.Unlock()
+
+	if c.path == "" {
+		return "", false
+	}
+	filename := c.filename(resource, id)
+	if _, err := os.Stat(filename); err != nil {
+		return "", false
+	}
+	var entry cacheEntry
+	bytes, err := ioutil.ReadFile(filename)
+	if err == nil {
+		err = json.Unmarshal(bytes, &entry)
+	}
+	if err != nil {
+		// treat it as outdated so that it gets removed
+		return "", true
+	}
+	return entry.UpdatedDate, true
+}
+
+// Sweep removes the expired entries from the disk tier (and the entries
+// for resources that are no longer cached). Expired entries are otherwise
+// only removed when the same ID is requested again. Returns the number of
+// files removed.
+func (c *ResponseCache) Sweep() int {
+	if c == nil || c.path == "" {
+		return 0
+	}
+	removed := 0
+	for resource := range defaultCacheTTLs {
+		ttl := c.ttls[resource]
+		files, err := ioutil.ReadDir(filepath.Join(c.path, resource))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			// files are written when the entry is stored
+			if ttl > 0 && time.Since(file.ModTime()) < ttl {
+				continue
+			}
+			if os.Remove(filepath.Join(c.path, resource, file.Name())) == nil {
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+func (c *ResponseCache) sweepEvery(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if removed := c.Sweep(); removed > 0 {
+			log.Printf("Removed %d expired entries from the response cache", removed)
+		}
+	}
+}
+
+// Remove removes all the entries for the BIB (e.g. because it has been
+// deleted or suppressed).
+func (c *ResponseCache) Remove(id string) {
+	if c == nil || !reCacheID.MatchString(id) {
+		return
+	}
+	for resource := range defaultCacheTTLs {
+		c.remove(resource, id)
+	}
+}
+
+// lookup returns the entry (if it has not expired) and the tier where it
+// was found.
+func (c *ResponseCache) lookup(resource, id string) (*cacheEntry, string) {
+	ttl := c.ttls[resource]
+	key := resource + "/" + id
+
+	c.mutex.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if time.Since(entry.Stored) < ttl {
+			c.lru.MoveToFront(element)
+			c.mutex.Unlock()
+			return entry, "memory"
+		}
+		c.lru.Remove(element)
+		delete(c.entries, key)
+	}
+	c.mutex.Unlock()
+
+	if c.path == "" {
+		return nil, ""
+	}
+	filename := c.filename(resource, id)
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, ""
+	}
+	var entry cacheEntry
+	err = json.Unmarshal(bytes, &entry)
+	if err != nil || time.Since(entry.Stored) >= ttl {
+		os.Remove(filename)
+		return nil, ""
+	}
+
+	c.mutex.Lock()
+	c.setMemory(&entry)
+	c.mutex.Unlock()
+	return &entry, "disk"
+}
+
+// setMemory adds the entry to the memory tier (the caller must hold the
+// mutex) and evicts the least recently used entries if needed.
+func (c *ResponseCache) setMemory(entry *cacheEntry) {
+	key := entry.Resource + "/" + entry.ID
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries && c.lru.Len() > 0 {
+		oldest := c.lru.Back()
+		old := oldest.Value.(*cacheEntry)
+		delete(c.entries, old.Resource+"/"+old.ID)
+		c.lru.Remove(oldest)
+	}
+}
+
+func (c *ResponseCache) remove(resource, id string) {
+	key := resource + "/" + id
+	c.mutex.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+	}
+	c.mutex.Unlock()
+	if c.path != "" {
+		os.Remove(c.filename(resource, id))
+	}
+}
+
+func (c *ResponseCache) filename(resource, id string) string {
+	return filepath.Join(c.path, resource, id+".json")
+}
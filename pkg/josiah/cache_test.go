@@ -0,0 +1,205 @@
+package josiah
+
+import (
+	"bibService/pkg/sierra"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheTiers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := Settings{CachedDataPath: dir, CacheMemoryEntries: 2}
+	cache := NewResponseCache(settings)
+	cache.Set(CacheMarc, "1000001", "2020-01-01T10:00:00Z", "marc for 1")
+	cache.Set(CacheMarc, "1000002", "", "marc for 2")
+	cache.Set(CacheMarc, "1000003", "", "marc for 3")
+	if cache.lru.Len() != 2 {
+		t.Errorf("Memory tier not limited: %d", cache.lru.Len())
+	}
+
+	// evicted from memory but still on disk
+	var value string
+	if !cache.Get(CacheMarc, "1000001", &value) || value != "marc for 1" {
+		t.Errorf("Value not found on disk: %s", value)
+	}
+
+	// a new cache (e.g. after a restart) finds the values on disk
+	cache = NewResponseCache(settings)
+	if !cache.Get(CacheMarc, "1000003", &value) || value != "marc for 3" {
+		t.Errorf("Value not found after restart: %s", value)
+	}
+	if cache.Get(CacheMarc, "9999999", &value) {
+		t.Errorf("Unexpected value for a record not cached")
+	}
+
+	// IDs that are not plain numbers are never cached
+	cache.Set(CacheMarc, "../x", "", "bad")
+	if cache.Get(CacheMarc, "../x", &value) {
+		t.Errorf("Unexpected value for an invalid ID")
+	}
+}
+
+func TestResponseCacheConcurrentSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := Settings{CachedDataPath: dir}
+	cache := NewResponseCache(settings)
+	value := strings.Repeat("marc for 1 ", 10000)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Set(CacheMarc, "1000001", "", value)
+		}()
+	}
+	wg.Wait()
+
+	var stored string
+	cache = NewResponseCache(settings)
+	if !cache.Get(CacheMarc, "1000001", &stored) || stored != value {
+		t.Errorf("Entry was corrupted by concurrent writes")
+	}
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "cache", CacheMarc))
+	if len(files) != 1 {
+		t.Errorf("Temporary files were left behind: %d files", len(files))
+	}
+}
+
+func TestResponseCacheExpiration(t *testing.T) {
+	settings := Settings{CacheTTL: map[string]int{CacheItems: 1, CacheMarc: -1}}
+	cache := NewResponseCache(settings)
+	if cache.Enabled(CacheMarc) || !cache.Enabled(CacheItems) || !cache.Enabled(CacheBib) {
+		t.Errorf("Unexpected resources enabled: %v", cache.ttls)
+	}
+
+	var value string
+	cache.Set(CacheMarc, "1", "", "marc")
+	if cache.Get(CacheMarc, "1", &value) {
+		t.Errorf("Disabled resource should not be cached")
+	}
+
+	cache.Set(CacheItems, "1", "", "items")
+	if !cache.Get(CacheItems, "1", &value) {
+		t.Errorf("Value not cached")
+	}
+	cache.entries[CacheItems+"/1"].Value.(*cacheEntry).Stored = time.Now().Add(-2 * time.Second)
+	if cache.Get(CacheItems, "1", &value) {
+		t.Errorf("Value did not expire")
+	}
+}
+
+func TestResponseCacheInvalidate(t *testing.T) {
+	cache := NewResponseCache(Settings{})
+	cache.Set(CacheBib, "1", "2020-01-01T10:00:00Z", "bib")
+	cache.Set(CacheItems, "1", "2020-01-01T10:00:00Z", "items")
+	cache.Set(CacheMarc, "1", "", "marc")
+	cache.Set(CacheBib, "2", "2020-01-01T10:00:00Z", "bib 2")
+
+	// same updatedDate: only the entry with an unknown date is dropped
+	cache.Invalidate("1", "2020-01-01T10:00:00Z")
+	var value string
+	if !cache.Get(CacheBib, "1", &value) || !cache.Get(CacheItems, "1", &value) || cache.Get(CacheMarc, "1", &value) {
+		t.Errorf("Unexpected entries after invalidating with the same date")
+	}
+	if cache.UpdatedDate("1") != "2020-01-01T10:00:00Z" {
+		t.Errorf("Unexpected updated date: %s", cache.UpdatedDate("1"))
+	}
+
+	cache.Invalidate("1", "2020-02-01T10:00:00Z")
+	if cache.Get(CacheBib, "1", &value) || cache.Get(CacheItems, "1", &value) {
+		t.Errorf("Entries not invalidated for an updated BIB")
+	}
+
+	cache.Remove("2")
+	if cache.Get(CacheBib, "2", &value) {
+		t.Errorf("Entry not removed")
+	}
+}
+
+func TestResponseCacheSweep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := Settings{CachedDataPath: dir}
+	cache := NewResponseCache(settings)
+	cache.Set(CacheMarc, "1000001", "2020-01-01T10:00:00Z", "marc for 1")
+	cache.Set(CacheMarc, "1000002", "2020-01-01T10:00:00Z", "marc for 2")
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(cache.filename(CacheMarc, "1000001"), old, old)
+
+	if removed := cache.Sweep(); removed != 1 {
+		t.Errorf("Unexpected number of entries removed: %d", removed)
+	}
+	if _, err := os.Stat(cache.filename(CacheMarc, "1000001")); !os.IsNotExist(err) {
+		t.Errorf("Expired entry was not removed: %v", err)
+	}
+
+	// entries only on disk are invalidated too
+	cache = NewResponseCache(settings)
+	cache.Invalidate("1000002", "2020-02-01T10:00:00Z")
+	if _, err := os.Stat(cache.filename(CacheMarc, "1000002")); !os.IsNotExist(err) {
+		t.Errorf("Outdated entry on disk was not invalidated: %v", err)
+	}
+}
+
+func TestGetBibsCached(t *testing.T) {
+	calls := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"token","token_type":"bearer","expires_in":3600}`)
+			return
+		}
+		calls = append(calls, r.URL.Path+"?"+r.URL.Query().Get("id")+r.URL.Query().Get("bibIds"))
+		if r.URL.Path == "/bibs" {
+			entries := []string{}
+			for _, id := range strings.Split(r.URL.Query().Get("id"), ",") {
+				entries = append(entries, fmt.Sprintf(`{"id":"%s","updatedDate":"2020-01-01T10:00:00Z","title":"Title %s"}`, id, id))
+			}
+			fmt.Fprintf(w, `{"total":%d,"entries":[%s]}`, len(entries), strings.Join(entries, ","))
+			return
+		}
+		fmt.Fprint(w, `{"total":1,"entries":[{"id":"i1","bibIds":["1"],"barcode":"3 1236"}]}`)
+	}))
+	defer server.Close()
+
+	settings := Settings{}
+	model := BibModel{settings: settings, api: sierra.NewSierra(server.URL, "key:secret", ""), cache: NewResponseCache(settings)}
+	bibs, err := model.GetBibs("b1")
+	if err != nil || len(bibs.Entries) != 1 || len(bibs.Entries[0].Items) != 1 {
+		t.Fatalf("Unexpected result: %#v, %v", bibs, err)
+	}
+
+	bibs, err = model.GetBibs("b2,b1")
+	if err != nil || bibs.Total != 2 || bibs.Entries[0].Id != "2" || bibs.Entries[1].Title != "Title 1" {
+		t.Fatalf("Unexpected result: %#v, %v", bibs, err)
+	}
+	if len(bibs.Entries[1].Items) != 1 || bibs.Entries[1].Items[0].BarcodeClean() != "31236" {
+		t.Errorf("Items not returned from the cache: %#v", bibs.Entries[1].Items)
+	}
+
+	expected := "/bibs?1 /items?1 /bibs?2 /items?2"
+	if strings.Join(calls, " ") != expected {
+		t.Errorf("Unexpected calls to Sierra: %s", strings.Join(calls, " "))
+	}
+}
@@ -0,0 +1,143 @@
+package josiah
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule represents a cron expression in the standard five field
+// format: minute (0-59), hour (0-23), day of month (1-31), month (1-12),
+// and day of week (0-6, Sunday is 0 or 7). Each field can be "*", a
+// number, a range ("1-5"), a list ("1,15"), or a step ("*/15", "0-30/10").
+type CronSchedule struct {
+	expr    string
+	minutes []bool
+	hours   []bool
+	doms    []bool
+	months  []bool
+	dows    []bool
+	anyDom  bool
+	anyDow  bool
+}
+
+// ParseCron parses a cron expression.
+func ParseCron(expr string) (CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return CronSchedule{}, fmt.Errorf("Invalid cron expression %q: expected 5 fields", expr)
+	}
+
+	var err error
+	cron := CronSchedule{expr: expr}
+	if cron.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid minute in %q: %s", expr, err)
+	}
+	if cron.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid hour in %q: %s", expr, err)
+	}
+	if cron.doms, err = parseCronField(fields[2], 1, 31); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid day of month in %q: %s", expr, err)
+	}
+	if cron.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid month in %q: %s", expr, err)
+	}
+	if cron.dows, err = parseCronField(fields[4], 0, 7); err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid day of week in %q: %s", expr, err)
+	}
+	if cron.dows[7] {
+		cron.dows[0] = true
+	}
+	cron.anyDom = fields[2] == "*"
+	cron.anyDow = fields[4] == "*"
+	return cron, nil
+}
+
+func (cron CronSchedule) String() string {
+	return cron.expr
+}
+
+// Matches returns true if the schedule is due at the given time (to the
+// minute).
+//
+// Like in cron, when both the day of month and the day of week are
+// restricted the schedule matches if either of them matches.
+func (cron CronSchedule) Matches(t time.Time) bool {
+	return cron.minutes[t.Minute()] && cron.hours[t.Hour()] && cron.matchesDay(t)
+}
+
+func (cron CronSchedule) matchesDay(t time.Time) bool {
+	if !cron.months[int(t.Month())] {
+		return false
+	}
+	domMatch := cron.doms[t.Day()]
+	dowMatch := cron.dows[int(t.Weekday())]
+	if cron.anyDom || cron.anyDow {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the next time (after t) when the schedule is due. Returns
+// the zero time if the schedule is not due in the next 4 years (e.g. for
+// "0 0 31 2 *")
+func (cron CronSchedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(4, 0, 0)
+	for next.Before(limit) {
+		if !cron.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if cron.Matches(next) {
+			return next
+		}
+		next = next.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// parseCronField returns an array where the values included in the field
+// are set to true.
+func parseCronField(field string, min, max int) ([]bool, error) {
+	values := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part)
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				// "5/15" means from 5 to the max every 15
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("value out of range %q", part)
+		}
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
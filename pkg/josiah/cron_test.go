@@ -0,0 +1,73 @@
+package josiah
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	invalid := []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"}
+	for _, expr := range invalid {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expected error for %q", expr)
+		}
+	}
+
+	valid := []string{"* * * * *", "0 2 * * *", "*/15 * * * 1-5", "0,30 8-18 1 1,6 7", "5/20 * * * *"}
+	for _, expr := range valid {
+		if _, err := ParseCron(expr); err != nil {
+			t.Errorf("Unexpected error for %q: %s", expr, err)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// Monday, March 2, 2020 at 02:00
+	monday := time.Date(2020, 3, 2, 2, 0, 0, 0, time.UTC)
+
+	cron, _ := ParseCron("0 2 * * *")
+	if !cron.Matches(monday) || cron.Matches(monday.Add(time.Minute)) {
+		t.Errorf("Unexpected matches for %s", cron)
+	}
+
+	cron, _ = ParseCron("*/15 * * * 1-5")
+	if !cron.Matches(monday.Add(45*time.Minute)) || cron.Matches(monday.Add(50*time.Minute)) {
+		t.Errorf("Unexpected matches for %s", cron)
+	}
+	if cron.Matches(monday.AddDate(0, 0, -1)) {
+		t.Errorf("Unexpected match on Sunday for %s", cron)
+	}
+
+	// Sunday can be 0 or 7
+	cron, _ = ParseCron("0 2 * * 7")
+	if !cron.Matches(monday.AddDate(0, 0, -1)) {
+		t.Errorf("Expected match on Sunday for %s", cron)
+	}
+
+	// Day of month OR day of week when both are restricted
+	cron, _ = ParseCron("0 2 15 * 1")
+	if !cron.Matches(monday) || !cron.Matches(time.Date(2020, 3, 15, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected matches for %s", cron)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	now := time.Date(2020, 3, 2, 2, 0, 30, 0, time.UTC)
+
+	cron, _ := ParseCron("0 2 * * *")
+	next := cron.Next(now)
+	if !next.Equal(time.Date(2020, 3, 3, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected next time: %s", next)
+	}
+
+	cron, _ = ParseCron("30 1 1 1 *")
+	next = cron.Next(now)
+	if !next.Equal(time.Date(2021, 1, 1, 1, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected next time: %s", next)
+	}
+
+	cron, _ = ParseCron("0 0 31 2 *")
+	if !cron.Next(now).IsZero() {
+		t.Errorf("Expected no next time for %s", cron)
+	}
+}
@@ -1,11 +1,10 @@
 package josiah
 
 import (
+	"bibService/pkg/sierra"
 	"io"
-	"log"
 	"os"
 	"strings"
-	"time"
 )
 
 type Downloader struct {
@@ -27,7 +26,7 @@ type Batch struct {
 func NewDownloader(settings Settings) Downloader {
 	d := Downloader{
 		settings: settings,
-		Model:    NewBibModel(settings),
+		Model:    NewBatchBibModel(settings),
 		Tracker:  Tracker{},
 	}
 	return d
@@ -57,33 +56,17 @@ func (d Downloader) DownloadBatch(batch Batch, toc bool) error {
 	idRange := idFromBib(bibRange)
 	limit := idRangeLimit(idRange)
 
-	var err error
-	var content string
-	for true {
-		content, err = d.Model.api.Marc(idRange, limit, toc)
-		if err == nil {
-			break
-		}
-
-		http404 := strings.Contains(err.Error(), "Status code 404")
+	// Notice that the Sierra client already retries the request when Sierra
+	// reports that the bib2Marc external process failed (with an exponential
+	// backoff) and when we hit the rate limit (waiting until it resets, see
+	// Settings.BatchRetryPolicy).
+	content, err := d.Model.api.Marc(idRange, limit, toc)
+	if err != nil {
 		empty := strings.Contains(content, "Record not found")
-		if http404 && empty {
-			content = ""
-			break
-		}
-
-		// TODO - handle this error
-		// 2019/12/06 08:28:33 HTTP GET: https://catalog.library.brown.edu/iii/sierra-api/v5/bibs/marc?id=[5470000,5472000]&limit=2001
-		// 2019/12/06 08:28:34 HTTP ERROR: {"code":135,"specificCode":0,"httpStatus":500,"name":"External Process Failed","description":"External Process Failed : bib2Marc process failed twice. Error1: External Process Failed : bib2Marc process failed : External Process Failed : bib2Marc process failed. No records exported, check parameters Error2: External Process Failed : bib2Marc process failed : External Process Failed : bib2Marc process failed. No records exported, check parameters"}
-		// 2019/12/06 08:28:34 &errors.errorString{s:"Status code 500"}
-
-		retry := strings.Contains(content, "Rate exceeded for endpoint")
-		if !retry {
+		if !(sierra.IsNotFound(err) && empty) {
 			return err
 		}
-
-		log.Printf("Going to sleep for 16 minutes...")
-		time.Sleep(16 * time.Minute)
+		content = ""
 	}
 
 	err = d.writeToFile(batch.Filename, content)
@@ -1,12 +1,14 @@
 package josiah
 
 import (
+	"bibService/pkg/metrics"
 	"bibService/pkg/sierra"
 	"database/sql"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -38,32 +40,51 @@ func NewSummaryRowFromSql(name sql.NullString, count sql.NullInt64) SummaryRow {
 	return row
 }
 
-func (e Ecosystem) DownloadCollection(listID int) error {
+// DownloadCollection replaces the data in Josiah's database for the given
+// collection (Sierra list) with the current data in Sierra. Returns the
+// number of rows for the collection before and after.
+func (e Ecosystem) DownloadCollection(listID int) (OperationStats, error) {
+	stats := OperationStats{}
+
 	// Get data from Sierra's database
 	items, err := sierra.CollectionItemsForList(e.sierraConnString, listID)
 	if err != nil {
-		return err
+		return stats, err
 	}
 
-	log.Printf("Connecting to DB: %s", e.josiahConnString)
+	log.Printf("Connecting to Josiah DB")
 	db, err := sql.Open("mysql", e.josiahConnString)
 	if err != nil {
-		return err
+		return stats, err
 	}
 	defer db.Close()
 
+	sqlCount := `SELECT count(*) FROM eco_details WHERE sierra_list = ?`
+	started := time.Now()
+	err = db.QueryRow(sqlCount, listID).Scan(&stats.CountBefore)
+	metrics.ObserveSQL("ecoDetailsCount", started, err)
+	if err != nil {
+		return stats, err
+	}
+	stats.CountAfter = stats.CountBefore
+
 	// Delete previous information
 	log.Printf("Deleting previous saved data in Josiah for this list %d\r\n", listID)
 	sqlDelete := `DELETE FROM eco_details WHERE sierra_list = ?`
+	started = time.Now()
 	_, err = db.Exec(sqlDelete, listID)
+	metrics.ObserveSQL("ecoDetailsDelete", started, err)
 	if err != nil {
-		return err
+		return stats, err
 	}
+	stats.CountAfter = 0
 
 	sqlDelete = `DELETE FROM eco_summaries WHERE sierra_list = ?`
+	started = time.Now()
 	_, err = db.Exec(sqlDelete, listID)
+	metrics.ObserveSQL("ecoSummariesDelete", started, err)
 	if err != nil {
-		return err
+		return stats, err
 	}
 
 	// Save Sierra data in the Josiah SQL database.
@@ -74,25 +95,27 @@ func (e Ecosystem) DownloadCollection(listID int) error {
 		if len(batch) == 5000 {
 			err := e.saveBatch(db, batch)
 			if err != nil {
-				return err
+				return stats, err
 			}
+			stats.CountAfter += len(batch)
 			batch = []sierra.CollectionItemRow{}
 		}
 	}
 	err = e.saveBatch(db, batch)
 	if err != nil {
-		return err
+		return stats, err
 	}
+	stats.CountAfter += len(batch)
 
 	// Calculate and save summary record for this list
 	log.Printf("Saving summary in Josiah for list %d\r\n", listID)
 	err = e.saveSummary(db, listID)
 	if err != nil {
-		return err
+		return stats, err
 	}
 
 	log.Printf("Done saving %d records in Josiah for list %d\r\n", len(items), listID)
-	return nil
+	return stats, nil
 }
 
 func (e Ecosystem) saveBatch(db *sql.DB, batch []sierra.CollectionItemRow) error {
@@ -128,6 +151,7 @@ func (e Ecosystem) saveBatch(db *sql.DB, batch []sierra.CollectionItemRow) error
 		)
 	`
 	for _, item := range batch {
+		started := time.Now()
 		_, err := db.Exec(sqlInsert,
 			item.SierraList, item.BibRecordNum, item.RecordTypeCode, item.Id, item.Title,
 			item.LanguageCode, item.BCode1, item.BCode2, item.BCode3, item.CountryCode,
@@ -139,6 +163,7 @@ func (e Ecosystem) saveBatch(db *sql.DB, batch []sierra.CollectionItemRow) error
 			item.CallnumberRaw, item.CallnumberNorm, item.Publisher,
 			item.OrderRecordNum, item.FundCode, item.FundCodeNum, item.FundCodeMaster,
 			item.MarcTag, item.MarcValue)
+		metrics.ObserveSQL("ecoDetailsInsert", started, err)
 		if err != nil {
 			return err
 		}
@@ -164,7 +189,7 @@ func (e Ecosystem) saveSummary(db *sql.DB, listID int) error {
 		WHERE sierra_list = {listID}
 		GROUP BY location_code
 		ORDER BY 2 DESC`
-	locationCounts, err := e.getSummaryCounts(db, sqlSelect, listID)
+	locationCounts, err := e.getSummaryCounts(db, "ecoSummaryLocations", sqlSelect, listID)
 	if err != nil {
 		return err
 	}
@@ -175,7 +200,7 @@ func (e Ecosystem) saveSummary(db *sql.DB, listID int) error {
     	WHERE sierra_list = {listID}
     	GROUP BY substring_index(callnumber_norm,' ', 1)
     	ORDER BY 2 DESC`
-	callNoCounts, err := e.getSummaryCounts(db, sqlSelect, listID)
+	callNoCounts, err := e.getSummaryCounts(db, "ecoSummaryCallnumbers", sqlSelect, listID)
 	if err != nil {
 		return err
 	}
@@ -186,7 +211,7 @@ func (e Ecosystem) saveSummary(db *sql.DB, listID int) error {
 		WHERE sierra_list = {listID}
 		GROUP BY checkout_total
 		ORDER BY 1 DESC`
-	checkoutCounts, err := e.getSummaryCounts(db, sqlSelect, listID)
+	checkoutCounts, err := e.getSummaryCounts(db, "ecoSummaryCheckouts", sqlSelect, listID)
 	if err != nil {
 		return err
 	}
@@ -208,12 +233,14 @@ func (e Ecosystem) saveSummary(db *sql.DB, listID int) error {
 		)`
 
 	log.Printf("Updating summary record for list %d", listID)
+	started := time.Now()
 	_, err = db.Exec(sqlInsert,
 		listID, listName, bibCount, itemCount, DbUtcNow(),
 		ToJSON(locationCounts),
 		ToJSON(callNoCounts),
 		ToJSON(checkoutCounts),
 		ToJSON(fundCounts))
+	metrics.ObserveSQL("ecoSummaryInsert", started, err)
 	if err != nil {
 		return err
 	}
@@ -228,14 +255,16 @@ func (e Ecosystem) saveSummary(db *sql.DB, listID int) error {
 		GROUP BY substring_index(marc_value, '|', 2)
 		ORDER BY 2 DESC
 		LIMIT 100`
-	subjectCounts, err := e.getSummaryCounts(db, sqlSelect, listID)
+	subjectCounts, err := e.getSummaryCounts(db, "ecoSummarySubjects", sqlSelect, listID)
 	if err != nil {
 		return err
 	}
 
 	sqlUpdate := "UPDATE eco_summaries SET subjects_str = ? WHERE sierra_list = ?"
 	log.Printf("Updating subjects_str for list %d", listID)
+	started = time.Now()
 	_, err = db.Exec(sqlUpdate, ToJSON(subjectCounts), listID)
+	metrics.ObserveSQL("ecoSummaryUpdate", started, err)
 	if err != nil {
 		return err
 	}
@@ -250,17 +279,21 @@ func (e Ecosystem) getBibCounts(db *sql.DB, listID int) (int, int, error) {
 	sqlSelect = strings.ReplaceAll(sqlSelect, "{listID}", strconv.Itoa(listID))
 	log.Printf("Running query: \r\n%s\r\n", sqlSelect)
 
+	started := time.Now()
 	row := db.QueryRow(sqlSelect)
 	var bibCount, itemCount int
 	err := row.Scan(&bibCount, &itemCount)
+	metrics.ObserveSQL("ecoBibCounts", started, err)
 	return bibCount, itemCount, err
 }
 
-func (e Ecosystem) getSummaryCounts(db *sql.DB, sqlSelect string, listID int) ([]SummaryRow, error) {
+func (e Ecosystem) getSummaryCounts(db *sql.DB, queryName string, sqlSelect string, listID int) ([]SummaryRow, error) {
 	sqlSelect = strings.ReplaceAll(sqlSelect, "{listID}", strconv.Itoa(listID))
 	log.Printf("Running query: \r\n%s\r\n", sqlSelect)
 
+	started := time.Now()
 	rows, err := db.Query(sqlSelect)
+	metrics.ObserveSQL(queryName, started, err)
 	if err != nil {
 		return []SummaryRow{}, err
 	}
@@ -292,7 +325,9 @@ func (e Ecosystem) getSummaryFundCodes(db *sql.DB, listID int) ([]SummaryRow, er
 	sqlSelect = strings.ReplaceAll(sqlSelect, "{listID}", strconv.Itoa(listID))
 	log.Printf("Running query: \r\n%s\r\n", sqlSelect)
 
+	started := time.Now()
 	rows, err := db.Query(sqlSelect)
+	metrics.ObserveSQL("ecoSummaryFundCodes", started, err)
 	if err != nil {
 		return []SummaryRow{}, err
 	}
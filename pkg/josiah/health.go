@@ -0,0 +1,148 @@
+package josiah
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health check states
+const (
+	HealthOK      = "ok"
+	HealthError   = "error"
+	HealthSkipped = "skipped" // component not configured
+)
+
+// HealthCheck is the result of checking one of the backends we depend on.
+type HealthCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HealthReport is the result of checking all the backends.
+type HealthReport struct {
+	Status string        `json:"status"` // "ok" only if all the checks are ok (or skipped)
+	Checks []HealthCheck `json:"checks"`
+}
+
+// healthCheckFunc checks a backend. It should give up when ctx is done.
+type healthCheckFunc func(ctx context.Context) error
+
+// DeepHealth checks (in parallel) that we can get a new token for the Sierra
+// API (i.e. our credentials are still valid), query the Sierra and Josiah databases, and reach the Solr cores.
+// Each check is given up to `timeout` to complete.
+func DeepHealth(settings Settings, sierraConnString, josiahConnString string, timeout time.Duration) HealthReport {
+	checks := map[string]healthCheckFunc{}
+	if settings.SierraURL != "" {
+		checks["sierraApi"] = func(ctx context.Context) error {
+			return sierraClient(settings).CheckCredentials(ctx)
+		}
+	}
+	if settings.DbHost != "" {
+		checks["sierraDb"] = func(ctx context.Context) error {
+			return checkDb(ctx, "postgres", sierraConnString)
+		}
+	}
+	if settings.JosiahDbName != "" {
+		checks["josiahDb"] = func(ctx context.Context) error {
+			return checkDb(ctx, "mysql", josiahConnString)
+		}
+	}
+	if settings.SolrURL != "" {
+		checks["solr"] = func(ctx context.Context) error {
+			return checkSolr(ctx, settings.SolrURL)
+		}
+	}
+	if settings.BestBetsSolrURL != "" {
+		checks["solrBestBets"] = func(ctx context.Context) error {
+			return checkSolr(ctx, settings.BestBetsSolrURL)
+		}
+	}
+
+	names := []string{"sierraApi", "sierraDb", "josiahDb", "solr", "solrBestBets"}
+	results := make([]HealthCheck, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		check, configured := checks[name]
+		if !configured {
+			results[i] = HealthCheck{Name: name, Status: HealthSkipped}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, name string, check healthCheckFunc) {
+			defer wg.Done()
+			results[i] = runHealthCheck(name, check, timeout)
+		}(i, name, check)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthOK, Checks: results}
+	for _, result := range results {
+		if result.Status == HealthError {
+			report.Status = HealthError
+		}
+	}
+	return report
+}
+
+// runHealthCheck runs the check and reports it as failed if it does not
+// complete within the timeout. Notice that checks that don't honor the
+// context keep running in the background until they complete on their own.
+func runHealthCheck(name string, check healthCheckFunc, timeout time.Duration) HealthCheck {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	started := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.New("Timed out after " + timeout.String())
+	}
+
+	result := HealthCheck{Name: name, Status: HealthOK, LatencyMs: time.Since(started).Milliseconds()}
+	if err != nil {
+		result.Status = HealthError
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func checkDb(ctx context.Context, driver, connString string) error {
+	db, err := sql.Open(driver, connString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var one int
+	return db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+// checkSolr runs a query that returns no documents against the Solr core.
+func checkSolr(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url+"/select?q=*:*&rows=0&wt=json", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Solr returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
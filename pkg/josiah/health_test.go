@@ -0,0 +1,56 @@
+package josiah
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRunHealthCheck(t *testing.T) {
+	ok := runHealthCheck("ok", func(ctx context.Context) error { return nil }, time.Second)
+	if ok.Status != HealthOK || ok.Name != "ok" {
+		t.Errorf("Unexpected result: %#v", ok)
+	}
+
+	slow := runHealthCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}, 10*time.Millisecond)
+	if slow.Status != HealthError || slow.Error == "" {
+		t.Errorf("Expected timeout: %#v", slow)
+	}
+}
+
+func TestDeepHealth(t *testing.T) {
+	report := DeepHealth(Settings{}, "", "", time.Second)
+	if report.Status != HealthOK || len(report.Checks) != 5 || report.Checks[0].Status != HealthSkipped {
+		t.Errorf("Unexpected report with nothing configured: %#v", report)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	report = DeepHealth(Settings{SolrURL: server.URL}, "", "", time.Second)
+	if report.Status != HealthError || report.Checks[3].Name != "solr" || report.Checks[3].Status != HealthError {
+		t.Errorf("Expected Solr check to fail: %#v", report)
+	}
+}
+
+func TestCheckSolrTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if err := checkSolr(ctx, server.URL); err == nil || time.Since(started) > time.Second {
+		t.Errorf("Solr check did not stop at the deadline: %v", err)
+	}
+}
@@ -0,0 +1,368 @@
+package josiah
+
+import (
+	"bibService/pkg/metrics"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Name of the file (under CachedDataPath) where we persist the jobs.
+const jobsFile = "jobs.json"
+
+// Number of finished jobs to keep in the jobs file.
+const jobsMaxKept = 200
+
+// Number of log lines to keep for each job.
+const jobLogTailSize = 50
+
+// Minimum time between saves of the jobs file for progress (and log)
+// updates, a large job can report its progress thousands of times. Changes
+// in the state of a job are always saved right away.
+const jobsSaveInterval = 10 * time.Second
+
+// Job states
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Job represents a long-running operation (e.g. importing a collection,
+// downloading the MARC records) that runs in the background.
+type Job struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type"`
+	Params   map[string]string `json:"params"`
+	Client   string            `json:"client"` // who submitted the job
+	State    string            `json:"state"`
+	Created  time.Time         `json:"created"`
+	Started  *time.Time        `json:"started,omitempty"`
+	Finished *time.Time        `json:"finished,omitempty"`
+	Progress JobProgress       `json:"progress"`
+	LogTail  []string          `json:"logTail"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// JobProgress indicates how far along a job is. Total is zero when the
+// job does not know ahead of time how much work it has to do.
+type JobProgress struct {
+	Processed int `json:"processed"`
+	Total     int `json:"total"`
+}
+
+// IsFinished returns true if the job has completed or failed.
+func (job Job) IsFinished() bool {
+	return job.State == JobCompleted || job.State == JobFailed
+}
+
+// clone returns a copy of the job that does not share the log with the
+// job being updated by the runner.
+func (job Job) clone() Job {
+	job.LogTail = append([]string{}, job.LogTail...)
+	return job
+}
+
+// JobFunc is the function that performs the work of a job type.
+type JobFunc func(jc *JobContext) error
+
+// JobContext gives a running job access to its parameters and allows it
+// to report its progress.
+type JobContext struct {
+	runner *JobRunner
+	jobID  string
+	Client string
+	Params map[string]string
+}
+
+// Logf adds a line to the job's log (and to the standard log).
+func (jc *JobContext) Logf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("Job %s: %s", jc.jobID, msg)
+	jc.runner.update(jc.jobID, false, func(job *Job) {
+		line := time.Now().Format("2006-01-02 15:04:05") + " " + msg
+		job.LogTail = append(job.LogTail, line)
+		if len(job.LogTail) > jobLogTailSize {
+			job.LogTail = job.LogTail[len(job.LogTail)-jobLogTailSize:]
+		}
+	})
+}
+
+// SetProgress updates the progress counters of the job.
+func (jc *JobContext) SetProgress(processed, total int) {
+	jc.runner.update(jc.jobID, false, func(job *Job) {
+		job.Progress = JobProgress{Processed: processed, Total: total}
+	})
+}
+
+// JobRunner runs jobs in the background (within this process) and keeps
+// track of them in a file under CachedDataPath so that their status
+// survives restarts.
+type JobRunner struct {
+	mutex    sync.Mutex
+	filename string
+	jobs     []Job
+	types    map[string]JobFunc
+	done     map[string]chan bool // closed when the job finishes
+	saved    time.Time            // last time the jobs file was saved
+}
+
+// NewJobRunner loads the jobs persisted in CachedDataPath. Jobs that were
+// running when the process stopped are marked as failed since they will
+// never finish.
+func NewJobRunner(settings Settings) (*JobRunner, error) {
+	runner := &JobRunner{types: map[string]JobFunc{}, done: map[string]chan bool{}}
+	if settings.CachedDataPath != "" {
+		runner.filename = filepath.Join(settings.CachedDataPath, jobsFile)
+	}
+	err := runner.load()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i, job := range runner.jobs {
+		if !job.IsFinished() {
+			runner.jobs[i].State = JobFailed
+			runner.jobs[i].Error = "Job was interrupted (the service was restarted)"
+			runner.jobs[i].Finished = &now
+		}
+	}
+	return runner, runner.save()
+}
+
+// Register defines a job type.
+func (r *JobRunner) Register(jobType string, fn JobFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.types[jobType] = fn
+}
+
+// Types returns the job types that have been registered.
+func (r *JobRunner) Types() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	types := []string{}
+	for jobType := range r.types {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// JobConflictError is returned when a job is submitted while another job
+// of the same type is still running (e.g. two Solr syncs would both
+// advance the high-water mark in sync_state.json).
+type JobConflictError struct {
+	Type      string
+	RunningID string
+}
+
+func (e JobConflictError) Error() string {
+	return fmt.Sprintf("A %s job is already running (job %s)", e.Type, e.RunningID)
+}
+
+// Submit queues a new job of the given type and starts it in the
+// background. Returns the job as it was queued. Only one job of each type
+// runs at a time, a JobConflictError is returned if one is already running.
+func (r *JobRunner) Submit(jobType string, params map[string]string, client string) (Job, error) {
+	r.mutex.Lock()
+	fn, ok := r.types[jobType]
+	if !ok {
+		r.mutex.Unlock()
+		return Job{}, fmt.Errorf("Unknown job type: %s", jobType)
+	}
+	for _, running := range r.jobs {
+		if running.Type == jobType && !running.IsFinished() {
+			r.mutex.Unlock()
+			return Job{}, JobConflictError{Type: jobType, RunningID: running.ID}
+		}
+	}
+	job := Job{
+		ID:      newJobID(),
+		Type:    jobType,
+		Params:  params,
+		Client:  client,
+		State:   JobQueued,
+		Created: time.Now(),
+		LogTail: []string{},
+	}
+	r.jobs = append(r.jobs, job)
+	r.done[job.ID] = make(chan bool)
+	err := r.saveLocked()
+	r.mutex.Unlock()
+	if err != nil {
+		// not fatal, the job can still run
+		log.Printf("ERROR saving jobs: %s", err)
+	}
+
+	go r.run(job, fn)
+	return job.clone(), nil
+}
+
+// Get returns the job with the given ID.
+func (r *JobRunner) Get(id string) (Job, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, job := range r.jobs {
+		if job.ID == id {
+			return job.clone(), true
+		}
+	}
+	return Job{}, false
+}
+
+// Wait blocks until the job with the given ID finishes and returns it.
+func (r *JobRunner) Wait(id string) (Job, bool) {
+	r.mutex.Lock()
+	done, running := r.done[id]
+	r.mutex.Unlock()
+	if running {
+		<-done
+	}
+	return r.Get(id)
+}
+
+// List returns all the jobs, newest first.
+func (r *JobRunner) List() []Job {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	jobs := []Job{}
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, r.jobs[i].clone())
+	}
+	return jobs
+}
+
+func (r *JobRunner) run(job Job, fn JobFunc) {
+	jc := &JobContext{runner: r, jobID: job.ID, Client: job.Client, Params: job.Params}
+	r.update(job.ID, true, func(job *Job) {
+		now := time.Now()
+		job.State = JobRunning
+		job.Started = &now
+	})
+	jc.Logf("Started %s %v", job.Type, job.Params)
+
+	started := time.Now()
+	err := runJobFunc(fn, jc)
+
+	if err != nil {
+		jc.Logf("Failed: %s", err)
+		metrics.JobDuration.ObserveSince(started, job.Type, JobFailed)
+	} else {
+		jc.Logf("Completed")
+		metrics.JobDuration.ObserveSince(started, job.Type, JobCompleted)
+	}
+	r.update(job.ID, true, func(job *Job) {
+		now := time.Now()
+		job.Finished = &now
+		if err != nil {
+			job.State = JobFailed
+			job.Error = err.Error()
+		} else {
+			job.State = JobCompleted
+		}
+	})
+
+	r.mutex.Lock()
+	close(r.done[job.ID])
+	delete(r.done, job.ID)
+	r.mutex.Unlock()
+}
+
+// runJobFunc runs the job making sure a panic in a job does not bring down
+// the whole service.
+func runJobFunc(fn JobFunc, jc *JobContext) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Job panicked: %v", r)
+		}
+	}()
+	return fn(jc)
+}
+
+// update applies the change to the job. The jobs are saved right away when
+// stateChange is true, otherwise (e.g. progress updates) only if they have
+// not been saved in the last jobsSaveInterval.
+func (r *JobRunner) update(id string, stateChange bool, change func(job *Job)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i := range r.jobs {
+		if r.jobs[i].ID == id {
+			change(&r.jobs[i])
+			break
+		}
+	}
+	if !stateChange && time.Since(r.saved) < jobsSaveInterval {
+		return
+	}
+	err := r.saveLocked()
+	if err != nil {
+		log.Printf("ERROR saving jobs: %s", err)
+	}
+}
+
+func (r *JobRunner) load() error {
+	if r.filename == "" {
+		return nil
+	}
+	bytes, err := ioutil.ReadFile(r.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, &r.jobs)
+}
+
+func (r *JobRunner) save() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.saveLocked()
+}
+
+// saveLocked persists the jobs (the caller must hold the mutex). Only
+// the most recent finished jobs are kept.
+func (r *JobRunner) saveLocked() error {
+	finished := 0
+	for _, job := range r.jobs {
+		if job.IsFinished() {
+			finished++
+		}
+	}
+	if finished > jobsMaxKept {
+		// drop the oldest finished jobs
+		toDrop := finished - jobsMaxKept
+		kept := []Job{}
+		for _, job := range r.jobs {
+			if job.IsFinished() && toDrop > 0 {
+				toDrop--
+				continue
+			}
+			kept = append(kept, job)
+		}
+		r.jobs = kept
+	}
+
+	if r.filename == "" {
+		return nil
+	}
+	r.saved = time.Now()
+	return saveJSON(r.filename, r.jobs)
+}
+
+func newJobID() string {
+	bytes := make([]byte, 4)
+	rand.Read(bytes)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(bytes)
+}
@@ -0,0 +1,186 @@
+package josiah
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitForJob(t *testing.T, runner *JobRunner, id string) Job {
+	for i := 0; i < 100; i++ {
+		job, _ := runner.Get(id)
+		if job.IsFinished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", id)
+	return Job{}
+}
+
+func TestJobRunner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	settings := Settings{CachedDataPath: dir}
+
+	runner, err := NewJobRunner(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.Register("ok", func(jc *JobContext) error {
+		jc.SetProgress(5, 10)
+		jc.Logf("Processing %s", jc.Params["id"])
+		return nil
+	})
+	runner.Register("fail", func(jc *JobContext) error {
+		return errors.New("boom")
+	})
+
+	_, err = runner.Submit("unknown", nil, "test")
+	if err == nil {
+		t.Errorf("Expected error for unknown job type")
+	}
+
+	job, err := runner.Submit("ok", map[string]string{"id": "123"}, "test")
+	if err != nil || job.ID == "" {
+		t.Fatalf("Error submitting job: %v", err)
+	}
+	job = waitForJob(t, runner, job.ID)
+	if job.State != JobCompleted || job.Progress.Processed != 5 || job.Client != "test" || len(job.LogTail) != 3 {
+		t.Errorf("Unexpected job: %#v", job)
+	}
+
+	failed, _ := runner.Submit("fail", nil, "test")
+	failed = waitForJob(t, runner, failed.ID)
+	if failed.State != JobFailed || failed.Error != "boom" {
+		t.Errorf("Unexpected failed job: %#v", failed)
+	}
+
+	if jobs := runner.List(); len(jobs) != 2 || jobs[0].ID != failed.ID {
+		t.Errorf("Unexpected job list: %#v", jobs)
+	}
+
+	// Jobs are persisted
+	runner2, err := NewJobRunner(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job2, found := runner2.Get(job.ID)
+	if !found || job2.State != JobCompleted {
+		t.Errorf("Job was not persisted: %#v", job2)
+	}
+}
+
+func TestJobRunnerThrottlesSaves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	settings := Settings{CachedDataPath: dir}
+
+	runner, _ := NewJobRunner(settings)
+	progress := make(chan bool)
+	release := make(chan bool)
+	runner.Register("long", func(jc *JobContext) error {
+		for i := 1; i <= 1000; i++ {
+			jc.SetProgress(i, 1000)
+		}
+		progress <- true
+		<-release
+		return nil
+	})
+	job, _ := runner.Submit("long", nil, "test")
+	<-progress
+
+	// progress is reported right away but not saved on every update
+	current, _ := runner.Get(job.ID)
+	saved, _ := loadJobs(t, settings).Get(job.ID)
+	if current.Progress.Processed != 1000 || saved.Progress.Processed == 1000 || saved.State != JobRunning {
+		t.Errorf("Unexpected progress: %#v (saved %#v)", current.Progress, saved)
+	}
+
+	// the final state is always saved
+	close(release)
+	waitForJob(t, runner, job.ID)
+	saved, _ = loadJobs(t, settings).Get(job.ID)
+	if saved.State != JobCompleted || saved.Progress.Processed != 1000 {
+		t.Errorf("Final state not saved: %#v", saved)
+	}
+}
+
+// loadJobs loads the jobs file without changing it (unlike NewJobRunner
+// which marks running jobs as failed).
+func loadJobs(t *testing.T, settings Settings) *JobRunner {
+	runner := &JobRunner{filename: filepath.Join(settings.CachedDataPath, jobsFile)}
+	if err := runner.load(); err != nil {
+		t.Fatal(err)
+	}
+	return runner
+}
+
+func TestJobRunnerInterrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	settings := Settings{CachedDataPath: dir}
+
+	runner, _ := NewJobRunner(settings)
+	release := make(chan bool)
+	runner.Register("slow", func(jc *JobContext) error {
+		<-release
+		return nil
+	})
+	job, _ := runner.Submit("slow", nil, "test")
+	defer close(release)
+
+	// A new runner (e.g. after a restart) marks the job as failed
+	runner2, _ := NewJobRunner(settings)
+	job2, _ := runner2.Get(job.ID)
+	if job2.State != JobFailed {
+		t.Errorf("Expected interrupted job to be marked as failed: %#v", job2)
+	}
+}
+
+func TestJobRunnerOneJobPerType(t *testing.T) {
+	runner, _ := NewJobRunner(Settings{})
+	release := make(chan bool)
+	runner.Register("solrSync", func(jc *JobContext) error {
+		<-release
+		return nil
+	})
+	runner.Register("other", func(jc *JobContext) error { return nil })
+
+	first, err := runner.Submit("solrSync", nil, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runner.Submit("solrSync", nil, "test")
+	var conflict JobConflictError
+	if !errors.As(err, &conflict) || conflict.RunningID != first.ID {
+		t.Errorf("Second job of the same type was not rejected: %v", err)
+	}
+	if len(runner.List()) != 1 {
+		t.Errorf("Rejected job was queued: %#v", runner.List())
+	}
+
+	// jobs of other types are not affected
+	other, err := runner.Submit("other", nil, "test")
+	if err != nil || waitForJob(t, runner, other.ID).State != JobCompleted {
+		t.Errorf("Job of another type did not run: %v", err)
+	}
+
+	close(release)
+	runner.Wait(first.ID)
+	if _, err = runner.Submit("solrSync", nil, "test"); err != nil {
+		t.Errorf("Job rejected after the previous one finished: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package josiah
+
+import (
+	"bibService/pkg/sierra"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocationsStatus describes the locations table currently in use.
+type LocationsStatus struct {
+	Source string    `json:"source"` // "built-in", "db", the file name, or "db + file name"
+	Count  int       `json:"count"`
+	Loaded time.Time `json:"loaded"`
+	// Buildings in the table that are not in the built-in mappings (e.g.
+	// "Rockefeller Library" from the Sierra DB rather than "Rockefeller").
+	// Request rules and shelf ranges must use these names to match.
+	NewBuildings []string `json:"newBuildings,omitempty"`
+}
+
+var locationsStatus = LocationsStatus{Source: "built-in"}
+var locationsStatusMutex sync.Mutex
+
+// ReloadLocations loads the locations table (from the Sierra database, the
+// locations file, or both) and replaces the one in use. If the locations
+// cannot be loaded the table in use is kept.
+func ReloadLocations(settings Settings, sierraConnString string) (LocationsStatus, error) {
+	if !settings.LocationsFromDb && settings.LocationsFile == "" {
+		return CurrentLocations(), nil
+	}
+
+	values, source, err := LoadLocations(settings, sierraConnString)
+	if err != nil {
+		return CurrentLocations(), err
+	}
+	sierra.SetLocations(values)
+
+	newBuildings := newBuildingNames(values)
+	if len(newBuildings) > 0 {
+		log.Printf("WARN: Locations use buildings not in the built-in mappings (check the request rules and shelf ranges): %s", strings.Join(newBuildings, ", "))
+	}
+
+	locationsStatusMutex.Lock()
+	defer locationsStatusMutex.Unlock()
+	locationsStatus = LocationsStatus{Source: source, Count: len(values), Loaded: time.Now(), NewBuildings: newBuildings}
+	return locationsStatus, nil
+}
+
+// newBuildingNames returns the buildings used by the locations that are
+// not in the built-in mappings (sorted).
+func newBuildingNames(values []sierra.Location) []string {
+	builtIn := sierra.BuiltInBuildings()
+	names := []string{}
+	for _, location := range values {
+		if location.Building != "" && !in(builtIn, location.Building) && !in(names, location.Building) {
+			names = append(names, location.Building)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CurrentLocations returns the status of the locations table in use.
+func CurrentLocations() LocationsStatus {
+	locationsStatusMutex.Lock()
+	defer locationsStatusMutex.Unlock()
+	return locationsStatus
+}
+
+// LoadLocations loads the locations from the Sierra database (when
+// locationsFromDb is set) and from the locations file. Entries in the file
+// take precedence over the ones in the database so that the file can be
+// used to fix or complete them (e.g. to set the online flag).
+func LoadLocations(settings Settings, sierraConnString string) ([]sierra.Location, string, error) {
+	sources := []string{}
+	values := []sierra.Location{}
+	if settings.LocationsFromDb {
+		fromDB, err := sierra.LocationsFromDB(sierraConnString)
+		if err != nil {
+			return nil, "", fmt.Errorf("Error loading locations from the Sierra DB: %s", err)
+		}
+		values = fromDB
+		sources = append(sources, "db")
+	}
+
+	if settings.LocationsFile != "" {
+		fromFile, err := LoadLocationsFile(settings.LocationsFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("Error loading locations from %s: %s", settings.LocationsFile, err)
+		}
+		index := map[string]int{}
+		for i, location := range values {
+			index[strings.ToLower(location.Code)] = i
+		}
+		for _, location := range fromFile {
+			if i, ok := index[strings.ToLower(location.Code)]; ok {
+				values[i] = location
+			} else {
+				values = append(values, location)
+			}
+		}
+		sources = append(sources, settings.LocationsFile)
+	}
+
+	if len(values) == 0 {
+		return nil, "", errors.New("No locations were found")
+	}
+	return values, strings.Join(sources, " + "), nil
+}
+
+// LoadLocationsFile loads the locations from a JSON file (an array of
+// sierra.Location) or a CSV file with a header row using the same names as
+// the JSON fields, for example:
+//
+//	code,name,building,online
+//	rstk,Rockefeller Stacks,Rockefeller,false
+//	es001,Online Resource,Online,true
+func LoadLocationsFile(filename string) ([]sierra.Location, error) {
+	var values []sierra.Location
+	var err error
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		values, err = loadLocationsJSON(filename)
+	} else {
+		values, err = loadLocationsCSV(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, location := range values {
+		if strings.TrimSpace(location.Code) == "" {
+			return nil, fmt.Errorf("Invalid location #%d (%s): code is missing", i+1, location.Name)
+		}
+	}
+	return values, nil
+}
+
+func loadLocationsJSON(filename string) ([]sierra.Location, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	values := []sierra.Location{}
+	err = json.Unmarshal(bytes, &values)
+	return values, err
+}
+
+func loadLocationsCSV(filename string) ([]sierra.Location, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"code", "building"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("Column %s is missing in the header", name)
+		}
+	}
+
+	values := []sierra.Location{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		online := strings.ToLower(value("online"))
+		values = append(values, sierra.Location{
+			Code:     value("code"),
+			Name:     value("name"),
+			Building: value("building"),
+			Online:   online == "true" || online == "yes" || online == "1",
+		})
+	}
+	return values, nil
+}
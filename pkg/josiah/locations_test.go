@@ -0,0 +1,50 @@
+package josiah
+
+import (
+	"bibService/pkg/sierra"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadLocationsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "locations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvFile := filepath.Join(dir, "locations.csv")
+	csv := "code,name,building,online\nzzstk,Test Stacks,Test Library,false\nzzonl,Test Online,Online,yes\n"
+	ioutil.WriteFile(csvFile, []byte(csv), 0644)
+	values, err := LoadLocationsFile(csvFile)
+	if err != nil || len(values) != 2 || values[0].Building != "Test Library" || !values[1].Online {
+		t.Errorf("Unexpected locations: %v, %v", values, err)
+	}
+
+	jsonFile := filepath.Join(dir, "locations.json")
+	ioutil.WriteFile(jsonFile, []byte(`[{"name": "No code", "building": "Test Library"}]`), 0644)
+	if _, err = LoadLocationsFile(jsonFile); err == nil {
+		t.Errorf("Location without a code was accepted")
+	}
+
+	status, err := ReloadLocations(Settings{LocationsFile: csvFile}, "")
+	if err != nil || status.Count != 2 || status.Source != csvFile {
+		t.Errorf("Unexpected status: %v, %v", status, err)
+	}
+	if strings.Join(status.NewBuildings, "|") != "Online|Test Library" {
+		t.Errorf("Unexpected new buildings: %v", status.NewBuildings)
+	}
+	item := sierra.Item{Location: map[string]string{"code": "zzstk"}}
+	if item.BuildingName() != "Test Library" {
+		t.Errorf("Locations not reloaded: %s", item.BuildingName())
+	}
+
+	// a file that cannot be loaded keeps the table in use
+	_, err = ReloadLocations(Settings{LocationsFile: jsonFile}, "")
+	if err == nil || item.BuildingName() != "Test Library" || CurrentLocations().Count != 2 {
+		t.Errorf("Locations replaced with an invalid file: %v", err)
+	}
+}
@@ -2,12 +2,17 @@ package josiah
 
 import (
 	"bibService/pkg/sierra"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // PatronModel handles patron interactions with Sierra.
 type PatronModel struct {
 	settings Settings
-	sierra   sierra.Sierra
+	sierra   *sierra.Sierra
 }
 
 // CheckedoutItem represents the bib information for a checked out item.
@@ -20,11 +25,15 @@ type CheckedoutItem struct {
 	ItemID    string
 }
 
+// ErrInvalidPatronID is returned when the patron ID is not a Sierra record
+// number. Patron IDs become part of the path of the Sierra API calls so
+// anything else (e.g. "123/../456") is rejected before reaching Sierra.
+var ErrInvalidPatronID = errors.New("Invalid patronId, it must be a record number (e.g. 1234567)")
+
 // NewPatronModel creates a new PatronModel
 func NewPatronModel(settings Settings) PatronModel {
 	model := PatronModel{settings: settings}
-	model.sierra = sierra.NewSierra(model.settings.SierraURL, model.settings.KeySecret, model.settings.SessionFile)
-	model.sierra.Verbose = settings.Verbose
+	model.sierra = sierraClient(settings)
 	return model
 }
 
@@ -36,6 +45,9 @@ func (patron PatronModel) checkedouts(patronID string) (sierra.Checkouts, error)
 
 // CheckedoutBibs returns the BIB information for the items checked out by a given patron.
 func (patron PatronModel) CheckedoutBibs(patronID string) ([]CheckedoutItem, error) {
+	if !isRecordNumber(patronID) {
+		return []CheckedoutItem{}, ErrInvalidPatronID
+	}
 	checkouts, err := patron.checkedouts(patronID)
 	if err != nil {
 		return []CheckedoutItem{}, err
@@ -70,3 +82,380 @@ func (patron PatronModel) CheckedoutBibs(patronID string) ([]CheckedoutItem, err
 	}
 	return items, nil
 }
+
+// PatronHold represents a hold placed by a patron.
+type PatronHold struct {
+	HoldID         string
+	BibID          string
+	BibNumber      string
+	Title          string
+	Author         string
+	ItemID         string // empty for BIB-level holds
+	Status         string
+	Ready          bool // ready for pickup
+	Frozen         bool
+	QueuePosition  int
+	QueueLength    int
+	PickupLocation string
+	Placed         string
+	NotNeededAfter string
+	PickupByDate   string
+}
+
+// PatronFine represents a fine owed by a patron.
+type PatronFine struct {
+	ItemID       string
+	BibID        string
+	BibNumber    string
+	Title        string
+	Description  string
+	ChargeType   string
+	Amount       float64
+	AssessedDate string
+}
+
+// PatronSummary represents the status of a patron's account.
+type PatronSummary struct {
+	PatronID       string
+	ExpirationDate string
+	Blocked        bool
+	BlockCode      string
+	BlockUntil     string
+	MoneyOwed      float64
+	Checkouts      int
+	Holds          int
+	HoldsReady     int
+	Fines          int
+}
+
+// Holds returns the holds placed by a given patron with the BIB information
+// for each of them. Like CheckedoutBibs we skip Borrow Direct items.
+func (patron PatronModel) Holds(patronID string) ([]PatronHold, error) {
+	if !isRecordNumber(patronID) {
+		return []PatronHold{}, ErrInvalidPatronID
+	}
+	holds, err := patron.sierra.Holds(patronID)
+	if err != nil {
+		return []PatronHold{}, err
+	}
+
+	items := []PatronHold{}
+	bibIDs := []string{}
+	for _, entry := range holds.Entries {
+		if entry.IsBorrowDirect() {
+			continue
+		}
+		hold := PatronHold{
+			HoldID:         entry.HoldID(),
+			Status:         entry.Status["name"],
+			Ready:          entry.IsReady(),
+			Frozen:         entry.Frozen,
+			QueuePosition:  entry.Priority,
+			QueueLength:    entry.PriorityQueueLength,
+			PickupLocation: entry.PickupLocation["name"],
+			Placed:         entry.Placed,
+			NotNeededAfter: entry.NotNeededAfterDate,
+			PickupByDate:   entry.PickupByDate,
+		}
+		switch entry.RecordType {
+		case "b":
+			hold.BibID = entry.RecordID()
+		case "i":
+			hold.ItemID = entry.RecordID()
+			hold.BibID, err = patron.sierra.BibIDForItemID(hold.ItemID)
+			if err != nil {
+				return []PatronHold{}, err
+			}
+		}
+		if hold.BibID != "" {
+			hold.BibNumber = "b" + hold.BibID
+			bibIDs = append(bibIDs, hold.BibID)
+		}
+		items = append(items, hold)
+	}
+
+	bibs, err := patron.bibs(bibIDs)
+	if err != nil {
+		return []PatronHold{}, err
+	}
+	for i, hold := range items {
+		items[i].Title = bibs[hold.BibID].Title
+		items[i].Author = bibs[hold.BibID].Author
+	}
+	return items, nil
+}
+
+// Fines returns the fines owed by a given patron with the title of the
+// item for each of them. Fines for Borrow Direct items are included (the
+// patron owes them) but without BIB information since we don't have it.
+func (patron PatronModel) Fines(patronID string) ([]PatronFine, error) {
+	if !isRecordNumber(patronID) {
+		return []PatronFine{}, ErrInvalidPatronID
+	}
+	fines, err := patron.sierra.Fines(patronID)
+	if err != nil {
+		return []PatronFine{}, err
+	}
+
+	items := []PatronFine{}
+	bibIDs := []string{}
+	for _, entry := range fines.Entries {
+		fine := PatronFine{
+			ItemID:       entry.ItemID(),
+			Description:  entry.Description,
+			ChargeType:   entry.ChargeType["display"],
+			Amount:       entry.Amount(),
+			AssessedDate: entry.AssessedDate,
+		}
+		if fine.ItemID != "" && !entry.IsBorrowDirect() {
+			fine.BibID, err = patron.sierra.BibIDForItemID(fine.ItemID)
+			if err != nil {
+				return []PatronFine{}, err
+			}
+			fine.BibNumber = "b" + fine.BibID
+			bibIDs = append(bibIDs, fine.BibID)
+		}
+		items = append(items, fine)
+	}
+
+	bibs, err := patron.bibs(bibIDs)
+	if err != nil {
+		return []PatronFine{}, err
+	}
+	for i, fine := range items {
+		items[i].Title = bibs[fine.BibID].Title
+	}
+	return items, nil
+}
+
+// Summary returns the status of a given patron's account: blocks, money
+// owed, and the number of checkouts, holds, and fines. Like CheckedoutBibs
+// the counts do not include Borrow Direct items.
+func (patron PatronModel) Summary(patronID string) (PatronSummary, error) {
+	if !isRecordNumber(patronID) {
+		return PatronSummary{}, ErrInvalidPatronID
+	}
+	record, err := patron.sierra.Patron(patronID)
+	if err != nil {
+		return PatronSummary{}, err
+	}
+	summary := PatronSummary{
+		PatronID:       patronID,
+		ExpirationDate: record.ExpirationDate,
+		Blocked:        record.IsBlocked(),
+		BlockCode:      record.BlockInfo["code"],
+		BlockUntil:     record.BlockInfo["until"],
+		MoneyOwed:      record.MoneyOwed,
+	}
+
+	checkouts, err := patron.checkedouts(patronID)
+	if err != nil {
+		return PatronSummary{}, err
+	}
+	for _, checkout := range checkouts.Entries {
+		if !checkout.IsBorrowDirect() {
+			summary.Checkouts++
+		}
+	}
+
+	holds, err := patron.sierra.Holds(patronID)
+	if err != nil {
+		return PatronSummary{}, err
+	}
+	for _, hold := range holds.Entries {
+		if hold.IsBorrowDirect() {
+			continue
+		}
+		summary.Holds++
+		if hold.IsReady() {
+			summary.HoldsReady++
+		}
+	}
+
+	fines, err := patron.sierra.Fines(patronID)
+	if err != nil {
+		return PatronSummary{}, err
+	}
+	summary.Fines = len(fines.Entries)
+	return summary, nil
+}
+
+// bibs fetches the BIB records (without items) for the given IDs in a
+// single call to Sierra.
+func (patron PatronModel) bibs(bibIDs []string) (map[string]sierra.Bib, error) {
+	bibs := map[string]sierra.Bib{}
+	if len(bibIDs) == 0 {
+		return bibs, nil
+	}
+	ids := []string{}
+	for _, id := range bibIDs {
+		if !in(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	query := sierra.BibQuery{IDs: ids, Limit: len(ids), Fields: []string{"default"}}
+	fetched, err := patron.sierra.GetBibs(query, false)
+	if err != nil {
+		return bibs, err
+	}
+	for _, bib := range fetched.Entries {
+		bibs[bib.Id] = bib
+	}
+	return bibs, nil
+}
+
+// HoldPlacement indicates what to place a hold on. When ItemID is empty
+// the hold is placed on the BIB (i.e. on the first item available).
+type HoldPlacement struct {
+	BibID          string // with or without the "b" prefix
+	ItemID         string // with or without the "i" prefix
+	PickupLocation string
+	NeededBy       string // yyyy-mm-dd
+}
+
+// PlaceHold places a hold for a given patron. Before sending the request
+// to Sierra we check that the patron is not blocked and that the item (or
+// at least one item in the BIB) can be requested according to the request
+// rules. Rejected requests are reported as a sierra.HoldError.
+func (patron PatronModel) PlaceHold(patronID string, placement HoldPlacement) error {
+	if !isRecordNumber(patronID) {
+		return invalidPatronHoldError()
+	}
+	bibID := strings.TrimPrefix(placement.BibID, "b")
+	itemID := strings.TrimPrefix(placement.ItemID, "i")
+	if !isRecordNumber(bibID) && !isRecordNumber(itemID) {
+		return sierra.NewHoldError(sierra.HoldReasonInvalidRequest, "A valid BIB or item number must be indicated")
+	}
+	if placement.PickupLocation == "" {
+		return sierra.NewHoldError(sierra.HoldReasonInvalidPickup, "No pickup location was indicated")
+	}
+	if placement.NeededBy != "" {
+		if _, err := time.Parse("2006-01-02", placement.NeededBy); err != nil {
+			return sierra.NewHoldError(sierra.HoldReasonInvalidRequest, "Invalid neededBy date (must be yyyy-mm-dd): "+placement.NeededBy)
+		}
+	}
+
+	err := patron.checkPatronCanRequest(patronID)
+	if err != nil {
+		return err
+	}
+
+	rules := requestRules(patron.settings)
+	request := sierra.HoldRequest{PickupLocation: placement.PickupLocation, NeededBy: placement.NeededBy}
+	if isRecordNumber(itemID) {
+		item, err := patron.sierra.Item(itemID)
+		if sierra.IsNotFound(err) {
+			return sierra.NewHoldError(sierra.HoldReasonNotFound, "Item not found: i"+itemID)
+		}
+		if err != nil {
+			return err
+		}
+		if bibID != "" && !item.IsForBib(bibID) {
+			return sierra.NewHoldError(sierra.HoldReasonInvalidRequest, "Item i"+itemID+" does not belong to b"+bibID)
+		}
+		if !canHold(rules, item) {
+			return sierra.NewHoldError(sierra.HoldReasonNotRequestable, "Item i"+itemID+" cannot be requested")
+		}
+		request.RecordType = "i"
+		request.RecordNumber, _ = strconv.Atoi(itemID)
+	} else {
+		items, err := patron.sierra.ItemsForBibs([]string{bibID})
+		if err != nil {
+			return err
+		}
+		requestable := false
+		for _, item := range items.ForBib(bibID) {
+			if canHold(rules, item) {
+				requestable = true
+				break
+			}
+		}
+		if !requestable {
+			return sierra.NewHoldError(sierra.HoldReasonNotRequestable, "None of the items in b"+bibID+" can be requested")
+		}
+		request.RecordType = "b"
+		request.RecordNumber, _ = strconv.Atoi(bibID)
+	}
+	return patron.sierra.PlaceHold(patronID, request)
+}
+
+// CancelHold cancels a hold placed by a given patron.
+func (patron PatronModel) CancelHold(patronID string, holdID string) error {
+	_, err := patron.patronHold(patronID, holdID)
+	if err != nil {
+		return err
+	}
+	return patron.sierra.CancelHold(holdID)
+}
+
+// ChangePickupLocation changes the pickup location of a hold placed by a
+// given patron. Holds that are already waiting for pickup cannot be
+// changed.
+func (patron PatronModel) ChangePickupLocation(patronID string, holdID string, pickupLocation string) error {
+	if pickupLocation == "" {
+		return sierra.NewHoldError(sierra.HoldReasonInvalidPickup, "No pickup location was indicated")
+	}
+	hold, err := patron.patronHold(patronID, holdID)
+	if err != nil {
+		return err
+	}
+	if hold.IsReady() {
+		return sierra.NewHoldError(sierra.HoldReasonNotModifiable, "The hold is ready for pickup and its pickup location cannot be changed")
+	}
+	return patron.sierra.UpdateHold(holdID, sierra.HoldUpdate{PickupLocation: pickupLocation})
+}
+
+// patronHold fetches a hold and makes sure that it belongs to the patron
+// (so that clients cannot change holds for other patrons).
+func (patron PatronModel) patronHold(patronID string, holdID string) (sierra.HoldEntry, error) {
+	if !isRecordNumber(patronID) {
+		return sierra.HoldEntry{}, invalidPatronHoldError()
+	}
+	if !isRecordNumber(holdID) {
+		return sierra.HoldEntry{}, sierra.NewHoldError(sierra.HoldReasonInvalidRequest, "Invalid hold ID: "+holdID)
+	}
+	hold, err := patron.sierra.Hold(holdID)
+	if sierra.IsNotFound(err) || (err == nil && hold.PatronID() != patronID) {
+		return sierra.HoldEntry{}, sierra.NewHoldError(sierra.HoldReasonNotFound, "Hold "+holdID+" not found for patron "+patronID)
+	}
+	return hold, err
+}
+
+func invalidPatronHoldError() error {
+	return sierra.NewHoldError(sierra.HoldReasonInvalidRequest, ErrInvalidPatronID.Error())
+}
+
+// checkPatronCanRequest returns a sierra.HoldError if the patron is blocked
+// or their card has expired.
+func (patron PatronModel) checkPatronCanRequest(patronID string) error {
+	record, err := patron.sierra.Patron(patronID)
+	if sierra.IsNotFound(err) {
+		return sierra.NewHoldError(sierra.HoldReasonNotFound, "Patron not found: "+patronID)
+	}
+	if err != nil {
+		return err
+	}
+	if record.IsBlocked() {
+		return sierra.NewHoldError(sierra.HoldReasonPatronBlocked, "The patron is blocked")
+	}
+	today := time.Now().Format("2006-01-02")
+	if record.ExpirationDate != "" && record.ExpirationDate < today {
+		return sierra.NewHoldError(sierra.HoldReasonPatronBlocked, "The patron's card expired on "+record.ExpirationDate)
+	}
+	return nil
+}
+
+// canHold returns true if the request rules allow a hold (or an Annex
+// request, which is placed as a hold too) for the item.
+func canHold(rules *RequestRules, item sierra.Item) bool {
+	allowed := rules.Allowed(item)
+	return in(allowed, RequestHold) || in(allowed, RequestAnnex)
+}
+
+var reRecordNumber = regexp.MustCompile(`^[0-9]+$`)
+
+// isRecordNumber returns true if the value is a Sierra record number
+// without its prefix (e.g. "1234567")
+func isRecordNumber(value string) bool {
+	return reRecordNumber.MatchString(value)
+}
@@ -0,0 +1,182 @@
+package josiah
+
+import (
+	"bibService/pkg/sierra"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testPatronServer() *httptest.Server {
+	responses := map[string]string{
+		"/patrons/111": `{"id":111,"expirationDate":"2030-06-30","moneyOwed":12.5,"blockInfo":{"code":"c","until":"2030-01-01"}}`,
+		"/patrons/111/holds": `{"total":3,"entries":[
+			{"id":"https://s/v5/patrons/holds/1","record":"https://s/v5/bibs/1000001","recordType":"b","priority":2,"priorityQueueLength":5,
+			 "pickupLocation":{"code":"r","name":"Rockefeller"},"status":{"code":"0","name":"on hold."}},
+			{"id":"https://s/v5/patrons/holds/2","record":"https://s/v5/items/2000002","recordType":"i","priority":1,"priorityQueueLength":1,
+			 "status":{"code":"i","name":"item ready for pickup."}},
+			{"id":"https://s/v5/patrons/holds/3","record":"https://s/v5/items/3000003@ncip","recordType":"i"}]}`,
+		"/patrons/111/fines": `{"total":2,"entries":[
+			{"id":"https://s/v5/patrons/fines/1","item":"https://s/v5/items/2000002","itemCharge":10,"processingFee":2.5,"chargeType":{"code":"2","display":"Overdue"}},
+			{"id":"https://s/v5/patrons/fines/2","item":"https://s/v5/items/3000003@ncip","itemCharge":5}]}`,
+		"/patrons/111/checkouts": `{"total":2,"entries":[{"item":"https://s/v5/items/4000004"},{"item":"https://s/v5/items/5000005@ncip"}]}`,
+		"/items/2000002":         `{"id":"2000002","bibIds":["1000002"]}`,
+		"/patrons/222":           `{"id":222,"expirationDate":"2030-06-30","blockInfo":{"code":"-"}}`,
+		"/patrons/holds/1":       `{"id":"https://s/v5/patrons/holds/1","patron":"https://s/v5/patrons/111","status":{"code":"0"}}`,
+		"/patrons/holds/2":       `{"id":"https://s/v5/patrons/holds/2","patron":"https://s/v5/patrons/111","status":{"code":"i"}}`,
+		"/items/6000006":         `{"id":"6000006","bibIds":["1000006"],"location":{"code":"rstk"},"status":{"code":"-"}}`,
+		"/items/7000007":         `{"id":"7000007","bibIds":["1000007"],"location":{"code":"rres"},"status":{"code":"-"}}`,
+		"/items":                 `{"total":1,"entries":[{"id":"7000007","bibIds":["1000007"],"location":{"code":"rres"},"status":{"code":"-"}}]}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+			return
+		}
+		if r.URL.Path == "/bibs" {
+			fmt.Fprint(w, `{"total":2,"entries":[{"id":"1000001","title":"Title 1"},{"id":"1000002","title":"Title 2"}]}`)
+			return
+		}
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"code":107,"httpStatus":404,"name":"Record not found"}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestPatronHoldsAndFines(t *testing.T) {
+	server := testPatronServer()
+	defer server.Close()
+	patron := PatronModel{sierra: sierra.NewSierra(server.URL, "key:secret", "")}
+
+	holds, err := patron.Holds("111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 2 {
+		t.Fatalf("Unexpected number of holds (Borrow Direct should be skipped): %d", len(holds))
+	}
+	if holds[0].Title != "Title 1" || holds[0].QueuePosition != 2 || holds[0].QueueLength != 5 || holds[0].PickupLocation != "Rockefeller" {
+		t.Errorf("Unexpected BIB-level hold: %+v", holds[0])
+	}
+	if holds[1].BibNumber != "b1000002" || holds[1].ItemID != "2000002" || !holds[1].Ready || holds[1].Title != "Title 2" {
+		t.Errorf("Unexpected item-level hold: %+v", holds[1])
+	}
+
+	fines, err := patron.Fines("111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fines) != 2 || fines[0].Title != "Title 2" || fines[0].Amount != 12.5 || fines[0].ChargeType != "Overdue" {
+		t.Errorf("Unexpected fines: %+v", fines)
+	}
+	if fines[1].BibID != "" || fines[1].Amount != 5 {
+		t.Errorf("Unexpected Borrow Direct fine: %+v", fines[1])
+	}
+}
+
+func TestPatronSummary(t *testing.T) {
+	server := testPatronServer()
+	defer server.Close()
+	patron := PatronModel{sierra: sierra.NewSierra(server.URL, "key:secret", "")}
+
+	if _, err := patron.Summary("111/holds"); err != ErrInvalidPatronID {
+		t.Errorf("Invalid patron ID was accepted: %v", err)
+	}
+
+	summary, err := patron.Summary("111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := PatronSummary{PatronID: "111", ExpirationDate: "2030-06-30", Blocked: true, BlockCode: "c", BlockUntil: "2030-01-01",
+		MoneyOwed: 12.5, Checkouts: 1, Holds: 2, HoldsReady: 1, Fines: 2}
+	if summary != expected {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
+
+func testHoldRules(t *testing.T) (Settings, func()) {
+	dir, err := ioutil.TempDir("", "holds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "rules.json")
+	ioutil.WriteFile(filename, []byte(`[{"request": "hold", "allow": true, "locations": ["rstk"]}]`), 0644)
+	return Settings{RequestRulesFile: filename}, func() { os.RemoveAll(dir) }
+}
+
+func holdReason(err error) string {
+	var holdErr sierra.HoldError
+	if errors.As(err, &holdErr) {
+		return holdErr.Reason
+	}
+	return ""
+}
+
+func TestPlaceHold(t *testing.T) {
+	server := testPatronServer()
+	defer server.Close()
+	settings, cleanup := testHoldRules(t)
+	defer cleanup()
+	patron := PatronModel{settings: settings, sierra: sierra.NewSierra(server.URL, "key:secret", "")}
+
+	tests := []struct {
+		patronID  string
+		placement HoldPlacement
+		reason    string
+	}{
+		{"222", HoldPlacement{BibID: "b1000006", ItemID: "i6000006", PickupLocation: "r"}, ""},
+		{"222", HoldPlacement{ItemID: "6000006", PickupLocation: "r"}, ""},
+		{"111", HoldPlacement{ItemID: "6000006", PickupLocation: "r"}, sierra.HoldReasonPatronBlocked},
+		{"999", HoldPlacement{ItemID: "6000006", PickupLocation: "r"}, sierra.HoldReasonNotFound},
+		{"222", HoldPlacement{BibID: "b1000001", ItemID: "i6000006", PickupLocation: "r"}, sierra.HoldReasonInvalidRequest},
+		{"222", HoldPlacement{ItemID: "i7000007", PickupLocation: "r"}, sierra.HoldReasonNotRequestable},
+		{"222", HoldPlacement{ItemID: "i8000008", PickupLocation: "r"}, sierra.HoldReasonNotFound},
+		{"222", HoldPlacement{BibID: "b1000007", PickupLocation: "r"}, sierra.HoldReasonNotRequestable},
+		{"222", HoldPlacement{BibID: "b1000006"}, sierra.HoldReasonInvalidPickup},
+		{"222", HoldPlacement{BibID: "-1", PickupLocation: "r"}, sierra.HoldReasonInvalidRequest},
+		{"222", HoldPlacement{BibID: "b1000006", PickupLocation: "r", NeededBy: "next week"}, sierra.HoldReasonInvalidRequest},
+		{"222/../111", HoldPlacement{BibID: "b1000006", PickupLocation: "r"}, sierra.HoldReasonInvalidRequest},
+	}
+	for _, test := range tests {
+		err := patron.PlaceHold(test.patronID, test.placement)
+		if holdReason(err) != test.reason || (test.reason == "" && err != nil) {
+			t.Errorf("Unexpected result for %s %+v: %v", test.patronID, test.placement, err)
+		}
+	}
+}
+
+func TestCancelAndChangeHold(t *testing.T) {
+	server := testPatronServer()
+	defer server.Close()
+	patron := PatronModel{sierra: sierra.NewSierra(server.URL, "key:secret", "")}
+
+	if err := patron.CancelHold("111", "1"); err != nil {
+		t.Errorf("Error cancelling hold: %s", err)
+	}
+	// holds that belong to other patrons are reported as not found
+	if err := patron.CancelHold("222", "1"); holdReason(err) != sierra.HoldReasonNotFound {
+		t.Errorf("Hold for another patron was cancelled: %v", err)
+	}
+	if err := patron.CancelHold("111?x=", "1"); holdReason(err) != sierra.HoldReasonInvalidRequest {
+		t.Errorf("Invalid patron ID was accepted: %v", err)
+	}
+	if err := patron.ChangePickupLocation("111", "1", "s"); err != nil {
+		t.Errorf("Error changing pickup location: %s", err)
+	}
+	if err := patron.ChangePickupLocation("111", "2", "s"); holdReason(err) != sierra.HoldReasonNotModifiable {
+		t.Errorf("Pickup location changed for a hold ready for pickup: %v", err)
+	}
+}
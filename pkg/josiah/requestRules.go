@@ -0,0 +1,180 @@
+package josiah
+
+import (
+	"bibService/pkg/sierra"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Types of requests that patrons can place for an item.
+const (
+	RequestAnnex = "annex" // request an item from the Annex
+	RequestHold  = "hold"  // place a hold (e.g. for a checked out item)
+	RequestScan  = "scan"  // scan and deliver (e.g. a chapter or an article)
+)
+
+var requestTypes = []string{RequestAnnex, RequestHold, RequestScan}
+
+// RequestRule allows (or denies) a type of request for the items that
+// match all the conditions in the rule. Empty conditions match any item.
+// Locations are matched as prefixes (e.g. "qh" matches "qhs") and
+// buildings are the names returned by sierra.Item.BuildingName.
+type RequestRule struct {
+	Request   string   `json:"request"` // annex, hold, or scan
+	Allow     bool     `json:"allow"`
+	Locations []string `json:"locations"`
+	Statuses  []string `json:"statuses"`
+	Itypes    []string `json:"itypes"`
+	Buildings []string `json:"buildings"`
+	Note      string   `json:"note"`
+}
+
+// RequestRules decides what requests are allowed for an item. For each
+// type of request the first rule that matches the item wins, if no rule
+// matches the request is not allowed.
+type RequestRules struct {
+	rules []RequestRule
+}
+
+var requestRulesCache = map[string]*RequestRules{}
+var requestRulesMutex sync.Mutex
+
+// requestRules returns the request rules for the given settings. The rules
+// file is loaded once per process. If the file cannot be loaded we log the
+// error and no requests are allowed.
+func requestRules(settings Settings) *RequestRules {
+	requestRulesMutex.Lock()
+	defer requestRulesMutex.Unlock()
+	rules, ok := requestRulesCache[settings.RequestRulesFile]
+	if !ok {
+		var err error
+		rules, err = NewRequestRules(settings)
+		if err != nil {
+			log.Printf("ERROR loading request rules from %s: %s", settings.RequestRulesFile, err)
+			rules = &RequestRules{}
+		}
+		requestRulesCache[settings.RequestRulesFile] = rules
+	}
+	return rules
+}
+
+// NewRequestRules loads the request rules indicated in the settings.
+func NewRequestRules(settings Settings) (*RequestRules, error) {
+	if settings.RequestRulesFile == "" {
+		return &RequestRules{}, nil
+	}
+	rules, err := LoadRequestRules(settings.RequestRulesFile)
+	if err != nil {
+		return nil, err
+	}
+	return &RequestRules{rules: rules}, nil
+}
+
+// LoadRequestRules loads the rules from a JSON file (an array of
+// RequestRule), for example:
+//
+//	[
+//	  {"request": "annex", "allow": true, "buildings": ["Annex"], "statuses": ["-"]},
+//	  {"request": "hold", "allow": false, "itypes": ["14"], "note": "reserves"},
+//	  {"request": "hold", "allow": true, "statuses": ["-", "m"]}
+//	]
+func LoadRequestRules(filename string) ([]RequestRule, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	rules := []RequestRule{}
+	err = json.Unmarshal(bytes, &rules)
+	if err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if !in(requestTypes, rule.Request) {
+			return nil, fmt.Errorf("Invalid request rule #%d: request must be one of %s", i+1, strings.Join(requestTypes, ", "))
+		}
+	}
+	return rules, nil
+}
+
+// Allowed returns the types of requests allowed for the item.
+func (r *RequestRules) Allowed(item sierra.Item) []string {
+	allowed := []string{}
+	for _, request := range requestTypes {
+		for _, rule := range r.rules {
+			if rule.Request == request && rule.matches(item) {
+				if rule.Allow {
+					allowed = append(allowed, request)
+				}
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+func (rule RequestRule) matches(item sierra.Item) bool {
+	if len(rule.Locations) > 0 && !matchesPrefix(rule.Locations, item.LocationCode()) {
+		return false
+	}
+	if len(rule.Statuses) > 0 && !in(rule.Statuses, item.StatusCode()) {
+		return false
+	}
+	if len(rule.Itypes) > 0 && !in(rule.Itypes, item.Itype()) {
+		return false
+	}
+	if len(rule.Buildings) > 0 && !inFold(rule.Buildings, item.BuildingName()) {
+		return false
+	}
+	return true
+}
+
+func matchesPrefix(prefixes []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func inFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// itemsSummary returns the availability of the items by building, e.g.
+// "Rockefeller: 2 of 3 available". Buildings are listed in the order in
+// which they appear in the items.
+func itemsSummary(items []sierra.Item) []string {
+	buildings := []string{}
+	total := map[string]int{}
+	available := map[string]int{}
+	for _, item := range items {
+		building := item.BuildingName()
+		if building == "" {
+			building = item.LocationName()
+		}
+		if _, ok := total[building]; !ok {
+			buildings = append(buildings, building)
+		}
+		total[building]++
+		if item.IsAvailable() {
+			available[building]++
+		}
+	}
+
+	summary := []string{}
+	for _, building := range buildings {
+		summary = append(summary, fmt.Sprintf("%s: %d of %d available", building, available[building], total[building]))
+	}
+	return summary
+}
@@ -0,0 +1,118 @@
+package josiah
+
+import (
+	"bibService/pkg/sierra"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testItem(location, status, itype string) sierra.Item {
+	return sierra.Item{
+		Location:    map[string]string{"code": location, "name": location},
+		Status:      map[string]string{"code": status},
+		FixedFields: map[string]sierra.FixedField{"61": {Value: itype}},
+	}
+}
+
+func TestRequestRules(t *testing.T) {
+	rules := RequestRules{rules: []RequestRule{
+		{Request: RequestAnnex, Allow: true, Buildings: []string{"annex"}, Statuses: []string{"-"}},
+		{Request: RequestHold, Allow: false, Itypes: []string{"14"}},
+		{Request: RequestHold, Allow: true, Locations: []string{"r"}},
+		{Request: RequestScan, Allow: true, Locations: []string{"qh", "rstk"}},
+	}}
+
+	tests := []struct {
+		item     sierra.Item
+		expected string
+	}{
+		{testItem("qhs", "-", "0"), "annex scan"},
+		{testItem("qhs", "m", "0"), "scan"},
+		{testItem("rstk", "-", "0"), "hold scan"},
+		{testItem("rstk", "-", "14"), "scan"},
+		{testItem("sci", "-", "0"), ""},
+	}
+	for _, test := range tests {
+		allowed := strings.Join(rules.Allowed(test.item), " ")
+		if allowed != test.expected {
+			t.Errorf("Unexpected requests for %s/%s/%s: %q", test.item.LocationCode(), test.item.StatusCode(), test.item.Itype(), allowed)
+		}
+	}
+
+	// without rules nothing is requestable
+	empty := RequestRules{}
+	if len(empty.Allowed(testItem("qhs", "-", "0"))) != 0 {
+		t.Errorf("Request allowed without rules")
+	}
+}
+
+func TestLoadRequestRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "rules.json")
+	ioutil.WriteFile(filename, []byte(`[{"request": "annex", "allow": true, "locations": ["q"]}]`), 0644)
+	rules, err := LoadRequestRules(filename)
+	if err != nil || len(rules) != 1 || rules[0].Locations[0] != "q" {
+		t.Errorf("Unexpected rules: %v, %v", rules, err)
+	}
+
+	ioutil.WriteFile(filename, []byte(`[{"request": "deliver", "allow": true}]`), 0644)
+	if _, err = LoadRequestRules(filename); err == nil {
+		t.Errorf("Invalid request type was accepted")
+	}
+}
+
+func TestItemsPagination(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "rules.json")
+	ioutil.WriteFile(filename, []byte(`[{"request": "annex", "allow": true, "buildings": ["Annex"], "statuses": ["-"]}]`), 0644)
+
+	settings := Settings{RequestRulesFile: filename, ItemsPageSize: 2, RootURL: "https://example.edu/bibutils/"}
+	model := BibModel{settings: settings, cache: NewResponseCache(settings)}
+	sierraItems := []sierra.Item{}
+	for i := 0; i < 3; i++ {
+		item := testItem("rstk", "-", "0")
+		item.Barcode = fmt.Sprintf("3123600%d", i)
+		sierraItems = append(sierraItems, item)
+	}
+	sierraItems = append(sierraItems, testItem("qhs", "-", "0"))
+	sierraItems[1].Status["duedate"] = "2020-10-01T08:00:00Z"
+	model.cache.Set(CacheBib, "1000001", "", sierra.Bib{Id: "1000001"})
+	model.cache.Set(CacheItems, "1000001", "", sierraItems)
+
+	items, err := model.Items("b1000001", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items.Items) != 2 || !items.HasMore || items.MoreLink != "https://example.edu/bibutils/item/?bib=b1000001&offset=2" {
+		t.Errorf("Unexpected page: %d items, %v, %s", len(items.Items), items.HasMore, items.MoreLink)
+	}
+	if !items.Requestable || strings.Join(items.Requests, " ") != RequestAnnex {
+		t.Errorf("Requests not computed for all items: %v", items.Requests)
+	}
+	expected := "Rockefeller: 2 of 3 available|Annex: 1 of 1 available"
+	if strings.Join(items.Summary, "|") != expected {
+		t.Errorf("Unexpected summary: %v", items.Summary)
+	}
+
+	items, err = model.Items("b1000001", 2)
+	if err != nil || len(items.Items) != 2 || items.HasMore || items.MoreLink != "" {
+		t.Errorf("Unexpected last page: %d items, %v, %s", len(items.Items), items.HasMore, items.MoreLink)
+	}
+	if strings.Join(items.Items[1].Requests, " ") != RequestAnnex || len(items.Items[0].Requests) != 0 {
+		t.Errorf("Unexpected requests per item: %v %v", items.Items[0].Requests, items.Items[1].Requests)
+	}
+}
@@ -0,0 +1,245 @@
+package josiah
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Name of the file (under CachedDataPath) where we keep the status of the
+// scheduled tasks.
+const scheduleStateFile = "schedule_state.json"
+
+// Client name recorded for the jobs started by the scheduler.
+const schedulerClient = "scheduler"
+
+// ScheduledTask is a job that runs on a schedule. For example:
+//
+//	{ "name": "nightly-delete", "cron": "0 2 * * *", "job": "solrDelete", "params": { "days": "3" } }
+type ScheduledTask struct {
+	Name   string            `json:"name"`
+	Cron   string            `json:"cron"`
+	Job    string            `json:"job"` // job type (see JobRunner)
+	Params map[string]string `json:"params"`
+}
+
+// TaskStatus has the information about the last run of a scheduled task.
+type TaskStatus struct {
+	Name        string     `json:"name"`
+	Cron        string     `json:"cron"`
+	Job         string     `json:"job"`
+	NextRun     *time.Time `json:"nextRun,omitempty"`
+	Running     bool       `json:"running"`
+	LastRun     *time.Time `json:"lastRun,omitempty"`
+	LastJobID   string     `json:"lastJobId,omitempty"`
+	LastState   string     `json:"lastState,omitempty"` // completed or failed
+	LastError   string     `json:"lastError,omitempty"`
+	LastSkipped *time.Time `json:"lastSkipped,omitempty"` // last time it did not run because a job of the same type was still running
+	SkippedRuns int        `json:"skippedRuns"`
+}
+
+type scheduledTask struct {
+	task ScheduledTask
+	cron CronSchedule
+}
+
+// Scheduler runs the scheduled tasks indicated in the settings as jobs.
+// A task is not started again if its previous run (or any other job of the
+// same type) has not finished.
+type Scheduler struct {
+	mutex    sync.Mutex
+	runner   *JobRunner
+	tasks    []scheduledTask
+	status   map[string]*TaskStatus
+	filename string
+}
+
+// NewScheduler validates the tasks in the settings and loads the status
+// of their previous runs.
+func NewScheduler(settings Settings, runner *JobRunner) (*Scheduler, error) {
+	s := &Scheduler{runner: runner, status: map[string]*TaskStatus{}}
+	if settings.CachedDataPath != "" {
+		s.filename = filepath.Join(settings.CachedDataPath, scheduleStateFile)
+	}
+
+	jobTypes := runner.Types()
+	for _, task := range settings.Schedule {
+		if task.Name == "" {
+			return nil, fmt.Errorf("Scheduled task without a name (job %s)", task.Job)
+		}
+		if _, exists := s.status[task.Name]; exists {
+			return nil, fmt.Errorf("Duplicated scheduled task name: %s", task.Name)
+		}
+		if !in(jobTypes, task.Job) {
+			return nil, fmt.Errorf("Unknown job type %q for scheduled task %s", task.Job, task.Name)
+		}
+		cron, err := ParseCron(task.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("Scheduled task %s: %s", task.Name, err)
+		}
+		s.tasks = append(s.tasks, scheduledTask{task: task, cron: cron})
+		s.status[task.Name] = &TaskStatus{Name: task.Name, Cron: task.Cron, Job: task.Job}
+	}
+
+	err := s.load()
+	return s, err
+}
+
+// Start runs the scheduler in the background. Tasks are checked at the
+// beginning of every minute.
+func (s *Scheduler) Start() {
+	if len(s.tasks) == 0 {
+		return
+	}
+	for _, t := range s.tasks {
+		log.Printf("Scheduled task %s (%s): %s %v", t.task.Name, t.cron, t.task.Job, t.task.Params)
+	}
+	go func() {
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			time.Sleep(next.Sub(now))
+			s.tick(next)
+		}
+	}()
+}
+
+// Status returns the status of each scheduled task.
+func (s *Scheduler) Status() []TaskStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	list := []TaskStatus{}
+	for _, t := range s.tasks {
+		status := *s.status[t.task.Name]
+		next := t.cron.Next(now)
+		if !next.IsZero() {
+			status.NextRun = &next
+		}
+		list = append(list, status)
+	}
+	return list
+}
+
+// tick starts the tasks due at the given time.
+func (s *Scheduler) tick(t time.Time) {
+	for _, task := range s.tasks {
+		if task.cron.Matches(t) {
+			s.runTask(task.task, t)
+		}
+	}
+}
+
+// runTask starts the job for the task (unless the previous run is still
+// running) and records its outcome when it finishes.
+func (s *Scheduler) runTask(task ScheduledTask, t time.Time) {
+	s.mutex.Lock()
+	status := s.status[task.Name]
+	if status.Running {
+		status.LastSkipped = &t
+		status.SkippedRuns++
+		s.saveLocked()
+		s.mutex.Unlock()
+		log.Printf("Skipped scheduled task %s: previous run (job %s) is still running", task.Name, status.LastJobID)
+		return
+	}
+
+	job, err := s.runner.Submit(task.Job, copyParams(task.Params), schedulerClient)
+	var conflict JobConflictError
+	if errors.As(err, &conflict) {
+		// e.g. the same job was started by hand
+		status.LastSkipped = &t
+		status.SkippedRuns++
+		s.saveLocked()
+		s.mutex.Unlock()
+		log.Printf("Skipped scheduled task %s: %s", task.Name, err)
+		return
+	}
+	status.LastRun = &t
+	if err != nil {
+		status.LastJobID = ""
+		status.LastState = JobFailed
+		status.LastError = err.Error()
+		s.saveLocked()
+		s.mutex.Unlock()
+		log.Printf("ERROR starting scheduled task %s: %s", task.Name, err)
+		return
+	}
+	status.Running = true
+	status.LastJobID = job.ID
+	status.LastState = ""
+	status.LastError = ""
+	s.saveLocked()
+	s.mutex.Unlock()
+
+	go func() {
+		job, _ := s.runner.Wait(job.ID)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		status.Running = false
+		status.LastState = job.State
+		status.LastError = job.Error
+		s.saveLocked()
+	}()
+}
+
+// load fetches the status of the previous runs. Tasks that were running
+// when the process stopped are not running anymore.
+func (s *Scheduler) load() error {
+	if s.filename == "" {
+		return nil
+	}
+	bytes, err := ioutil.ReadFile(s.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	saved := map[string]TaskStatus{}
+	err = json.Unmarshal(bytes, &saved)
+	if err != nil {
+		return err
+	}
+	for name, status := range s.status {
+		prev, found := saved[name]
+		if !found {
+			continue
+		}
+		status.LastRun = prev.LastRun
+		status.LastJobID = prev.LastJobID
+		status.LastState = prev.LastState
+		status.LastError = prev.LastError
+		status.LastSkipped = prev.LastSkipped
+		status.SkippedRuns = prev.SkippedRuns
+		if prev.Running {
+			status.LastState = JobFailed
+			status.LastError = "Task was interrupted (the service was restarted)"
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) saveLocked() {
+	if s.filename == "" {
+		return
+	}
+	err := saveJSON(s.filename, s.status)
+	if err != nil {
+		log.Printf("ERROR saving schedule state: %s", err)
+	}
+}
+
+func copyParams(params map[string]string) map[string]string {
+	copy := map[string]string{}
+	for key, value := range params {
+		copy[key] = value
+	}
+	return copy
+}
@@ -0,0 +1,120 @@
+package josiah
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewSchedulerInvalid(t *testing.T) {
+	runner, _ := NewJobRunner(Settings{})
+	runner.Register("ok", func(jc *JobContext) error { return nil })
+
+	invalid := [][]ScheduledTask{
+		{{Name: "", Cron: "* * * * *", Job: "ok"}},
+		{{Name: "a", Cron: "* * * *", Job: "ok"}},
+		{{Name: "a", Cron: "* * * * *", Job: "unknown"}},
+		{{Name: "a", Cron: "* * * * *", Job: "ok"}, {Name: "a", Cron: "0 * * * *", Job: "ok"}},
+	}
+	for _, tasks := range invalid {
+		if _, err := NewScheduler(Settings{Schedule: tasks}, runner); err == nil {
+			t.Errorf("Expected error for tasks: %#v", tasks)
+		}
+	}
+}
+
+func TestSchedulerTick(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	runner, _ := NewJobRunner(Settings{})
+	release := make(chan bool)
+	runner.Register("slow", func(jc *JobContext) error {
+		<-release
+		return nil
+	})
+	settings := Settings{
+		CachedDataPath: dir,
+		Schedule:       []ScheduledTask{{Name: "task1", Cron: "0 2 * * *", Job: "slow"}},
+	}
+	scheduler, err := NewScheduler(settings, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Not due
+	scheduler.tick(time.Date(2020, 3, 2, 2, 1, 0, 0, time.UTC))
+	if status := scheduler.Status()[0]; status.LastRun != nil {
+		t.Errorf("Task ran when it was not due: %#v", status)
+	}
+
+	// Due
+	day1 := time.Date(2020, 3, 2, 2, 0, 0, 0, time.UTC)
+	scheduler.tick(day1)
+	status := scheduler.Status()[0]
+	if !status.Running || status.LastJobID == "" || !status.LastRun.Equal(day1) {
+		t.Errorf("Task did not run: %#v", status)
+	}
+
+	// Due again while still running
+	scheduler.tick(day1.AddDate(0, 0, 1))
+	status = scheduler.Status()[0]
+	if status.SkippedRuns != 1 || !status.LastRun.Equal(day1) {
+		t.Errorf("Task was not skipped: %#v", status)
+	}
+
+	close(release)
+	runner.Wait(status.LastJobID)
+	for i := 0; i < 100 && scheduler.Status()[0].Running; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	status = scheduler.Status()[0]
+	if status.Running || status.LastState != JobCompleted {
+		t.Errorf("Task outcome not recorded: %#v", status)
+	}
+
+	// The status survives restarts
+	scheduler2, _ := NewScheduler(settings, runner)
+	status2 := scheduler2.Status()[0]
+	if status2.LastJobID != status.LastJobID || status2.LastState != JobCompleted || status2.SkippedRuns != 1 {
+		t.Errorf("Task status was not persisted: %#v", status2)
+	}
+}
+
+func TestSchedulerSkipsWhileJobRunning(t *testing.T) {
+	runner, _ := NewJobRunner(Settings{})
+	release := make(chan bool)
+	runner.Register("solrSync", func(jc *JobContext) error {
+		<-release
+		return nil
+	})
+	settings := Settings{Schedule: []ScheduledTask{{Name: "sync", Cron: "0 * * * *", Job: "solrSync"}}}
+	scheduler, err := NewScheduler(settings, runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a job of the same type started by hand is still running
+	manual, err := runner.Submit("solrSync", nil, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Date(2020, 3, 2, 2, 0, 0, 0, time.UTC)
+	scheduler.tick(due)
+	status := scheduler.Status()[0]
+	if status.SkippedRuns != 1 || status.LastRun != nil || status.Running || len(runner.List()) != 1 {
+		t.Errorf("Task ran while a job of the same type was running: %#v", status)
+	}
+
+	close(release)
+	runner.Wait(manual.ID)
+	scheduler.tick(due.Add(time.Hour))
+	if status = scheduler.Status()[0]; status.LastRun == nil || status.LastJobID == "" {
+		t.Errorf("Task did not run after the manual job finished: %#v", status)
+	}
+	runner.Wait(status.LastJobID)
+}
@@ -1,37 +1,65 @@
 package josiah
 
 import (
+	"bibService/pkg/sierra"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
 )
 
 // Settings represents a shared set of values for all models including
 // information about how to connect to Sierra's API, the Sierra database,
 // or our Solr server.
 type Settings struct {
-	ServerAddress    string `json:"serverAddress"`
-	SessionFile      string `json:"sessionFile"`
-	SierraURL        string `json:"sierraUrl"`
-	KeySecret        string `json:"keySecret"`
-	Verbose          bool   `json:"verbose"`
-	SolrURL          string `json:"solrUrl"`
-	RootURL          string `json:"rootUrl"`
-	CachedDataPath   string `json:"cachedDataPath"`
-	DbUser           string `json:"dbUser"`           // Sierra Postgres DB
-	DbPassword       string `json:"dbPassword"`       // Sierra Postgres DB
-	DbHost           string `json:"dbHost"`           // Sierra Postgres DB
-	DbPort           int    `json:"dbPort"`           // Sierra Postgres DB
-	DbName           string `json:"dbName"`           // Sierra Postgres DB
-	JosiahDbHost     string `json:"josiahDbHost"`     // Josiah MySQL DB
-	JosiahDbUser     string `json:"josiahDbUser"`     // Josiah MySQL DB
-	JosiahDbPassword string `json:"josiahDbPassword"` // Josiah MySQL DB
-	JosiahDbName     string `json:"josiahDbName"`     // Josiah MySQL DB
-	BestBetsAPIKey   string `json:"bbApiKey"`         // Google API key to access the BestBets document
-	BestBetsDocID    string `json:"bbDocID"`          // ID of the Google Sheet with the BestBets data
-	BestBetsSolrURL  string `json:"bbSolrUrl"`        // Best Bets Solr URL
-}
-
-// LoadSettings fetches settings information from a JSON file.
+	ServerAddress        string          `json:"serverAddress"`
+	SessionFile          string          `json:"sessionFile"`
+	SierraURL            string          `json:"sierraUrl"`
+	KeySecret            string          `json:"keySecret"`
+	Verbose              bool            `json:"verbose"`
+	SolrURL              string          `json:"solrUrl"`
+	RootURL              string          `json:"rootUrl"`
+	CachedDataPath       string          `json:"cachedDataPath"`
+	DbUser               string          `json:"dbUser"`               // Sierra Postgres DB
+	DbPassword           string          `json:"dbPassword"`           // Sierra Postgres DB
+	DbHost               string          `json:"dbHost"`               // Sierra Postgres DB
+	DbPort               int             `json:"dbPort"`               // Sierra Postgres DB
+	DbName               string          `json:"dbName"`               // Sierra Postgres DB
+	JosiahDbHost         string          `json:"josiahDbHost"`         // Josiah MySQL DB
+	JosiahDbUser         string          `json:"josiahDbUser"`         // Josiah MySQL DB
+	JosiahDbPassword     string          `json:"josiahDbPassword"`     // Josiah MySQL DB
+	JosiahDbName         string          `json:"josiahDbName"`         // Josiah MySQL DB
+	BestBetsAPIKey       string          `json:"bbApiKey"`             // Google API key to access the BestBets document
+	BestBetsDocID        string          `json:"bbDocID"`              // ID of the Google Sheet with the BestBets data
+	BestBetsSolrURL      string          `json:"bbSolrUrl"`            // Best Bets Solr URL
+	SierraMaxRetries     int             `json:"sierraMaxRetries"`     // Times to retry a failed Sierra API call
+	SierraRetryDelay     int             `json:"sierraRetryDelay"`     // Seconds to wait before the first retry (doubles on each retry)
+	SierraMaxDelay       int             `json:"sierraMaxDelay"`       // Maximum seconds to wait between retries
+	SierraRateLimitDelay int             `json:"sierraRateLimitDelay"` // Seconds that jobs wait when Sierra reports "Rate exceeded" (default 960, -1 to use the regular delay)
+	SolrMaxDeletes       int             `json:"solrMaxDeletes"`       // Max documents to delete from Solr in one run (default 5000, -1 for no limit)
+	SolrMaxDeletePercent float64         `json:"solrMaxDeletePercent"` // Max percentage of the documents in Solr to delete in one run (default 5, -1 for no limit)
+	BestBetsMinRows      int             `json:"bbMinRows"`            // Min rows in the BestBets sheet to replace all BestBets in Solr (default 100)
+	AuditLogFile         string          `json:"auditLogFile"`         // Defaults to audit_log.jsonl under cachedDataPath
+	AuditLogMaxSize      int             `json:"auditLogMaxSize"`      // MB before the audit log is rotated (default 100, -1 to never rotate)
+	APIClients           []APIClient     `json:"apiClients"`           // Clients allowed to call the web service
+	Schedule             []ScheduledTask `json:"schedule"`             // Tasks that the web server runs on a schedule
+	CacheTTL             map[string]int  `json:"cacheTtl"`             // Seconds to cache Sierra responses by resource (bib, items, marc, holdings), -1 to disable
+	CacheMemoryEntries   int             `json:"cacheMemoryEntries"`   // Cached responses kept in memory (default 1000, -1 for disk only)
+	ShelfRangesFile      string          `json:"shelfRangesFile"`      // CSV or JSON file with the call number ranges for each floor/aisle
+	ShelfMapURL          string          `json:"shelfMapUrl"`          // Template for the URL of the map of a shelf (see ShelfLocator.MapURL)
+	RequestRulesFile     string          `json:"requestRulesFile"`     // JSON file with the rules for Annex, hold, and scan requests (see RequestRule)
+	ItemsPageSize        int             `json:"itemsPageSize"`        // Max items returned per request for a BIB (default 100, -1 for no limit)
+	LocationsFromDb      bool            `json:"locationsFromDb"`      // Load the location/building mappings from the Sierra DB
+	LocationsFile        string          `json:"locationsFile"`        // CSV or JSON file with location/building mappings (overrides the ones in the DB)
+}
+
+// LoadSettings fetches settings information from a JSON file. Secrets
+// (e.g. passwords) can be provided via environment variables instead of
+// the JSON file (see secrets).
 func LoadSettings(filename string) (Settings, error) {
 	bytes, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -40,5 +68,244 @@ func LoadSettings(filename string) (Settings, error) {
 
 	var settings Settings
 	err = json.Unmarshal(bytes, &settings)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	err = settings.loadSecrets(os.LookupEnv)
 	return settings, err
 }
+
+// CacheTTLs returns how long to cache the Sierra responses for each
+// resource. Resources not indicated in the settings take the default
+// values and resources with a negative value are not cached.
+func (settings Settings) CacheTTLs() map[string]time.Duration {
+	ttls := map[string]time.Duration{}
+	for resource, ttl := range defaultCacheTTLs {
+		ttls[resource] = ttl
+		if seconds, ok := settings.CacheTTL[resource]; ok && seconds != 0 {
+			ttls[resource] = time.Duration(seconds) * time.Second
+		}
+	}
+	return ttls
+}
+
+// ItemsLimit returns the max number of items to return per request for a
+// BIB, zero means no limit.
+func (settings Settings) ItemsLimit() int {
+	if settings.ItemsPageSize < 0 {
+		return 0
+	}
+	if settings.ItemsPageSize == 0 {
+		return defaultItemsPageSize
+	}
+	return settings.ItemsPageSize
+}
+
+// AuditLogMaxBytes returns the size at which the audit log is rotated,
+// zero means never.
+func (settings Settings) AuditLogMaxBytes() int64 {
+	if settings.AuditLogMaxSize < 0 {
+		return 0
+	}
+	if settings.AuditLogMaxSize == 0 {
+		return defaultAuditLogMaxSize * 1024 * 1024
+	}
+	return int64(settings.AuditLogMaxSize) * 1024 * 1024
+}
+
+// Validate returns the problems found in the settings, for example
+// required values that are missing or values that are inconsistent with
+// each other. An empty list means the settings are OK.
+func (settings Settings) Validate() []string {
+	problems := []string{}
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Sierra API
+	if settings.SierraURL == "" {
+		add("sierraUrl is missing")
+	} else if !isHTTPURL(settings.SierraURL) {
+		add("sierraUrl is not a valid URL: %s", settings.SierraURL)
+	}
+	if settings.KeySecret == "" {
+		add("keySecret is missing (set it in the settings file or in BIBSERVICE_KEY_SECRET)")
+	} else if !strings.Contains(settings.KeySecret, ":") {
+		add("keySecret must be in the form key:secret")
+	}
+	if settings.SierraMaxRetries < 0 || settings.SierraRetryDelay < 0 || settings.SierraMaxDelay < 0 {
+		add("sierraMaxRetries, sierraRetryDelay, and sierraMaxDelay cannot be negative")
+	}
+	if settings.AuditLogMaxSize < -1 {
+		add("auditLogMaxSize must be -1 (never rotate), 0 (default), or a number of MB")
+	}
+	if settings.SierraRateLimitDelay < -1 {
+		add("sierraRateLimitDelay must be -1 (use the regular delay), 0 (default), or a number of seconds")
+	}
+	if settings.SierraMaxDelay > 0 && settings.SierraMaxDelay < settings.SierraRetryDelay {
+		add("sierraMaxDelay (%d) is less than sierraRetryDelay (%d)", settings.SierraMaxDelay, settings.SierraRetryDelay)
+	}
+
+	// Solr
+	if settings.SolrURL == "" {
+		add("solrUrl is missing")
+	} else if !isHTTPURL(settings.SolrURL) {
+		add("solrUrl is not a valid URL: %s", settings.SolrURL)
+	}
+	if settings.BestBetsSolrURL != "" && !isHTTPURL(settings.BestBetsSolrURL) {
+		add("bbSolrUrl is not a valid URL: %s", settings.BestBetsSolrURL)
+	}
+	if settings.SolrMaxDeletes < -1 {
+		add("solrMaxDeletes must be -1 (no limit), 0 (default), or a positive number")
+	}
+	if settings.SolrMaxDeletePercent < -1 || settings.SolrMaxDeletePercent > 100 {
+		add("solrMaxDeletePercent must be -1 (no limit), 0 (default), or a percentage")
+	}
+
+	// Databases: optional, but if configured they must be complete.
+	sierraDb := map[string]string{"dbHost": settings.DbHost, "dbUser": settings.DbUser,
+		"dbPassword": settings.DbPassword, "dbName": settings.DbName}
+	if anySet(sierraDb) {
+		for _, name := range missing(sierraDb) {
+			add("%s is missing (required to connect to the Sierra database)", name)
+		}
+		if settings.DbPort <= 0 {
+			add("dbPort is missing (required to connect to the Sierra database)")
+		}
+	}
+	josiahDb := map[string]string{"josiahDbUser": settings.JosiahDbUser,
+		"josiahDbPassword": settings.JosiahDbPassword, "josiahDbName": settings.JosiahDbName}
+	if anySet(josiahDb) || settings.JosiahDbHost != "" {
+		for _, name := range missing(josiahDb) {
+			add("%s is missing (required to connect to the Josiah database)", name)
+		}
+	}
+
+	// BestBets
+	bestBets := map[string]string{"bbApiKey": settings.BestBetsAPIKey, "bbDocID": settings.BestBetsDocID}
+	if anySet(bestBets) {
+		for _, name := range missing(bestBets) {
+			add("%s is missing (required to fetch the BestBets)", name)
+		}
+	}
+
+	// API clients
+	names := map[string]bool{}
+	keys := map[string]bool{}
+	validRoles := []string{RoleCatalog, RolePatron, RoleAdmin, RoleMonitor}
+	for i, client := range settings.APIClients {
+		if client.Name == "" {
+			add("apiClients[%d] has no name", i)
+		} else if names[client.Name] {
+			add("apiClients has more than one client named %s", client.Name)
+		}
+		names[client.Name] = true
+		if client.Key == "" && client.Secret == "" {
+			add("apiClients %s has no key or secret", client.Name)
+		}
+		if client.Key != "" && keys[client.Key] {
+			add("apiClients %s uses the same key as another client", client.Name)
+		}
+		keys[client.Key] = true
+		for _, role := range client.Roles {
+			if !in(validRoles, role) {
+				add("apiClients %s has an unknown role: %s", client.Name, role)
+			}
+		}
+	}
+
+	for resource := range settings.CacheTTL {
+		if _, ok := defaultCacheTTLs[resource]; !ok {
+			add("cacheTtl has an unknown resource: %s (valid values are bib, items, marc, and holdings)", resource)
+		}
+	}
+
+	if settings.ShelfRangesFile != "" {
+		if _, err := LoadShelfRanges(settings.ShelfRangesFile); err != nil {
+			add("shelfRangesFile cannot be loaded: %s", err)
+		}
+	}
+	if settings.ShelfMapURL != "" && !isHTTPURL(settings.ShelfMapURL) {
+		add("shelfMapUrl is not a valid URL: %s", settings.ShelfMapURL)
+	}
+
+	if settings.RequestRulesFile != "" {
+		if _, err := LoadRequestRules(settings.RequestRulesFile); err != nil {
+			add("requestRulesFile cannot be loaded: %s", err)
+		}
+	}
+
+	if settings.LocationsFile != "" {
+		if _, err := LoadLocationsFile(settings.LocationsFile); err != nil {
+			add("locationsFile cannot be loaded: %s", err)
+		}
+	}
+	if settings.LocationsFromDb && settings.DbHost == "" {
+		add("locationsFromDb requires the Sierra DB settings (dbHost, dbUser, dbPassword, dbName)")
+	}
+
+	if len(settings.Schedule) > 0 && settings.CachedDataPath == "" {
+		add("cachedDataPath is missing (required to keep track of the scheduled tasks)")
+	}
+	return problems
+}
+
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func anySet(values map[string]string) bool {
+	for _, value := range values {
+		if value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// missing returns the names (sorted) of the values that are empty.
+func missing(values map[string]string) []string {
+	names := []string{}
+	for name, value := range values {
+		if value == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RetryPolicy returns the policy to use when retrying failed Sierra API calls
+// for interactive requests. Values not indicated in the settings take the
+// default values.
+func (settings Settings) RetryPolicy() sierra.RetryPolicy {
+	return settings.retryPolicy(sierra.DefaultRetryPolicy())
+}
+
+// BatchRetryPolicy returns the policy to use when retrying failed Sierra API
+// calls for jobs and command line actions. Unlike RetryPolicy calls that hit
+// the rate limit wait for it to reset (sierraRateLimitDelay).
+func (settings Settings) BatchRetryPolicy() sierra.RetryPolicy {
+	policy := settings.retryPolicy(sierra.BatchRetryPolicy())
+	if settings.SierraRateLimitDelay > 0 {
+		policy.RateLimitDelay = time.Duration(settings.SierraRateLimitDelay) * time.Second
+	} else if settings.SierraRateLimitDelay == -1 {
+		policy.RateLimitDelay = 0
+	}
+	return policy
+}
+
+func (settings Settings) retryPolicy(policy sierra.RetryPolicy) sierra.RetryPolicy {
+	if settings.SierraMaxRetries > 0 {
+		policy.MaxRetries = settings.SierraMaxRetries
+	}
+	if settings.SierraRetryDelay > 0 {
+		policy.InitialDelay = time.Duration(settings.SierraRetryDelay) * time.Second
+	}
+	if settings.SierraMaxDelay > 0 {
+		policy.MaxDelay = time.Duration(settings.SierraMaxDelay) * time.Second
+	}
+	return policy
+}
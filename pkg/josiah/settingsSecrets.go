@@ -0,0 +1,106 @@
+package josiah
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Value shown instead of a secret when the settings are printed.
+const redactedValue = "[REDACTED]"
+
+// settingSecret is a setting that holds a secret and the environment
+// variable that can be used to provide it instead of the JSON file.
+type settingSecret struct {
+	env   string
+	value *string
+}
+
+// secrets returns the secrets in the settings. Each secret can be set via
+// an environment variable (e.g. BIBSERVICE_DB_PASSWORD) or via a file whose
+// path is in the environment variable with the _FILE suffix (e.g.
+// BIBSERVICE_DB_PASSWORD_FILE, handy for Docker/Kubernetes secrets).
+func (settings *Settings) secrets() []settingSecret {
+	secrets := []settingSecret{
+		{env: "BIBSERVICE_KEY_SECRET", value: &settings.KeySecret},
+		{env: "BIBSERVICE_DB_PASSWORD", value: &settings.DbPassword},
+		{env: "BIBSERVICE_JOSIAH_DB_PASSWORD", value: &settings.JosiahDbPassword},
+		{env: "BIBSERVICE_BB_API_KEY", value: &settings.BestBetsAPIKey},
+	}
+	for i := range settings.APIClients {
+		// e.g. BIBSERVICE_CLIENT_JOSIAH_KEY and BIBSERVICE_CLIENT_JOSIAH_SECRET
+		prefix := "BIBSERVICE_CLIENT_" + envName(settings.APIClients[i].Name)
+		secrets = append(secrets,
+			settingSecret{env: prefix + "_KEY", value: &settings.APIClients[i].Key},
+			settingSecret{env: prefix + "_SECRET", value: &settings.APIClients[i].Secret})
+	}
+	return secrets
+}
+
+// loadSecrets replaces the secrets in the settings with the values
+// provided in the environment (if any).
+func (settings *Settings) loadSecrets(lookupEnv func(string) (string, bool)) error {
+	for _, secret := range settings.secrets() {
+		value, inEnv := lookupEnv(secret.env)
+		filename, inFile := lookupEnv(secret.env + "_FILE")
+		if inEnv && inFile {
+			return fmt.Errorf("Both %s and %s_FILE are set, only one of them can be used", secret.env, secret.env)
+		}
+		if inEnv {
+			*secret.value = value
+		}
+		if inFile {
+			bytes, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return fmt.Errorf("Error reading %s_FILE: %s", secret.env, err)
+			}
+			*secret.value = strings.TrimRight(string(bytes), "\r\n")
+		}
+	}
+	return nil
+}
+
+// Redacted returns a copy of the settings with the secrets replaced so
+// that they can be logged.
+func (settings Settings) Redacted() Settings {
+	settings.APIClients = append([]APIClient{}, settings.APIClients...)
+	for _, secret := range settings.secrets() {
+		if *secret.value != "" {
+			*secret.value = redactedValue
+		}
+	}
+	return settings
+}
+
+// plainSettings has the same fields as Settings but not its methods (so
+// that we can print it without calling String/GoString recursively).
+type plainSettings Settings
+
+// String makes sure secrets are not printed when the settings are logged
+// (e.g. with %v or %+v).
+func (settings Settings) String() string {
+	return fmt.Sprintf("%+v", plainSettings(settings.Redacted()))
+}
+
+// GoString makes sure secrets are not printed when the settings are
+// logged with %#v.
+func (settings Settings) GoString() string {
+	value := fmt.Sprintf("%#v", plainSettings(settings.Redacted()))
+	return strings.Replace(value, "josiah.plainSettings", "josiah.Settings", 1)
+}
+
+// envName converts a value to the form used in environment variable
+// names (e.g. "josiah-front end" => "JOSIAH_FRONT_END")
+func envName(value string) string {
+	var chars []rune
+	for _, c := range strings.ToUpper(value) {
+		isAlpha := c >= 'A' && c <= 'Z'
+		isDigit := c >= '0' && c <= '9'
+		if isAlpha || isDigit {
+			chars = append(chars, c)
+		} else {
+			chars = append(chars, '_')
+		}
+	}
+	return string(chars)
+}
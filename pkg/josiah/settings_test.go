@@ -0,0 +1,118 @@
+package josiah
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSettingsRedacted(t *testing.T) {
+	settings := Settings{
+		SierraURL:        "https://sierra/iii/sierra-api/v5",
+		KeySecret:        "key:top-secret",
+		DbPassword:       "db-secret",
+		JosiahDbPassword: "josiah-secret",
+		BestBetsAPIKey:   "bb-secret",
+		APIClients:       []APIClient{{Name: "josiah", Key: "client-key", Secret: "client-secret"}},
+	}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		value := fmt.Sprintf(format, settings)
+		if strings.Contains(value, "secret") || strings.Contains(value, "client-key") {
+			t.Errorf("Secrets not redacted with %s: %s", format, value)
+		}
+		if !strings.Contains(value, "https://sierra/iii/sierra-api/v5") {
+			t.Errorf("Non-secret values should be printed with %s: %s", format, value)
+		}
+	}
+	if !strings.HasPrefix(fmt.Sprintf("%#v", settings), "josiah.Settings{") {
+		t.Errorf("Unexpected type name: %#v", settings)
+	}
+
+	// the original values are not affected
+	if settings.KeySecret != "key:top-secret" || settings.APIClients[0].Key != "client-key" {
+		t.Errorf("Secrets were modified in the original settings")
+	}
+}
+
+func TestSettingsLoadSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "db_password")
+	ioutil.WriteFile(secretFile, []byte("from-file\n"), 0600)
+
+	env := map[string]string{
+		"BIBSERVICE_KEY_SECRET":          "key:from-env",
+		"BIBSERVICE_DB_PASSWORD_FILE":    secretFile,
+		"BIBSERVICE_CLIENT_CRON_JOB_KEY": "cron-key",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	settings := Settings{KeySecret: "key:from-json", JosiahDbPassword: "josiah-json",
+		APIClients: []APIClient{{Name: "cron-job"}}}
+	err = settings.loadSecrets(lookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.KeySecret != "key:from-env" || settings.DbPassword != "from-file" || settings.JosiahDbPassword != "josiah-json" {
+		t.Errorf("Unexpected secrets: %s, %s, %s", settings.KeySecret, settings.DbPassword, settings.JosiahDbPassword)
+	}
+	if settings.APIClients[0].Key != "cron-key" {
+		t.Errorf("Client key not loaded from the environment: %s", settings.APIClients[0].Key)
+	}
+
+	env["BIBSERVICE_DB_PASSWORD"] = "both"
+	if err := settings.loadSecrets(lookupEnv); err == nil {
+		t.Errorf("Expected an error when both the variable and the _FILE variable are set")
+	}
+
+	delete(env, "BIBSERVICE_DB_PASSWORD")
+	env["BIBSERVICE_DB_PASSWORD_FILE"] = filepath.Join(dir, "missing")
+	if err := settings.loadSecrets(lookupEnv); err == nil {
+		t.Errorf("Expected an error when the _FILE does not exist")
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	settings := Settings{
+		SierraURL: "https://sierra/iii/sierra-api/v5",
+		KeySecret: "key:secret",
+		SolrURL:   "http://localhost:8983/solr/catalog",
+	}
+	if problems := settings.Validate(); len(problems) != 0 {
+		t.Errorf("Unexpected problems: %v", problems)
+	}
+
+	settings.KeySecret = "no-colon"
+	settings.DbHost = "sierra-db"
+	settings.BestBetsDocID = "doc"
+	settings.APIClients = []APIClient{
+		{Name: "a", Key: "k1", Roles: []string{"catalog", "superuser"}},
+		{Name: "a", Key: "k1"},
+		{Name: "b"},
+	}
+	expected := []string{
+		"keySecret must be in the form key:secret",
+		"dbName is missing (required to connect to the Sierra database)",
+		"dbPassword is missing (required to connect to the Sierra database)",
+		"dbUser is missing (required to connect to the Sierra database)",
+		"dbPort is missing (required to connect to the Sierra database)",
+		"bbApiKey is missing (required to fetch the BestBets)",
+		"apiClients a has an unknown role: superuser",
+		"apiClients has more than one client named a",
+		"apiClients a uses the same key as another client",
+		"apiClients b has no key or secret",
+	}
+	problems := settings.Validate()
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected problems:\n%s", strings.Join(problems, "\n"))
+	}
+}
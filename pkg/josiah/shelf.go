@@ -0,0 +1,278 @@
+package josiah
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ShelfRange indicates where (floor, aisle, side) the items in a building
+// with call numbers between Begin and End are shelved. Location is
+// optional and restricts the range to the items in Sierra locations that
+// start with that code (e.g. "rstk" for the Rockefeller stacks).
+type ShelfRange struct {
+	Building     string `json:"building"`
+	Location     string `json:"location"`
+	Floor        string `json:"floor"`
+	Aisle        string `json:"aisle"`
+	Side         string `json:"side"`
+	DisplayAisle string `json:"displayAisle"` // defaults to Aisle + Side
+	Begin        string `json:"begin"`        // LC call number
+	End          string `json:"end"`          // LC call number (inclusive)
+	beginKey     string
+	endKey       string
+}
+
+// ShelfLocator finds the shelf for an item based on its building and its
+// LC call number.
+type ShelfLocator struct {
+	ranges []ShelfRange
+	mapURL string
+}
+
+var shelfLocators = map[string]*ShelfLocator{}
+var shelfLocatorsMutex sync.Mutex
+
+// shelfLocator returns the shelf locator for the given settings. The shelf
+// ranges file is loaded once per process. If the file cannot be loaded we
+// log the error and items are not located.
+func shelfLocator(settings Settings) *ShelfLocator {
+	key := settings.ShelfRangesFile + "|" + settings.ShelfMapURL
+
+	shelfLocatorsMutex.Lock()
+	defer shelfLocatorsMutex.Unlock()
+	locator, ok := shelfLocators[key]
+	if !ok {
+		var err error
+		locator, err = NewShelfLocator(settings)
+		if err != nil {
+			log.Printf("ERROR loading shelf ranges from %s: %s", settings.ShelfRangesFile, err)
+			locator = &ShelfLocator{mapURL: settings.ShelfMapURL}
+		}
+		shelfLocators[key] = locator
+	}
+	return locator
+}
+
+// NewShelfLocator loads the shelf ranges indicated in the settings.
+func NewShelfLocator(settings Settings) (*ShelfLocator, error) {
+	locator := &ShelfLocator{mapURL: settings.ShelfMapURL}
+	if settings.ShelfRangesFile == "" {
+		return locator, nil
+	}
+	ranges, err := LoadShelfRanges(settings.ShelfRangesFile)
+	if err != nil {
+		return nil, err
+	}
+	locator.ranges = ranges
+	return locator, nil
+}
+
+// LoadShelfRanges loads the shelf ranges from a JSON file (an array of
+// ShelfRange) or a CSV file with a header row using the same names as the
+// JSON fields, for example:
+//
+//	building,location,floor,aisle,side,begin,end
+//	Rockefeller,,2,12,A,PS1,PS3545.H16
+func LoadShelfRanges(filename string) ([]ShelfRange, error) {
+	var ranges []ShelfRange
+	var err error
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		ranges, err = loadShelfRangesJSON(filename)
+	} else {
+		ranges, err = loadShelfRangesCSV(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ranges {
+		r := &ranges[i]
+		r.beginKey = NormalizeLC(r.Begin)
+		r.endKey = NormalizeLC(r.End)
+		if r.Building == "" || r.beginKey == "" || r.endKey == "" {
+			return nil, fmt.Errorf("Invalid shelf range #%d (%s, %s - %s): building, begin, and end must be valid", i+1, r.Building, r.Begin, r.End)
+		}
+		if r.beginKey > r.endKey {
+			return nil, fmt.Errorf("Invalid shelf range #%d (%s, %s - %s): begin is after end", i+1, r.Building, r.Begin, r.End)
+		}
+		if r.DisplayAisle == "" {
+			r.DisplayAisle = r.Aisle + r.Side
+		}
+	}
+	return ranges, nil
+}
+
+func loadShelfRangesJSON(filename string) ([]ShelfRange, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	ranges := []ShelfRange{}
+	err = json.Unmarshal(bytes, &ranges)
+	return ranges, err
+}
+
+func loadShelfRangesCSV(filename string) ([]ShelfRange, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"building", "begin", "end"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("Column %s is missing in the header", name)
+		}
+	}
+
+	ranges := []ShelfRange{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := func(name string) string {
+			i, ok := columns[strings.ToLower(name)]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		ranges = append(ranges, ShelfRange{
+			Building:     value("building"),
+			Location:     value("location"),
+			Floor:        value("floor"),
+			Aisle:        value("aisle"),
+			Side:         value("side"),
+			DisplayAisle: value("displayAisle"),
+			Begin:        value("begin"),
+			End:          value("end"),
+		})
+	}
+	return ranges, nil
+}
+
+// Locate returns the shelf for an item in the given building and Sierra
+// location with the given call number. Shelf.Located is false when the
+// call number is not an LC call number or it is not in any of the ranges.
+func (locator *ShelfLocator) Locate(building, locationCode, callnumber string) ShelfResp {
+	shelf := ShelfResp{Location: building}
+	key := NormalizeLC(callnumber)
+	if key == "" {
+		return shelf
+	}
+	for _, r := range locator.ranges {
+		if !strings.EqualFold(r.Building, building) {
+			continue
+		}
+		if r.Location != "" && !strings.HasPrefix(strings.ToLower(locationCode), strings.ToLower(r.Location)) {
+			continue
+		}
+		if r.includes(key) {
+			shelf.Floor = r.Floor
+			shelf.Aisle = r.Aisle
+			shelf.Side = r.Side
+			shelf.DisplayAisle = r.DisplayAisle
+			shelf.Located = true
+			return shelf
+		}
+	}
+	return shelf
+}
+
+// includes returns true if the normalized call number is in the range.
+// Notice that the end of a range includes the call numbers that start
+// with it (e.g. "PS3545.H16" includes "PS3545.H16 Z5 1990" but not
+// "PS3545.H165")
+func (r ShelfRange) includes(key string) bool {
+	if key < r.beginKey {
+		return false
+	}
+	if key <= r.endKey {
+		return true
+	}
+	if strings.HasPrefix(key, r.endKey) {
+		next := key[len(r.endKey)]
+		return next == ' ' || next == '.'
+	}
+	return false
+}
+
+// MapURL returns the URL to the map for a located item using the map URL
+// template in the settings. The template can use the placeholders
+// {building}, {floor}, {aisle}, {side}, and {callnumber}, e.g.
+// "https://library.example.edu/maps/?loc={building}&floor={floor}&aisle={aisle}"
+func (locator *ShelfLocator) MapURL(shelf ShelfResp, callnumber string) string {
+	if locator.mapURL == "" || !shelf.Located {
+		return ""
+	}
+	replacer := strings.NewReplacer(
+		"{building}", url.QueryEscape(shelf.Location),
+		"{floor}", url.QueryEscape(shelf.Floor),
+		"{aisle}", url.QueryEscape(shelf.Aisle),
+		"{side}", url.QueryEscape(shelf.Side),
+		"{callnumber}", url.QueryEscape(callnumber),
+	)
+	return replacer.Replace(locator.mapURL)
+}
+
+// Matches an LC call number: class letters, class number, and the rest
+// (cutters, dates, volumes)
+var reLCCallNumber = regexp.MustCompile(`^([A-Z]{1,3})\s*(\d{1,5})(\.\d+)?\s*(.*)$`)
+
+// Matches a cutter (e.g. ".H16") at the beginning of a string
+var reLCCutter = regexp.MustCompile(`^\.?\s*([A-Z])(\d+)\s*`)
+
+// NormalizeLC returns a value for an LC call number that sorts correctly
+// when compared as a string. For example "PS3545.H16 Z5 1990" becomes
+// "PS 03545 H16 Z5 1990" so that it sorts after "PS 00350" (PS350)
+// even though "3545" < "350" as strings. Returns an empty string if the
+// value is not an LC call number.
+func NormalizeLC(callnumber string) string {
+	value := strings.ToUpper(strings.TrimSpace(callnumber))
+	matches := reLCCallNumber.FindStringSubmatch(value)
+	if matches == nil {
+		return ""
+	}
+
+	classNumber, _ := strconv.Atoi(matches[2])
+	key := fmt.Sprintf("%-3s%05d%s", matches[1], classNumber, matches[3])
+
+	// cutters are decimals, so they sort correctly as strings
+	rest := matches[4]
+	for {
+		cutter := reLCCutter.FindStringSubmatch(rest)
+		if cutter == nil {
+			break
+		}
+		key += " " + cutter[1] + cutter[2]
+		rest = rest[len(cutter[0]):]
+	}
+	if rest = strings.Join(strings.Fields(rest), " "); rest != "" {
+		key += " " + rest
+	}
+	return key
+}
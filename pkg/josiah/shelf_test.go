@@ -0,0 +1,108 @@
+package josiah
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeLC(t *testing.T) {
+	tests := map[string]string{
+		"PS3545.H16 Z5 1990": "PS 03545 H16 Z5 1990",
+		"ps350 .a2":          "PS 00350 A2",
+		"QA76.73.G63":        "QA 00076.73 G63",
+		"Microfilm 1234":     "",
+		"":                   "",
+	}
+	for value, expected := range tests {
+		if key := NormalizeLC(value); key != expected {
+			t.Errorf("Unexpected key for %q: %q", value, key)
+		}
+	}
+
+	// class numbers sort numerically
+	if NormalizeLC("PS350") > NormalizeLC("PS3545") {
+		t.Errorf("PS350 sorted after PS3545")
+	}
+}
+
+func TestShelfLocator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shelf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvFile := filepath.Join(dir, "ranges.csv")
+	csv := "building,location,floor,aisle,side,begin,end\n" +
+		"Rockefeller,,2,12,A,PS1,PS3545.H16\n" +
+		"Rockefeller,,2,12,B,PS3545.H17,PZ9999\n" +
+		"Sciences,,B,3,,QA1,QA999\n"
+	if err := ioutil.WriteFile(csvFile, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := Settings{ShelfRangesFile: csvFile, ShelfMapURL: "https://maps.example.edu/?loc={building}&aisle={aisle}&cn={callnumber}"}
+	locator, err := NewShelfLocator(settings)
+	if err != nil {
+		t.Fatalf("Error loading ranges: %s", err)
+	}
+
+	shelf := locator.Locate("Rockefeller", "rstk", "PS3545.H16 Z5 1990")
+	if !shelf.Located || shelf.Floor != "2" || shelf.DisplayAisle != "12A" {
+		t.Errorf("Unexpected shelf: %+v", shelf)
+	}
+	mapURL := locator.MapURL(shelf, "PS3545.H16 Z5 1990")
+	if mapURL != "https://maps.example.edu/?loc=Rockefeller&aisle=12&cn=PS3545.H16+Z5+1990" {
+		t.Errorf("Unexpected map URL: %s", mapURL)
+	}
+
+	// .H165 sorts after .H16 (and before .H17)
+	shelf = locator.Locate("Rockefeller", "rstk", "PS3545.H165")
+	if shelf.Located {
+		t.Errorf("Call number after the end of a range was located: %+v", shelf)
+	}
+	shelf = locator.Locate("Rockefeller", "rstk", "PS3545.H18")
+	if shelf.Side != "B" {
+		t.Errorf("Unexpected shelf: %+v", shelf)
+	}
+
+	shelf = locator.Locate("Rockefeller", "rstk", "QA76.73")
+	if shelf.Located || locator.MapURL(shelf, "QA76.73") != "" {
+		t.Errorf("Item located in the wrong building: %+v", shelf)
+	}
+
+	jsonFile := filepath.Join(dir, "ranges.json")
+	json := `[{"building": "Sciences", "location": "sci", "floor": "B", "aisle": "3", "begin": "QA1", "end": "QA999"}]`
+	if err := ioutil.WriteFile(jsonFile, []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+	locator, err = NewShelfLocator(Settings{ShelfRangesFile: jsonFile})
+	if err != nil {
+		t.Fatalf("Error loading ranges: %s", err)
+	}
+	if shelf = locator.Locate("Sciences", "scist", "QA76.73"); !shelf.Located {
+		t.Errorf("Item not located: %+v", shelf)
+	}
+	if shelf = locator.Locate("Sciences", "rstk", "QA76.73"); shelf.Located {
+		t.Errorf("Item located in the wrong location: %+v", shelf)
+	}
+}
+
+func TestShelfRangesInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shelf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvFile := filepath.Join(dir, "ranges.csv")
+	csv := "building,floor,begin,end\nRockefeller,2,PS9,PS1\n"
+	if err := ioutil.WriteFile(csvFile, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadShelfRanges(csvFile); err == nil {
+		t.Errorf("Range with begin after end was accepted")
+	}
+}
@@ -0,0 +1,41 @@
+package josiah
+
+import (
+	"bibService/pkg/sierra"
+	"sync"
+)
+
+var sierraClients = map[string]*sierra.Sierra{}
+var sierraClientsMutex sync.Mutex
+
+// sierraClient returns the Sierra API client for the given settings.
+//
+// Clients are created once per process (per Sierra URL and credentials) and
+// shared by all the models so that the access token is reused across
+// requests rather than re-read from the session file on every request.
+func sierraClient(settings Settings) *sierra.Sierra {
+	return sharedSierraClient(settings, "", settings.RetryPolicy())
+}
+
+// sierraBatchClient is like sierraClient but for jobs and command line
+// actions. It has its own access token since its requests can wait for
+// several minutes for the rate limit to reset (see Settings.BatchRetryPolicy)
+// and we don't want to hold up interactive requests while they wait.
+func sierraBatchClient(settings Settings) *sierra.Sierra {
+	return sharedSierraClient(settings, "batch", settings.BatchRetryPolicy())
+}
+
+func sharedSierraClient(settings Settings, kind string, policy sierra.RetryPolicy) *sierra.Sierra {
+	key := settings.SierraURL + "|" + settings.KeySecret + "|" + settings.SessionFile + "|" + kind
+
+	sierraClientsMutex.Lock()
+	defer sierraClientsMutex.Unlock()
+	client, ok := sierraClients[key]
+	if !ok {
+		client = sierra.NewSierra(settings.SierraURL, settings.KeySecret, settings.SessionFile)
+		client.Verbose = settings.Verbose
+		client.Retry = policy
+		sierraClients[key] = client
+	}
+	return client
+}
@@ -0,0 +1,120 @@
+package josiah
+
+import (
+	"fmt"
+)
+
+// Default limits for the number of documents deleted from Solr in a
+// single run (see DeleteLimits)
+const (
+	defaultMaxDeletes       = 5000
+	defaultMaxDeletePercent = 5.0
+	defaultBestBetsMinRows  = 100
+)
+
+// SolrDeletePlan has the IDs of the BIB records that would be removed from
+// Solr for a given date range.
+type SolrDeletePlan struct {
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Deleted    []string `json:"deleted"`    // deleted in Sierra
+	Suppressed []string `json:"suppressed"` // suppressed in Sierra
+	SolrCount  int      `json:"solrCount"`  // documents currently in Solr
+	// Indicates why the delete would be aborted (see DeleteLimits)
+	LimitExceeded string `json:"limitExceeded,omitempty"`
+}
+
+// Count returns the total number of IDs to delete.
+func (plan SolrDeletePlan) Count() int {
+	return len(plan.Deleted) + len(plan.Suppressed)
+}
+
+// DeleteLimits indicates the maximum number of documents that we are
+// willing to delete from Solr in a single run. A suspiciously large number
+// of deletes usually means something went wrong (e.g. a bulk suppress in
+// Sierra) and we'd rather stop and have a human look at it. A negative
+// value disables the check.
+type DeleteLimits struct {
+	MaxDeletes       int
+	MaxDeletePercent float64 // percentage of the documents in Solr
+}
+
+// ThresholdError is returned when an operation was aborted because it
+// would have deleted more documents than allowed.
+type ThresholdError struct {
+	Operation string
+	Count     int
+	Limit     string
+}
+
+func (e ThresholdError) Error() string {
+	return fmt.Sprintf("%s aborted: it would delete %d documents (limit is %s)", e.Operation, e.Count, e.Limit)
+}
+
+// DeleteLimits returns the limits to use when deleting from Solr.
+// Values not indicated in the settings take the default values.
+func (settings Settings) DeleteLimits() DeleteLimits {
+	limits := DeleteLimits{
+		MaxDeletes:       defaultMaxDeletes,
+		MaxDeletePercent: defaultMaxDeletePercent,
+	}
+	if settings.SolrMaxDeletes != 0 {
+		limits.MaxDeletes = settings.SolrMaxDeletes
+	}
+	if settings.SolrMaxDeletePercent != 0 {
+		limits.MaxDeletePercent = settings.SolrMaxDeletePercent
+	}
+	return limits
+}
+
+// MinBestBetsRows returns the minimum number of rows that the BestBets
+// Google Sheet must have for us to replace all the BestBets in Solr.
+func (settings Settings) MinBestBetsRows() int {
+	if settings.BestBetsMinRows != 0 {
+		return settings.BestBetsMinRows
+	}
+	return defaultBestBetsMinRows
+}
+
+// Check returns a ThresholdError if deleting `count` documents out of
+// `total` goes over the limits.
+func (limits DeleteLimits) Check(operation string, count, total int) error {
+	if limits.MaxDeletes >= 0 && count > limits.MaxDeletes {
+		return ThresholdError{Operation: operation, Count: count, Limit: fmt.Sprintf("%d documents", limits.MaxDeletes)}
+	}
+	if limits.MaxDeletePercent >= 0 && total > 0 {
+		percent := float64(count) * 100 / float64(total)
+		if percent > limits.MaxDeletePercent {
+			limit := fmt.Sprintf("%.1f%% of %d documents", limits.MaxDeletePercent, total)
+			return ThresholdError{Operation: operation, Count: count, Limit: limit}
+		}
+	}
+	return nil
+}
+
+// DeletePreview returns the IDs that would be removed from Solr by Delete
+// for the given date range without deleting anything.
+func (model BibModel) DeletePreview(fromDate, toDate string) (SolrDeletePlan, error) {
+	plan := SolrDeletePlan{From: fromDate, To: toDate}
+	count, err := model.solrCount()
+	if err != nil {
+		return plan, err
+	}
+	plan.SolrCount = count
+
+	plan.Deleted, err = model.GetBibsDeleted(fromDate, toDate)
+	if err != nil {
+		return plan, err
+	}
+
+	plan.Suppressed, err = model.GetBibsSuppressed(fromDate, toDate)
+	if err != nil {
+		return plan, err
+	}
+
+	errLimit := model.settings.DeleteLimits().Check("Solr delete", plan.Count(), plan.SolrCount)
+	if errLimit != nil {
+		plan.LimitExceeded = errLimit.Error()
+	}
+	return plan, nil
+}
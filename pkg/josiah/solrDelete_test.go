@@ -0,0 +1,77 @@
+package josiah
+
+import (
+	"bibService/pkg/sierra"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeleteLimits(t *testing.T) {
+	limits := Settings{}.DeleteLimits()
+	if limits.MaxDeletes != defaultMaxDeletes || limits.MaxDeletePercent != defaultMaxDeletePercent {
+		t.Errorf("Unexpected default limits: %#v", limits)
+	}
+
+	limits = Settings{SolrMaxDeletes: 100, SolrMaxDeletePercent: 10}.DeleteLimits()
+	if err := limits.Check("test", 100, 1000); err != nil {
+		t.Errorf("Unexpected error within limits: %s", err)
+	}
+
+	err := limits.Check("test", 101, 100000)
+	if _, ok := err.(ThresholdError); !ok {
+		t.Errorf("Expected threshold error for max deletes, got: %v", err)
+	}
+
+	err = limits.Check("test", 50, 400)
+	if _, ok := err.(ThresholdError); !ok {
+		t.Errorf("Expected threshold error for max percent, got: %v", err)
+	}
+
+	// negative values disable the checks
+	limits = Settings{SolrMaxDeletes: -1, SolrMaxDeletePercent: -1}.DeleteLimits()
+	if err := limits.Check("test", 1000000, 10); err != nil {
+		t.Errorf("Unexpected error with limits disabled: %s", err)
+	}
+}
+
+func TestDeletePreviewKeepsCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+		case "/solr/select":
+			fmt.Fprint(w, `{"response":{"numFound":1000,"docs":[]}}`)
+		case "/bibs":
+			if r.URL.Query().Get("suppressed") == "true" {
+				fmt.Fprint(w, `{"total":1,"entries":[{"id":"1000002","suppressed":true}]}`)
+			} else {
+				fmt.Fprint(w, `{"total":1,"entries":[{"id":"1000001","deleted":true}]}`)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	settings := Settings{}
+	model := BibModel{settings: settings, api: sierra.NewSierra(server.URL, "key:secret", ""),
+		cache: NewResponseCache(settings), solrUrl: server.URL + "/solr"}
+	model.cache.Set(CacheBib, "1000001", "", sierra.Bib{Id: "1000001"})
+	model.cache.Set(CacheBib, "1000002", "", sierra.Bib{Id: "1000002"})
+
+	plan, err := model.DeletePreview("2020-01-01", "2020-01-02")
+	if err != nil || plan.Count() != 2 || plan.SolrCount != 1000 {
+		t.Fatalf("Unexpected plan: %#v, %v", plan, err)
+	}
+	var bib sierra.Bib
+	if !model.cache.Get(CacheBib, "1000001", &bib) || !model.cache.Get(CacheBib, "1000002", &bib) {
+		t.Errorf("Dry run removed records from the cache")
+	}
+
+	model.uncache(plan)
+	if model.cache.Get(CacheBib, "1000001", &bib) || model.cache.Get(CacheBib, "1000002", &bib) {
+		t.Errorf("Records were not removed from the cache")
+	}
+}
@@ -0,0 +1,121 @@
+package josiah
+
+import (
+	"bibService/pkg/marcimport"
+	"bibService/pkg/sierra"
+)
+
+// NewSolrDoc creates a Solr document (as defined in Josiah's Solr schema)
+// with the values from a Sierra bib record. The bib record is expected to
+// include its item information since some of the values (e.g. building
+// and location codes) are calculated from them.
+//
+// The values calculated here are meant to match the values that Traject
+// produces for the same record (see scripts/compare.rb)
+func NewSolrDoc(bib sierra.Bib) marcimport.SolrDoc {
+	doc := marcimport.SolrDoc{}
+	doc.Id = []string{bib.Bib()}
+	doc.UpdatedDt = stringArray(bib.UpdatedDateTime)
+	doc.IsbnT = bib.Isbn()
+	doc.IssnT = bib.Issn()
+	doc.OclcT = bib.OclcNum()
+
+	doc.TitleT = bib.TitleT()
+	doc.TitleDisplay = stringArray(bib.TitleDisplay())
+	doc.TitleVernDisplay = stringArray(bib.TitleVernacularDisplay())
+	doc.TitleSeriesT = bib.TitleSeries()
+	doc.TitleSort = stringArray(bib.SortableTitle())
+	doc.UniformTitlesDisplay = jsonArray(bib.UniformTitlesDisplay(false))
+	doc.NewUniformTitleAuthorDisplay = jsonArray(bib.UniformTitlesDisplay(true))
+	doc.UniformRelatedWorksDisplay = jsonArray(bib.UniformRelatedWorks())
+
+	doc.AuthorDisplay = stringArray(bib.AuthorDisplay())
+	doc.AuthorVernDisplay = stringArray(bib.AuthorVernacularDisplay())
+	doc.AuthorAddlDisplay = bib.AuthorsAddlDisplay()
+	doc.AuthorT = bib.AuthorsT()
+	doc.AuthorAddlT = bib.AuthorsAddlT()
+	doc.AuthorFacet = bib.AuthorFacet()
+
+	doc.PublishedDisplay = bib.PublishedDisplay()
+	doc.PublishedVernDisplay = stringArray(bib.PublishedVernacularDisplay())
+	doc.PhysicalDisplay = bib.PhysicalDisplay()
+	doc.AbstractDisplay = stringArray(bib.AbstractDisplay())
+	doc.TableOfContents = bib.TableOfContents()
+	doc.TableOfContents970 = bib.TableOfContents970()
+	if year, ok := bib.PublicationYear(); ok {
+		doc.PublicationYear = []int{year}
+	}
+
+	doc.UrlFullTextDisplay = bib.UrlDisplay("856u")
+	doc.UrlSupplDisplay = bib.UrlDisplay("856z")
+	online := bib.IsOnline()
+	doc.Online = []bool{online}
+	if online {
+		doc.AccessFacet = []string{"Online"}
+	} else {
+		doc.AccessFacet = []string{"At the library"}
+	}
+
+	doc.Format = stringArray(bib.Format())
+	doc.LanguageFacet = bib.Languages()
+	doc.BuildingFacet = bib.BuildingFacets()
+	doc.LocationCodeT = bib.LocationCodes()
+	doc.RegionFacet = bib.RegionFacet()
+	doc.TopicFacet = bib.TopicFacet()
+	doc.SubjectsT = bib.Subjects()
+	doc.CallNumbers = bib.CallNumbers()
+	doc.Text = bib.Text()
+	doc.MarcDisplay = bib.MarcDisplay()
+
+	bookplates := bib.BookplateCodes()
+	doc.BookplateCodeFacet = bookplates
+	doc.BookplateCodeSS = bookplates
+	return doc
+}
+
+// NewSolrDocs creates the Solr documents for the bibs given. Bibs deleted
+// or suppressed in Sierra are skipped (see solrRemovedIDs).
+func NewSolrDocs(bibs sierra.Bibs) []marcimport.SolrDoc {
+	docs := []marcimport.SolrDoc{}
+	for _, bib := range bibs.Entries {
+		if notInSolr(bib) {
+			continue
+		}
+		docs = append(docs, NewSolrDoc(bib))
+	}
+	return docs
+}
+
+// solrRemovedIDs returns the Solr IDs of the bibs given that must not be
+// in Solr because they were deleted or suppressed in Sierra.
+func solrRemovedIDs(bibs sierra.Bibs) []string {
+	ids := []string{}
+	for _, bib := range bibs.Entries {
+		if notInSolr(bib) {
+			ids = append(ids, bib.Bib())
+		}
+	}
+	return ids
+}
+
+func notInSolr(bib sierra.Bib) bool {
+	return bib.Deleted || bib.Suppressed
+}
+
+// stringArray returns an array with the value given, or an empty array
+// if the value is empty. This prevents us from sending empty values to Solr.
+func stringArray(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return []string{value}
+}
+
+// jsonArray is like stringArray but also considers an empty JSON array
+// as an empty value.
+func jsonArray(value string) []string {
+	if value == "[]" || value == "null" {
+		return []string{}
+	}
+	return stringArray(value)
+}
@@ -0,0 +1,53 @@
+package josiah
+
+import (
+	"bibService/pkg/marc"
+	"bibService/pkg/sierra"
+	"testing"
+)
+
+func TestNewSolrDoc(t *testing.T) {
+	a := map[string]string{"tag": "a", "content": "The title /"}
+	f245 := marc.MarcField{MarcTag: "245", Ind2: "4"}
+	f245.Subfields = []map[string]string{a}
+	f008 := marc.MarcField{MarcTag: "008", Content: "760629c19749999ne tr pss o   0   a0eng  cas   "}
+	bib := sierra.Bib{Id: "1234567", VarFields: marc.MarcFields{f008, f245}}
+
+	doc := NewSolrDoc(bib)
+	if len(doc.Id) != 1 || doc.Id[0] != "b1234567" {
+		t.Errorf("Unexpected id: %#v", doc.Id)
+	}
+	if len(doc.TitleDisplay) != 1 || doc.TitleDisplay[0] != "The title" {
+		t.Errorf("Unexpected title_display: %#v", doc.TitleDisplay)
+	}
+	if len(doc.TitleSort) != 1 || doc.TitleSort[0] != "title" {
+		t.Errorf("Unexpected title_sort: %#v", doc.TitleSort)
+	}
+	if len(doc.PublicationYear) != 1 || doc.PublicationYear[0] != 1974 {
+		t.Errorf("Unexpected pub_date: %#v", doc.PublicationYear)
+	}
+	if len(doc.AuthorDisplay) != 0 {
+		t.Errorf("Unexpected author_display: %#v", doc.AuthorDisplay)
+	}
+	if len(doc.AccessFacet) != 1 || doc.AccessFacet[0] != "At the library" {
+		t.Errorf("Unexpected access_facet: %#v", doc.AccessFacet)
+	}
+}
+
+func TestNewSolrDocsSkipsDeletedAndSuppressed(t *testing.T) {
+	bibs := sierra.Bibs{Entries: []sierra.Bib{
+		{Id: "1000001"},
+		{Id: "1000002", Deleted: true},
+		{Id: "1000003", Suppressed: true},
+	}}
+
+	docs := NewSolrDocs(bibs)
+	if len(docs) != 1 || docs[0].Id[0] != "b1000001" {
+		t.Errorf("Unexpected documents: %#v", docs)
+	}
+
+	removed := solrRemovedIDs(bibs)
+	if len(removed) != 2 || removed[0] != "b1000002" || removed[1] != "b1000003" {
+		t.Errorf("Unexpected IDs to remove: %#v", removed)
+	}
+}
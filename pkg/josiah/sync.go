@@ -0,0 +1,157 @@
+package josiah
+
+import (
+	"bibService/pkg/sierra"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Number of days to process when there is no previous sync recorded.
+const syncDefaultDays = 10
+
+// Name of the file (under CachedDataPath) where we keep track of the syncs.
+const syncStateFile = "sync_state.json"
+
+// Name of the file (under CachedDataPath) that keeps two syncs from running
+// at the same time, even in different processes (e.g. the sync action and
+// the solrSync job in the web server).
+const syncLockFile = "sync.lock"
+
+// A lock file older than this is assumed to have been left behind by a
+// sync that crashed.
+const syncLockMaxAge = 6 * time.Hour
+
+// ErrSyncRunning is returned when another sync is already running.
+var ErrSyncRunning = errors.New("Another sync is running")
+
+// SyncState keeps track of the last successful sync between Sierra and Solr.
+type SyncState struct {
+	LastSync  string    `json:"lastSync"` // high-water mark (yyyy-mm-dd)
+	LastRun   time.Time `json:"lastRun"`
+	Indexed   int       `json:"indexed"`
+	Completed bool      `json:"completed"`
+}
+
+// Sync updates Solr with the changes in Sierra since the last successful
+// sync: bib records updated are re-indexed and bib records deleted or
+// suppressed are removed.
+//
+// The high-water mark is only moved forward once the whole run has
+// succeeded, therefore if a run fails the next run will start again from
+// the same point. Sierra's date ranges are by day (rather than by date and
+// time) so the day of the high-water mark is processed again on the next
+// run to pick up changes made later on that day. This is safe because
+// indexing and deleting are idempotent.
+//
+// Only one sync can run at a time (see syncLockFile), ErrSyncRunning is
+// returned if another one is running.
+func (model BibModel) Sync() (SyncState, error) {
+	if model.settings.CachedDataPath == "" {
+		return SyncState{}, errors.New("No cachedDataPath was indicated in the settings")
+	}
+
+	unlock, err := lockSync(filepath.Join(model.settings.CachedDataPath, syncLockFile))
+	if err != nil {
+		return SyncState{}, err
+	}
+	defer unlock()
+
+	stateFile := filepath.Join(model.settings.CachedDataPath, syncStateFile)
+	state, err := loadSyncState(stateFile)
+	if err != nil {
+		return state, err
+	}
+
+	now := time.Now()
+	from := state.LastSync
+	if from == "" {
+		from = now.AddDate(0, 0, -syncDefaultDays).Format("2006-01-02")
+	}
+	to := now.Format("2006-01-02")
+
+	// Record the attempt (but not the new high-water mark) so that we can
+	// tell from the state file that the last run did not complete.
+	state.LastRun = now
+	state.Completed = false
+	err = saveSyncState(stateFile, state)
+	if err != nil {
+		return state, err
+	}
+
+	log.Printf("Sync: indexing BIB records updated (%s - %s)", from, to)
+	indexed := 0
+	err = model.EachBibUpdated(context.Background(), from, to, true, func(page []sierra.Bib) error {
+		count, err := model.indexBibs(sierra.Bibs{Total: len(page), Entries: page})
+		indexed += count
+		return err
+	})
+	if err != nil {
+		return state, err
+	}
+
+	log.Printf("Sync: deleting BIB records deleted/suppressed (%s - %s)", from, to)
+	started := time.Now()
+	stats, err := model.Delete(from, to, false)
+	params := map[string]string{"from": from, "to": to}
+	NewAuditLog(model.settings).RecordOperation("sync", "solr.delete", params, stats, started, err)
+	if err != nil {
+		return state, err
+	}
+
+	state.LastSync = to
+	state.Indexed = indexed
+	state.Completed = true
+	err = saveSyncState(stateFile, state)
+	return state, err
+}
+
+// lockSync creates the lock file (which must not exist) and returns the
+// function to remove it once the sync is done.
+func lockSync(filename string) (func(), error) {
+	info, err := os.Stat(filename)
+	if err == nil && time.Since(info.ModTime()) > syncLockMaxAge {
+		log.Printf("WARN: Removing stale sync lock %s (created %s)", filename, info.ModTime().Format(time.RFC3339))
+		os.Remove(filename)
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		owner, _ := ioutil.ReadFile(filename)
+		return nil, fmt.Errorf("%w (%s, lock file %s)", ErrSyncRunning, strings.TrimSpace(string(owner)), filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintf(file, "pid %d since %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	file.Close()
+	if err != nil {
+		os.Remove(filename)
+		return nil, err
+	}
+	return func() { os.Remove(filename) }, nil
+}
+
+func loadSyncState(filename string) (SyncState, error) {
+	var state SyncState
+	bytes, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(bytes, &state)
+	return state, err
+}
+
+func saveSyncState(filename string, state SyncState) error {
+	return saveJSON(filename, state)
+}
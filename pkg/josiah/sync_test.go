@@ -0,0 +1,43 @@
+package josiah
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockSync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, syncLockFile)
+
+	unlock, err := lockSync(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lockSync(filename)
+	if !errors.Is(err, ErrSyncRunning) {
+		t.Errorf("Expected second lock to fail: %v", err)
+	}
+
+	unlock()
+	unlock, err = lockSync(filename)
+	if err != nil {
+		t.Errorf("Lock was not released: %v", err)
+	}
+
+	// locks left behind by a sync that crashed eventually expire
+	old := time.Now().Add(-syncLockMaxAge - time.Minute)
+	os.Chtimes(filename, old, old)
+	unlock, err = lockSync(filename)
+	if err != nil {
+		t.Errorf("Stale lock was not removed: %v", err)
+	}
+	unlock()
+}
@@ -3,7 +3,10 @@ package josiah
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -55,3 +58,42 @@ func Slugify(value string) string {
 	}
 	return slug
 }
+
+func in(values []string, searchedFor string) bool {
+	for _, value := range values {
+		if value == searchedFor {
+			return true
+		}
+	}
+	return false
+}
+
+// saveJSON writes the data as JSON to a temporary file first and then
+// renames it so that we never end up with a partially written file. Each
+// call writes to its own temporary file (in the same directory so that the
+// rename is atomic) since the same file can be saved concurrently, e.g. by
+// two requests caching the same BIB.
+func saveJSON(filename string, data interface{}) error {
+	bytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(bytes)
+	if err == nil {
+		err = tmpFile.Chmod(0644)
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+	}
+	return err
+}
@@ -306,7 +306,7 @@ func TestVernacularSubfields(t *testing.T) {
 		values[1].String() != "歴史文化ライブラリー ;" ||
 		values[2].String() != "Rekishi bunka raiburarī ; 451" ||
 		values[3].String() != "歴史文化ライブラリー ; 451" {
-		t.Errorf("Unexpected values were found: %#v")
+		t.Errorf("Unexpected values were found: %#v", values)
 	}
 }
 
@@ -333,7 +333,7 @@ func TestPubYear008X(t *testing.T) {
 	test4 := "061108q19501980nyuar ss 0 0eng ccas a "
 	_, ok = PubYear008(test4, 15)
 	if ok {
-		t.Errorf("Should have returned false on questionable date %s (%v, %v)", test4)
+		t.Errorf("Should have returned false on questionable date %s", test4)
 	}
 
 	if _, ok = PubYear008("too short", 15); ok {
@@ -0,0 +1,153 @@
+package marc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ISO 2709 delimiters
+const (
+	subfieldDelimiter = "\x1f"
+	fieldTerminator   = "\x1e"
+	recordTerminator  = "\x1d"
+)
+
+const leaderLength = 24
+
+// Leader used when the record does not have one.
+const defaultLeader = "00000nam a2200000 a 4500"
+
+// MarcJSON returns the record in MARC-in-JSON format
+// (https://rossfsinger.com/blog/2010/09/a-proposal-to-serialize-marc-in-json/)
+// This is the same format that Traject stores in the marc_display field
+// (i.e. what Ruby's MARC::Record.to_hash produces)
+func (fields MarcFields) MarcJSON() (string, error) {
+	type dataField struct {
+		Ind1      string              `json:"ind1"`
+		Ind2      string              `json:"ind2"`
+		Subfields []map[string]string `json:"subfields"`
+	}
+
+	type marcJSON struct {
+		Leader string                   `json:"leader"`
+		Fields []map[string]interface{} `json:"fields"`
+	}
+
+	record := marcJSON{Leader: fields.marcLeader(), Fields: []map[string]interface{}{}}
+	for _, field := range fields.marcFieldsSorted() {
+		if field.isControlField() {
+			record.Fields = append(record.Fields, map[string]interface{}{field.MarcTag: field.Content})
+			continue
+		}
+		data := dataField{
+			Ind1:      indicator(field.Ind1),
+			Ind2:      indicator(field.Ind2),
+			Subfields: []map[string]string{},
+		}
+		for _, sub := range field.Subfields {
+			data.Subfields = append(data.Subfields, map[string]string{sub["tag"]: sub["content"]})
+		}
+		record.Fields = append(record.Fields, map[string]interface{}{field.MarcTag: data})
+	}
+
+	// Notice that we don't escape HTML characters (e.g. "&") to match
+	// the output from Traject.
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(record)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+// ISO2709 returns the record in binary MARC21 format (ISO 2709)
+// https://www.loc.gov/marc/specifications/specrecstruc.html
+//
+// The record length and base address in the leader are recalculated and
+// the character coding scheme is set to "a" (UTF-8) since that is what
+// we get from Sierra.
+func (fields MarcFields) ISO2709() ([]byte, error) {
+	var directory, data strings.Builder
+	for _, field := range fields.marcFieldsSorted() {
+		var value string
+		if field.isControlField() {
+			value = field.Content + fieldTerminator
+		} else {
+			value = indicator(field.Ind1) + indicator(field.Ind2)
+			for _, sub := range field.Subfields {
+				value += subfieldDelimiter + sub["tag"] + sub["content"]
+			}
+			value += fieldTerminator
+		}
+		if len(value) > 9999 {
+			return nil, fmt.Errorf("Field %s is too long for ISO 2709 (%d bytes)", field.MarcTag, len(value))
+		}
+		directory.WriteString(fmt.Sprintf("%3s%04d%05d", field.MarcTag, len(value), data.Len()))
+		data.WriteString(value)
+	}
+	directory.WriteString(fieldTerminator)
+
+	baseAddress := leaderLength + directory.Len()
+	recordLength := baseAddress + data.Len() + len(recordTerminator)
+	if recordLength > 99999 {
+		return nil, errors.New("Record is too long for ISO 2709")
+	}
+
+	leader := []byte(fields.marcLeader())
+	copy(leader[0:5], fmt.Sprintf("%05d", recordLength))
+	leader[9] = 'a'
+	copy(leader[12:17], fmt.Sprintf("%05d", baseAddress))
+
+	var record bytes.Buffer
+	record.Write(leader)
+	record.WriteString(directory.String())
+	record.WriteString(data.String())
+	record.WriteString(recordTerminator)
+	return record.Bytes(), nil
+}
+
+// marcLeader returns the leader of the record padded (or truncated) to its
+// expected length.
+func (fields MarcFields) marcLeader() string {
+	leader := fields.Leader()
+	if leader == "" {
+		return defaultLeader
+	}
+	if len(leader) < leaderLength {
+		return leader + strings.Repeat(" ", leaderLength-len(leader))
+	}
+	return leader[0:leaderLength]
+}
+
+// marcFieldsSorted returns the MARC fields in the record sorted by MARC tag.
+// Sierra returns the fields grouped by field tag (not MARC tag) and includes
+// fields that are not MARC fields (e.g. the leader) which are skipped.
+func (fields MarcFields) marcFieldsSorted() MarcFields {
+	marcFields := MarcFields{}
+	for _, field := range fields {
+		if len(field.MarcTag) == 3 {
+			marcFields = append(marcFields, field)
+		}
+	}
+	sort.SliceStable(marcFields, func(i, j int) bool {
+		return marcFields[i].MarcTag < marcFields[j].MarcTag
+	})
+	return marcFields
+}
+
+func (f MarcField) isControlField() bool {
+	return f.MarcTag < "010" && len(f.Subfields) == 0
+}
+
+func indicator(value string) string {
+	if value == "" {
+		return " "
+	}
+	return value[0:1]
+}
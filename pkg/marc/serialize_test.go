@@ -0,0 +1,68 @@
+package marc
+
+import (
+	"testing"
+)
+
+func testRecord() MarcFields {
+	leader := MarcField{FieldTag: "_", Content: "00000cam  2200000 a 4500"}
+	f008 := MarcField{FieldTag: "y", MarcTag: "008", Content: "850101s1984    nyu"}
+	a := map[string]string{"tag": "a", "content": "Salt & pepper /"}
+	c := map[string]string{"tag": "c", "content": "Jane Doe."}
+	f245 := MarcField{FieldTag: "t", MarcTag: "245", Ind1: "1", Ind2: "0"}
+	f245.Subfields = []map[string]string{a, c}
+	a100 := map[string]string{"tag": "a", "content": "Doe, Jane."}
+	f100 := MarcField{FieldTag: "a", MarcTag: "100", Ind1: "1"}
+	f100.Subfields = []map[string]string{a100}
+	// Non-MARC Sierra field (should be skipped)
+	note := MarcField{FieldTag: "x", Content: "internal note"}
+	return MarcFields{leader, f245, f100, note, f008}
+}
+
+func TestMarcJSON(t *testing.T) {
+	str, err := testRecord().MarcJSON()
+	if err != nil {
+		t.Errorf("Error serializing to MARC-in-JSON: %s", err)
+	}
+	expected := `{"leader":"00000cam  2200000 a 4500","fields":[` +
+		`{"008":"850101s1984    nyu"},` +
+		`{"100":{"ind1":"1","ind2":" ","subfields":[{"a":"Doe, Jane."}]}},` +
+		`{"245":{"ind1":"1","ind2":"0","subfields":[{"a":"Salt & pepper /"},{"c":"Jane Doe."}]}}]}`
+	if str != expected {
+		t.Errorf("Unexpected MARC-in-JSON: %s", str)
+	}
+}
+
+func TestISO2709(t *testing.T) {
+	data, err := testRecord().ISO2709()
+	if err != nil {
+		t.Errorf("Error serializing to ISO 2709: %s", err)
+	}
+
+	// leader (24) + directory (3 * 12 + 1) + fields (19 + 15 + 31) + terminator (1)
+	record := string(data)
+	if len(record) != 127 {
+		t.Errorf("Unexpected record length: %d", len(record))
+	}
+	if record[0:24] != "00127cam a2200061 a 4500" {
+		t.Errorf("Unexpected leader: %s", record[0:24])
+	}
+	directory := "008001900000" + "100001500019" + "245003100034" + fieldTerminator
+	if record[24:61] != directory {
+		t.Errorf("Unexpected directory: %s", record[24:61])
+	}
+	f245 := "10" + subfieldDelimiter + "aSalt & pepper /" + subfieldDelimiter + "cJane Doe." + fieldTerminator
+	if record[61+34:61+34+31] != f245 {
+		t.Errorf("Unexpected 245: %q", record[61+34:61+34+31])
+	}
+	if record[len(record)-1:] != recordTerminator {
+		t.Errorf("Record terminator not found")
+	}
+}
+
+func TestMarcJSONNoLeader(t *testing.T) {
+	str, _ := MarcFields{}.MarcJSON()
+	if str != `{"leader":"`+defaultLeader+`","fields":[]}` {
+		t.Errorf("Unexpected MARC-in-JSON: %s", str)
+	}
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/url"
+	"path"
+	"time"
+)
+
+// Metrics exposed by the service (see /metrics)
+var (
+	HTTPRequests = NewCounter("bibservice_http_requests_total",
+		"HTTP requests received by route, method, and status code.",
+		"route", "method", "code")
+
+	HTTPRequestDuration = NewHistogram("bibservice_http_request_duration_seconds",
+		"Time to process HTTP requests by route.",
+		DefaultBuckets, "route")
+
+	SierraAPICalls = NewCounter("bibservice_sierra_api_calls_total",
+		"Calls to the Sierra API by endpoint, method, and HTTP status (\"error\" when no response was received). Retries are counted as separate calls.",
+		"endpoint", "method", "status")
+
+	SierraAPIDuration = NewHistogram("bibservice_sierra_api_call_duration_seconds",
+		"Duration of the calls to the Sierra API by endpoint.",
+		DefaultBuckets, "endpoint")
+
+	SierraRateLimited = NewCounter("bibservice_sierra_rate_limited_total",
+		"Calls to the Sierra API rejected with \"Rate exceeded\" by endpoint.",
+		"endpoint")
+
+	SierraTokenRefreshes = NewCounter("bibservice_sierra_token_refreshes_total",
+		"Access tokens requested from the Sierra API by result (ok or error).",
+		"result")
+
+	SQLQueryDuration = NewHistogram("bibservice_sql_query_duration_seconds",
+		"Duration of SQL queries by query name and result (ok or error).",
+		DefaultBuckets, "query", "result")
+
+	SolrRequests = NewCounter("bibservice_solr_requests_total",
+		"Updates sent to Solr by core, operation (post, delete, deleteAll), and result (ok or error).",
+		"core", "operation", "result")
+
+	SolrDocuments = NewCounter("bibservice_solr_documents_total",
+		"Documents posted to or deleted from Solr by core and operation (post or delete).",
+		"core", "operation")
+
+	CacheRequests = NewCounter("bibservice_cache_requests_total",
+		"Lookups in the Sierra response cache by resource (bib, items, marc) and result (memory, disk, or miss).",
+		"resource", "result")
+
+	CacheInvalidations = NewCounter("bibservice_cache_invalidations_total",
+		"Cached Sierra responses dropped because the BIB was updated in Sierra, by resource.",
+		"resource")
+
+	JobDuration = NewHistogram("bibservice_job_duration_seconds",
+		"Duration of background jobs by type and final state (completed or failed).",
+		JobBuckets, "type", "state")
+)
+
+// ObserveSQL records the duration of a named SQL query.
+func ObserveSQL(query string, started time.Time, err error) {
+	SQLQueryDuration.ObserveSince(started, query, result(err))
+}
+
+// ObserveSolr records an update sent to Solr that affected the given
+// number of documents.
+func ObserveSolr(solrURL string, operation string, docs int, err error) {
+	core := solrCore(solrURL)
+	SolrRequests.Inc(core, operation, result(err))
+	if err == nil && docs > 0 {
+		SolrDocuments.Add(float64(docs), core, operation)
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// solrCore returns the name of the core from its URL
+// (e.g. "http://localhost:8983/solr/blacklight-core" => "blacklight-core")
+func solrCore(solrURL string) string {
+	u, err := url.Parse(solrURL)
+	if err != nil || u.Path == "" {
+		return solrURL
+	}
+	return path.Base(u.Path)
+}
@@ -0,0 +1,258 @@
+// Package metrics keeps counters and histograms in memory and exposes them
+// in the Prometheus text format
+// (https://prometheus.io/docs/instrumenting/exposition_formats/)
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Separator used to build the key of a series from its label values.
+const labelSeparator = "\xff"
+
+// metric is implemented by Counter and Histogram.
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry keeps the metrics that are exposed.
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+}
+
+// DefaultRegistry is the registry used by NewCounter and NewHistogram.
+var DefaultRegistry = &Registry{}
+
+func (r *Registry) register(m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes all the metrics in the registry in the Prometheus text
+// format.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mutex.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mutex.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// WriteText writes the metrics in the default registry.
+func WriteText(w io.Writer) {
+	DefaultRegistry.WriteText(w)
+}
+
+// Counter is a value that only goes up, with one series for each
+// combination of label values.
+type Counter struct {
+	mutex  sync.Mutex
+	name   string
+	help   string
+	labels []string
+	values map[string]float64
+}
+
+// NewCounter creates a counter in the default registry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+// NewCounter creates a counter in the registry.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, values: map[string]float64{}}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series for the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds the given value to the series for the given label values.
+// The label values must be in the same order as the labels of the counter.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	key := seriesKey(c.labels, labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.values[key] += value
+}
+
+// Value returns the current value of the series for the given label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := seriesKey(c.labels, labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, escapeHelp(c.help))
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelPairs(c.labels, key, ""), formatFloat(c.values[key]))
+	}
+}
+
+// Histogram counts observations (e.g. durations in seconds) in buckets,
+// with one series for each combination of label values.
+type Histogram struct {
+	mutex   sync.Mutex
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // one per bucket (not cumulative)
+	count  uint64
+	sum    float64
+}
+
+// DefaultBuckets are the buckets (in seconds) for the duration of HTTP
+// requests, Sierra API calls, and SQL queries.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// JobBuckets are the buckets (in seconds) for the duration of background
+// jobs, which can run for hours.
+var JobBuckets = []float64{1, 5, 15, 30, 60, 300, 600, 1800, 3600, 7200, 14400, 28800}
+
+// NewHistogram creates a histogram in the default registry.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram creates a histogram in the registry. Buckets must be sorted
+// in increasing order.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}}
+	r.register(h)
+	return h
+}
+
+// Observe records a value in the series for the given label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := seriesKey(h.labels, labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// ObserveSince records the time elapsed since started (in seconds).
+func (h *Histogram) ObserveSince(started time.Time, labelValues ...string) {
+	h.Observe(time.Since(started).Seconds(), labelValues...)
+}
+
+// Count returns the number of observations in the series for the given
+// label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := seriesKey(h.labels, labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		return 0
+	}
+	return s.count
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, escapeHelp(h.help))
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+	keys := []string{}
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			le := `le="` + formatFloat(upper) + `"`
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(h.labels, key, le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(h.labels, key, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelPairs(h.labels, key, ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelPairs(h.labels, key, ""), s.count)
+	}
+}
+
+// seriesKey builds the key for a series. Missing label values are
+// recorded as empty strings and extra values are ignored.
+func seriesKey(labels []string, labelValues []string) string {
+	values := make([]string, len(labels))
+	copy(values, labelValues)
+	return strings.Join(values, labelSeparator)
+}
+
+// labelPairs returns the labels of a series in the form {name="value",...}
+// (plus the extra pair if one is given).
+func labelPairs(labels []string, key string, extra string) string {
+	pairs := []string{}
+	if len(labels) > 0 {
+		values := strings.Split(key, labelSeparator)
+		for i, label := range labels {
+			pairs = append(pairs, label+`="`+escapeLabelValue(values[i])+`"`)
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterText(t *testing.T) {
+	r := &Registry{}
+	c := r.NewCounter("test_total", "Test counter.", "route", "code")
+	c.Inc("/b", "200")
+	c.Inc("/a", "500")
+	c.Add(2, "/b", "200")
+	c.Inc(`/"quoted"`, "200")
+
+	var buffer bytes.Buffer
+	r.WriteText(&buffer)
+	expected := `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{route="/\"quoted\"",code="200"} 1
+test_total{route="/a",code="500"} 1
+test_total{route="/b",code="200"} 3
+`
+	if buffer.String() != expected {
+		t.Errorf("Unexpected output:\n%s", buffer.String())
+	}
+	if c.Value("/b", "200") != 3 || c.Value("/c", "200") != 0 {
+		t.Errorf("Unexpected values: %f, %f", c.Value("/b", "200"), c.Value("/c", "200"))
+	}
+}
+
+func TestHistogramText(t *testing.T) {
+	r := &Registry{}
+	h := r.NewHistogram("test_seconds", "Test histogram.", []float64{0.5, 1, 5}, "query")
+	h.Observe(0.1, "q1")
+	h.Observe(0.7, "q1")
+	h.Observe(10, "q1")
+
+	var buffer bytes.Buffer
+	r.WriteText(&buffer)
+	expected := `# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{query="q1",le="0.5"} 1
+test_seconds_bucket{query="q1",le="1"} 2
+test_seconds_bucket{query="q1",le="5"} 2
+test_seconds_bucket{query="q1",le="+Inf"} 3
+test_seconds_sum{query="q1"} 10.8
+test_seconds_count{query="q1"} 3
+`
+	if buffer.String() != expected {
+		t.Errorf("Unexpected output:\n%s", buffer.String())
+	}
+}
+
+func TestObserveHelpers(t *testing.T) {
+	before := SQLQueryDuration.Count("testQuery", "error")
+	ObserveSQL("testQuery", time.Now(), errors.New("failed"))
+	if SQLQueryDuration.Count("testQuery", "error")-before != 1 {
+		t.Errorf("SQL query not recorded")
+	}
+
+	ObserveSolr("http://localhost:8983/solr/test-core/", "post", 25, nil)
+	if SolrDocuments.Value("test-core", "post") != 25 || SolrRequests.Value("test-core", "post", "ok") != 1 {
+		t.Errorf("Solr post not recorded")
+	}
+
+	var buffer bytes.Buffer
+	WriteText(&buffer)
+	if !strings.Contains(buffer.String(), `bibservice_solr_documents_total{core="test-core",operation="post"} 25`) {
+		t.Errorf("Solr documents not in output")
+	}
+}
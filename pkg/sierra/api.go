@@ -6,16 +6,29 @@ package sierra
 // 	https://techdocs.iii.com/sierraapi/Content/zAppendix/bibObjectExample.htm
 
 import (
+	"bibService/pkg/metrics"
+	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Sierra represents the Sierra API endpoint.
+//
+// A Sierra value is safe for concurrent use and is meant to be long-lived
+// (e.g. one per process) so that the access token is shared across requests.
+// Always use it through the pointer returned by NewSierra.
 type Sierra struct {
 	URL           string
 	Persistent    bool
@@ -24,17 +37,20 @@ type Sierra struct {
 	Authorization authResp
 	Verbose       bool
 	SessionFile   string
+	Retry         RetryPolicy
+	authMutex     sync.Mutex // protects Authorization and the session file
 }
 
 // NewSierra defines a Sierra API endpoint.
-func NewSierra(apiURL, keySecret, sessionFile string) Sierra {
-	s := Sierra{
+func NewSierra(apiURL, keySecret, sessionFile string) *Sierra {
+	s := &Sierra{
 		URL:         apiURL,
 		KeySecret:   keySecret,
 		KeySecret64: base64.StdEncoding.EncodeToString([]byte(keySecret)),
 		SessionFile: sessionFile,
 		Persistent:  (sessionFile != ""),
 		Verbose:     false,
+		Retry:       DefaultRetryPolicy(),
 	}
 
 	if s.Persistent {
@@ -44,72 +60,181 @@ func NewSierra(apiURL, keySecret, sessionFile string) Sierra {
 	return s
 }
 
-func (s Sierra) httpDelete(url, accessToken string) (string, error) {
-	s.log("HTTP DELETE", url)
-	req, err := http.NewRequest("DELETE", url, nil)
-	if accessToken != "" {
-		req.Header.Set("Authorization", "Bearer "+accessToken)
-	}
+// apiGet issues an authenticated HTTP GET to the Sierra API.
+func (s *Sierra) apiGet(url string) (string, error) {
+	return s.apiRequest("GET", url)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// apiRequest issues an authenticated request to the Sierra API. If Sierra
+// rejects our access token (e.g. because it was revoked or it expired
+// earlier than we expected) we get a new token and replay the request once.
+func (s *Sierra) apiRequest(method, url string) (string, error) {
+	token, err := s.accessToken()
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		s.log("HTTP ERROR", string(body))
-		return string(body), fmt.Errorf("Status code %d", resp.StatusCode)
+	body, err := s.httpRequest(method, url, bearer(token))
+	var apiErr APIError
+	if errors.As(err, &apiErr) && apiErr.IsUnauthorized() {
+		s.log("Access token rejected, requesting a new one", url)
+		s.invalidateToken(token)
+		token, err = s.accessToken()
+		if err != nil {
+			return "", err
+		}
+		body, err = s.httpRequest(method, url, bearer(token))
 	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	return string(body), err
+	return body, err
 }
 
-func (s Sierra) httpGet(url, accessToken string) (string, error) {
-	s.log("HTTP GET", url)
-	req, err := http.NewRequest("GET", url, nil)
-	if accessToken != "" {
-		req.Header.Set("Authorization", "Bearer "+accessToken)
+// apiSend issues an authenticated request with a JSON payload (nil for
+// none) to the Sierra API. Unlike apiRequest failed requests are not
+// retried since they change data in Sierra (e.g. retrying a hold request
+// that timed out could place a second hold) but, like apiRequest, the
+// request is replayed once if Sierra rejects our access token.
+func (s *Sierra) apiSend(method, url string, payload interface{}) (string, error) {
+	var data []byte
+	if payload != nil {
+		var err error
+		data, err = json.Marshal(payload)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	token, err := s.accessToken()
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		s.log("HTTP ERROR", string(body))
-		return string(body), fmt.Errorf("Status code %d", resp.StatusCode)
+	headers := bearer(token)
+	headers["Content-Type"] = "application/json"
+	body, err := s.httpRequestOnce(method, url, headers, data)
+	var apiErr APIError
+	if errors.As(err, &apiErr) && apiErr.IsUnauthorized() {
+		s.log("Access token rejected, requesting a new one", url)
+		s.invalidateToken(token)
+		token, err = s.accessToken()
+		if err != nil {
+			return "", err
+		}
+		headers = bearer(token)
+		headers["Content-Type"] = "application/json"
+		body, err = s.httpRequestOnce(method, url, headers, data)
 	}
+	return body, err
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	return string(body), err
+func (s *Sierra) httpDelete(url, accessToken string) (string, error) {
+	return s.httpRequest("DELETE", url, bearer(accessToken))
+}
+
+func (s *Sierra) httpGet(url, accessToken string) (string, error) {
+	return s.httpRequest("GET", url, bearer(accessToken))
+}
+
+// httpRequest issues an HTTP request to the Sierra API and retries it
+// (according to the retry policy) if it fails with a retryable error.
+func (s *Sierra) httpRequest(method, url string, headers map[string]string) (string, error) {
+	return s.Retry.retry(method+" "+url, func() (string, error) {
+		return s.httpRequestOnce(method, url, headers, nil)
+	})
 }
 
-func (s Sierra) httpPost(url string, headers map[string]string) (string, error) {
-	s.log("HTTP POST", url)
-	req, err := http.NewRequest("POST", url, nil)
+func (s *Sierra) httpRequestOnce(method, url string, headers map[string]string, payload []byte) (string, error) {
+	return s.httpRequestContext(context.Background(), method, url, headers, payload)
+}
+
+// httpRequestContext issues an HTTP request to the Sierra API (without
+// retrying it) that is aborted if ctx is done before it completes.
+func (s *Sierra) httpRequestContext(ctx context.Context, method, url string, headers map[string]string, payload []byte) (string, error) {
+	s.log("HTTP "+method, url)
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return "", err
+	}
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
 
+	endpoint := s.endpointName(url)
+	started := time.Now()
+	defer metrics.SierraAPIDuration.ObserveSince(started, endpoint)
+
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
+		metrics.SierraAPICalls.Inc(endpoint, method, "error")
 		return "", err
 	}
-
 	defer resp.Body.Close()
+	metrics.SierraAPICalls.Inc(endpoint, method, strconv.Itoa(resp.StatusCode))
+
 	body, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		s.log("HTTP ERROR", string(body))
+		apiErr := newAPIError(resp.StatusCode, string(body))
+		if apiErr.IsRateLimited() {
+			metrics.SierraRateLimited.Inc(endpoint)
+		}
+		return string(body), apiErr
+	}
 	return string(body), err
 }
 
+var recordIDSegment = regexp.MustCompile(`/[0-9]+(/|$)`)
+
+// Endpoints reported in the metrics (once record IDs are replaced by ":id").
+// Any other URL (e.g. the MARC files that Sierra generates for /bibs/marc)
+// is reported under its resource, e.g. "/bibs/*", so that the number of
+// values for the endpoint label is bounded.
+var knownEndpoints = []string{
+	"/token",
+	"/bibs", "/bibs/marc", "/bibs/:id", "/bibs/:id/marc",
+	"/items", "/items/:id", "/items/:id/checkouts",
+	"/holdings",
+	"/patrons/:id", "/patrons/:id/:id", "/patrons/:id/checkouts", "/patrons/:id/fines",
+	"/patrons/:id/holds", "/patrons/:id/holds/requests", "/patrons/holds/:id",
+}
+
+var knownResources = []string{"token", "bibs", "items", "holdings", "patrons"}
+
+// endpointName returns the Sierra endpoint for a URL without the query
+// string and with record IDs replaced by ":id"
+// (e.g. "https://host/iii/sierra-api/v5/items/123/checkouts?x=y" => "/items/:id/checkouts")
+// URLs that don't match any of the knownEndpoints are collapsed to their
+// resource (e.g. "/bibs/*") or to "other".
+func (s *Sierra) endpointName(url string) string {
+	endpoint := strings.TrimPrefix(url, s.URL)
+	if i := strings.Index(endpoint, "?"); i != -1 {
+		endpoint = endpoint[:i]
+	}
+	endpoint = recordIDSegment.ReplaceAllString(endpoint, "/:id$1")
+	endpoint = recordIDSegment.ReplaceAllString(endpoint, "/:id$1")
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if in(knownEndpoints, endpoint) {
+		return endpoint
+	}
+
+	resource := strings.SplitN(strings.TrimPrefix(endpoint, "/"), "/", 2)[0]
+	if in(knownResources, resource) {
+		return "/" + resource + "/*"
+	}
+	return "other"
+}
+
+func bearer(accessToken string) map[string]string {
+	if accessToken == "" {
+		return map[string]string{}
+	}
+	return map[string]string{"Authorization": "Bearer " + accessToken}
+}
+
 func (s *Sierra) loadSession() {
 	bytes, err := ioutil.ReadFile(s.SessionFile)
 	if err != nil {
@@ -122,12 +247,15 @@ func (s *Sierra) loadSession() {
 	s.Authorization = auth
 }
 
-func (s Sierra) log(msg1, msg2 string) {
+func (s *Sierra) log(msg1, msg2 string) {
 	if s.Verbose {
 		log.Printf("%s: %s", msg1, msg2)
 	}
 }
 
+// saveSession writes the session information to a temporary file first
+// and then renames it so that other processes reading the session file
+// never see a partially written file.
 func (s *Sierra) saveSession() error {
 	bytes, err := json.Marshal(s.Authorization)
 	if err != nil {
@@ -135,6 +263,10 @@ func (s *Sierra) saveSession() error {
 	}
 	// http://stackoverflow.com/a/18415935/446681
 	var normalAccess os.FileMode = 0644
-	err = ioutil.WriteFile(s.SessionFile, bytes, normalAccess)
-	return err
+	tmpFile := s.SessionFile + ".tmp"
+	err = ioutil.WriteFile(tmpFile, bytes, normalAccess)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, s.SessionFile)
 }
@@ -0,0 +1,61 @@
+package sierra
+
+import (
+	"bibService/pkg/metrics"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpointName(t *testing.T) {
+	s := NewSierra("https://host/iii/sierra-api/v5", "key:secret", "")
+	tests := map[string]string{
+		"https://host/iii/sierra-api/v5/bibs/?id=1,2,3":          "/bibs",
+		"https://host/iii/sierra-api/v5/items/123/checkouts?x=y": "/items/:id/checkouts",
+		"https://host/iii/sierra-api/v5/bibs/1000001/marc":       "/bibs/:id/marc",
+		"https://host/iii/sierra-api/v5/patrons/1/2":             "/patrons/:id/:id",
+		"https://host/iii/sierra-api/v5/token":                   "/token",
+		"https://host/iii/sierra-api/v5/bibs/marc/files/x.mrc":   "/bibs/*",
+		"https://host/iii/sierra-api/v5/bibs/marc/files/y.mrc":   "/bibs/*",
+		"https://host/iii/sierra-api/v5/bibs/abc":                "/bibs/*",
+		"https://otherhost/files/x.mrc":                          "other",
+	}
+	for url, expected := range tests {
+		if name := s.endpointName(url); name != expected {
+			t.Errorf("Unexpected endpoint for %s: %s", url, name)
+		}
+	}
+}
+
+func TestAPICallMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"token","token_type":"bearer","expires_in":3600}`)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"code":0,"specificCode":0,"name":"Rate exceeded for endpoint","description":"Rate exceeded for endpoint"}`)
+	}))
+	defer server.Close()
+
+	calls := metrics.SierraAPICalls.Value("/holdings", "GET", "403")
+	limited := metrics.SierraRateLimited.Value("/holdings")
+	tokens := metrics.SierraTokenRefreshes.Value("ok")
+
+	s := NewSierra(server.URL, "key:secret", "")
+	s.Retry = RetryPolicy{MaxRetries: 1, RetryRateLimited: true}
+	_, err := s.apiGet(server.URL + "/holdings")
+	if err == nil {
+		t.Errorf("Expected a rate limit error")
+	}
+	if metrics.SierraAPICalls.Value("/holdings", "GET", "403")-calls != 2 {
+		t.Errorf("Calls (including retries) not counted")
+	}
+	if metrics.SierraRateLimited.Value("/holdings")-limited != 2 {
+		t.Errorf("Rate limited calls not counted")
+	}
+	if metrics.SierraTokenRefreshes.Value("ok")-tokens != 1 {
+		t.Errorf("Token refresh not counted")
+	}
+}
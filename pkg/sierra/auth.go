@@ -1,6 +1,8 @@
 package sierra
 
 import (
+	"bibService/pkg/metrics"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,48 +18,107 @@ type authResp struct {
 	ValidUntil  time.Time `json:"valid_until"` // non-III value
 }
 
-func (s Sierra) isAuthenticated() bool {
+// We request a new token this long before the current one expires so that
+// we don't use a token that expires while a request is in flight.
+const tokenRefreshMargin = 2 * time.Minute
+
+// accessToken returns a valid access token, requesting a new one from
+// Sierra if the current one has expired or is about to expire.
+//
+// Requesting the token is retried according to the retry policy, but
+// outside of authMutex so that other requests are not blocked while we
+// wait to retry.
+func (s *Sierra) accessToken() (string, error) {
+	return s.Retry.retry("POST "+s.URL+"/token", s.accessTokenOnce)
+}
+
+func (s *Sierra) accessTokenOnce() (string, error) {
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+	err := s.authenticate()
+	if err != nil {
+		return "", err
+	}
+	return s.Authorization.AccessToken, nil
+}
+
+// invalidateToken discards the token given so that the next call to
+// accessToken requests a new one. If the token has already been replaced
+// (e.g. by another request) the current token is preserved.
+func (s *Sierra) invalidateToken(token string) {
+	s.authMutex.Lock()
+	defer s.authMutex.Unlock()
+	if s.Authorization.AccessToken == token {
+		s.Authorization = authResp{}
+	}
+}
+
+// isAuthenticated must be called while holding authMutex.
+func (s *Sierra) isAuthenticated() bool {
 	if s.Authorization.AccessToken == "" {
 		return false
 	}
-	validSession := time.Now().Before(s.Authorization.ValidUntil)
+	validSession := time.Now().Add(tokenRefreshMargin).Before(s.Authorization.ValidUntil)
 	return validSession
 }
 
+// authenticate must be called while holding authMutex.
 func (s *Sierra) authenticate() error {
 	if s.isAuthenticated() {
 		return nil
 	}
 
+	auth, err := s.requestToken(context.Background())
+	if err != nil {
+		return err
+	}
+	s.Authorization = auth
+	if s.Persistent {
+		err = s.saveSession()
+	}
+	return err
+}
+
+// requestToken requests a new access token from Sierra.
+func (s *Sierra) requestToken(ctx context.Context) (authResp, error) {
 	url := s.URL + "/token"
 	headers := map[string]string{
 		"Authorization": "Basic " + s.KeySecret64,
 		"Content-Type":  "text/plain",
 	}
-	body, err := s.httpPost(url, headers)
+	body, err := s.httpRequestContext(ctx, "POST", url, headers, nil)
 	if err != nil {
-		return err
+		metrics.SierraTokenRefreshes.Inc("error")
+		return authResp{}, err
 	}
 
 	var auth authResp
 	err = json.Unmarshal([]byte(body), &auth)
 	if err != nil {
-		return err
+		metrics.SierraTokenRefreshes.Inc("error")
+		return authResp{}, err
 	}
 
 	if auth.AccessToken == "" {
+		metrics.SierraTokenRefreshes.Inc("error")
 		errorMsg := fmt.Sprintf("No authentication token was returned %s", body)
-		return errors.New(errorMsg)
+		return authResp{}, errors.New(errorMsg)
 	}
+	metrics.SierraTokenRefreshes.Inc("ok")
 
 	duration := time.Duration(auth.ExpiresIn) * time.Second
 	auth.URL = s.URL
 	auth.ValidFrom = time.Now()
 	auth.ValidUntil = auth.ValidFrom.Add(duration)
-	s.Authorization = auth
-	if s.Persistent {
-		err = s.saveSession()
-	}
+	return auth, nil
+}
 
+// CheckCredentials requests a new access token from Sierra to make sure
+// that our credentials are still valid (e.g. they have not expired or been
+// revoked). The token is discarded, the token that we share across requests
+// is not affected. The request is not retried and it is aborted when ctx
+// is done.
+func (s *Sierra) CheckCredentials(ctx context.Context) error {
+	_, err := s.requestToken(ctx)
 	return err
 }
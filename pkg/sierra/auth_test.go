@@ -0,0 +1,124 @@
+package sierra
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReauthenticateOn401(t *testing.T) {
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokens++
+			fmt.Fprintf(w, `{"access_token":"token%d","token_type":"bearer","expires_in":3600}`, tokens)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	defer server.Close()
+
+	s := NewSierra(server.URL, "key:secret", "")
+	body, err := s.apiGet(server.URL + "/bibs")
+	if err != nil || body != "OK" || tokens != 2 {
+		t.Errorf("Request was not replayed with a new token: %s, %v, %d", body, err, tokens)
+	}
+}
+
+func TestTokenRefreshMargin(t *testing.T) {
+	s := NewSierra("http://localhost", "key:secret", "")
+	s.Authorization = authResp{AccessToken: "x", ValidUntil: time.Now().Add(30 * time.Second)}
+	if s.isAuthenticated() {
+		t.Errorf("Token about to expire should not be considered valid")
+	}
+	s.Authorization.ValidUntil = time.Now().Add(time.Hour)
+	if !s.isAuthenticated() {
+		t.Errorf("Valid token not detected")
+	}
+}
+
+func TestTokenRetryReleasesLock(t *testing.T) {
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens++
+		if tokens == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"token","token_type":"bearer","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	s := NewSierra(server.URL, "key:secret", "")
+	locked := false
+	sleep = func(d time.Duration) {
+		// this would block if we were still holding authMutex
+		done := make(chan bool)
+		go func() {
+			s.invalidateToken("")
+			done <- true
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			locked = true
+		}
+	}
+	defer func() { sleep = time.Sleep }()
+
+	token, err := s.accessToken()
+	if err != nil || token != "token" || tokens != 2 {
+		t.Errorf("Token request was not retried: %s, %v, %d", token, err, tokens)
+	}
+	if locked {
+		t.Errorf("authMutex was held while waiting to retry")
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	valid := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"code":123,"specificCode":0,"httpStatus":401,"name":"Unauthorized","description":"Invalid client credentials"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"new","token_type":"bearer","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	// a fresh token is requested even though we have a valid one, and the
+	// shared token is left alone
+	s := NewSierra(server.URL, "key:secret", "")
+	s.Authorization = authResp{AccessToken: "shared", ValidUntil: time.Now().Add(time.Hour)}
+	if err := s.CheckCredentials(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if s.Authorization.AccessToken != "shared" {
+		t.Errorf("Shared token was replaced: %s", s.Authorization.AccessToken)
+	}
+
+	valid = false
+	if err := s.CheckCredentials(context.Background()); err == nil {
+		t.Errorf("Revoked credentials not detected")
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+	s = NewSierra(slow.URL, "key:secret", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if err := s.CheckCredentials(ctx); err == nil || time.Since(started) > time.Second {
+		t.Errorf("Check did not stop at the deadline: %v", err)
+	}
+}
@@ -486,7 +486,7 @@ func (bib Bib) UpdatedDate() string {
 
 func (bib Bib) IsOnline() bool {
 	for _, item := range bib.Items {
-		if strings.HasPrefix(item.Location["code"], "es") {
+		if isOnlineLocation(item.Location["code"]) {
 			return true
 		}
 	}
@@ -621,16 +621,48 @@ func (bib Bib) RegionFacetZFields() []string {
 }
 
 /*
- * TODO
+ * Table of contents
  */
+
+// TableOfContents returns the chapters in the 505 fields (formatted
+// contents note) as a JSON string.
 func (bib Bib) TableOfContents() []string {
-	return []string{}
+	entries := []TocEntry{}
+	for _, field := range bib.VarFields.GetFields("505") {
+		entries = append(entries, tocFrom505(field)...)
+	}
+	return tocDisplay(entries)
 }
 
+// TableOfContents970 returns the chapters in the 970 fields as a JSON
+// string. Notice that the 970 fields only come when the MARC data is
+// exported with the "toc" mapping (see Sierra.Marc)
 func (bib Bib) TableOfContents970() []string {
-	return []string{}
+	entries := []TocEntry{}
+	for _, field := range bib.VarFields.GetFields("970") {
+		entry, ok := tocFrom970(field)
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+	return tocDisplay(entries)
+}
+
+func tocDisplay(entries []TocEntry) []string {
+	if len(entries) == 0 {
+		return []string{}
+	}
+	str, err := toJSON(entries)
+	if err != nil {
+		return []string{}
+	}
+	return []string{str}
 }
 
+/*
+ * Others
+ */
+
 func (bib Bib) Text() []string {
 	text := []string{}
 	for _, field := range bib.VarFields {
@@ -641,21 +673,24 @@ func (bib Bib) Text() []string {
 	return text
 }
 
+// MarcDisplay returns the full MARC record in MARC-in-JSON format.
 func (bib Bib) MarcDisplay() []string {
-	return []string{}
+	str, err := bib.VarFields.MarcJSON()
+	if err != nil {
+		return []string{}
+	}
+	return []string{str}
 }
 
 // GetBib fetches on BIB record by ID
 func (s *Sierra) GetBib(bibID string) (Bib, error) {
-	params := map[string]string{
-		"id": bibID,
-	}
-	bibs, err := s.GetBibs(params, true)
+	query := BibQuery{IDs: []string{bibID}}
+	bibs, err := s.GetBibs(query, true)
 	if err != nil {
 		return Bib{}, err
 	}
 	if len(bibs.Entries) == 0 {
-		return Bib{}, fmt.Errorf("No BIB was found for ID %s", bibID)
+		return Bib{}, NewNotFoundError(fmt.Sprintf("No BIB was found for ID %s", bibID))
 	}
 	return bibs.Entries[0], nil
 }
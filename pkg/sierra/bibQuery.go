@@ -0,0 +1,85 @@
+package sierra
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Fields that we request by default when fetching BIB records.
+var defaultBibFields = []string{"default", "available", "orders", "normTitle",
+	"normAuthor", "locations", "varFields", "fixedFields"}
+
+// BibQuery represents the parameters to fetch BIB records via the
+// Sierra API /bibs endpoint.
+//
+// Date ranges are in the form yyyy-mm-dd. Sierra automatically appends
+// "00:00:00" to the from date and "23:59:59" to the to date. Either end
+// of a range can be left empty for an open ended range.
+type BibQuery struct {
+	IDs         []string // list of IDs (without the "b" prefix)
+	FromID      string   // ID range (without the "b" prefix)
+	ToID        string
+	UpdatedFrom string
+	UpdatedTo   string
+	CreatedFrom string
+	CreatedTo   string
+	DeletedFrom string
+	DeletedTo   string
+	Suppressed  bool // only fetch suppressed records
+	Deleted     bool // only fetch deleted records
+	Locations   []string
+	Limit       int
+	Offset      int
+	Fields      []string // defaults to defaultBibFields when empty
+}
+
+// Values returns the query string values for the query.
+func (q BibQuery) Values() url.Values {
+	values := url.Values{}
+	if len(q.IDs) > 0 {
+		values.Set("id", strings.Join(q.IDs, ","))
+	} else if q.FromID != "" || q.ToID != "" {
+		values.Set("id", queryRange(q.FromID, q.ToID))
+	}
+	if q.UpdatedFrom != "" || q.UpdatedTo != "" {
+		values.Set("updatedDate", queryRange(q.UpdatedFrom, q.UpdatedTo))
+	}
+	if q.CreatedFrom != "" || q.CreatedTo != "" {
+		values.Set("createdDate", queryRange(q.CreatedFrom, q.CreatedTo))
+	}
+	if q.DeletedFrom != "" || q.DeletedTo != "" {
+		values.Set("deletedDate", queryRange(q.DeletedFrom, q.DeletedTo))
+	}
+	if q.Suppressed {
+		values.Set("suppressed", "true")
+	}
+	if q.Deleted {
+		values.Set("deleted", "true")
+	}
+	if len(q.Locations) > 0 {
+		values.Set("locations", strings.Join(q.Locations, ","))
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		values.Set("offset", strconv.Itoa(q.Offset))
+	}
+	fields := q.Fields
+	if len(fields) == 0 {
+		fields = defaultBibFields
+	}
+	values.Set("fields", strings.Join(fields, ","))
+	return values
+}
+
+// URL returns the URL (properly encoded) to execute the query against
+// the given Sierra API URL.
+func (q BibQuery) URL(apiURL string) string {
+	return apiURL + "/bibs?" + q.Values().Encode()
+}
+
+func queryRange(from, to string) string {
+	return "[" + from + "," + to + "]"
+}
@@ -0,0 +1,30 @@
+package sierra
+
+import (
+	"testing"
+)
+
+func TestBibQueryURL(t *testing.T) {
+	q := BibQuery{FromID: "1000000", ToID: "1000010", Limit: 10, Fields: []string{"default"}}
+	url := q.URL("http://sierra/v5")
+	expected := "http://sierra/v5/bibs?fields=default&id=%5B1000000%2C1000010%5D&limit=10"
+	if url != expected {
+		t.Errorf("Unexpected URL: %s", url)
+	}
+
+	q = BibQuery{UpdatedFrom: "2020-01-01", UpdatedTo: "2020-01-31", Suppressed: true, Offset: 1000}
+	values := q.Values()
+	if values.Get("updatedDate") != "[2020-01-01,2020-01-31]" || values.Get("suppressed") != "true" ||
+		values.Get("offset") != "1000" || values.Get("limit") != "" {
+		t.Errorf("Unexpected values: %#v", values)
+	}
+	if values.Get("fields") != "default,available,orders,normTitle,normAuthor,locations,varFields,fixedFields" {
+		t.Errorf("Default fields not used: %s", values.Get("fields"))
+	}
+
+	q = BibQuery{IDs: []string{"1", "2"}, DeletedFrom: "2020-01-01", Locations: []string{"rock", "sci"}}
+	values = q.Values()
+	if values.Get("id") != "1,2" || values.Get("deletedDate") != "[2020-01-01,]" || values.Get("locations") != "rock,sci" {
+		t.Errorf("Unexpected values: %#v", values)
+	}
+}
@@ -419,3 +419,68 @@ func TestIsDissertation(t *testing.T) {
 		t.Errorf("Incorrectly detected a dissertation")
 	}
 }
+
+func TestTableOfContents505Basic(t *testing.T) {
+	a := map[string]string{"tag": "a", "content": "Introduction -- The early years -- Later years."}
+	field := marc.MarcField{MarcTag: "505"}
+	field.Subfields = []map[string]string{a}
+	bib := Bib{VarFields: marc.MarcFields{field}}
+
+	toc := bib.TableOfContents()
+	expected := `[{"title":"Introduction"},{"title":"The early years"},{"title":"Later years"}]`
+	if len(toc) != 1 || toc[0] != expected {
+		t.Errorf("Unexpected values found: %#v", toc)
+	}
+}
+
+func TestTableOfContents505Enhanced(t *testing.T) {
+	g1 := map[string]string{"tag": "g", "content": "1."}
+	t1 := map[string]string{"tag": "t", "content": "Chapter one /"}
+	r1 := map[string]string{"tag": "r", "content": "John Smith --"}
+	t2 := map[string]string{"tag": "t", "content": "Chapter two /"}
+	r2 := map[string]string{"tag": "r", "content": "Jane Doe"}
+	p2 := map[string]string{"tag": "g", "content": "(p. 25-40)."}
+	field := marc.MarcField{MarcTag: "505"}
+	field.Subfields = []map[string]string{g1, t1, r1, t2, r2, p2}
+	bib := Bib{VarFields: marc.MarcFields{field}}
+
+	toc := bib.TableOfContents()
+	// Notice that (like Traject) periods after short values are preserved
+	expected := `[{"label":"1.","title":"Chapter one","authors":["John Smith"]},` +
+		`{"title":"Chapter two","authors":["Jane Doe"],"page":"(p. 25-40)."}]`
+	if len(toc) != 1 || toc[0] != expected {
+		t.Errorf("Unexpected values found: %#v", toc)
+	}
+}
+
+func TestTableOfContents970(t *testing.T) {
+	l1 := map[string]string{"tag": "l", "content": "Chapter 1"}
+	t1 := map[string]string{"tag": "t", "content": "The beginning"}
+	c1 := map[string]string{"tag": "c", "content": "John Smith"}
+	p1 := map[string]string{"tag": "p", "content": "1"}
+	f1 := marc.MarcField{MarcTag: "970", Ind1: "1"}
+	f1.Subfields = []map[string]string{l1, t1, c1, p1}
+
+	t2 := map[string]string{"tag": "t", "content": "The end"}
+	p2 := map[string]string{"tag": "p", "content": "99"}
+	f2 := marc.MarcField{MarcTag: "970", Ind1: "2"}
+	f2.Subfields = []map[string]string{t2, p2}
+
+	// 970 without a title are ignored
+	l3 := map[string]string{"tag": "l", "content": "Index"}
+	f3 := marc.MarcField{MarcTag: "970", Ind1: "1"}
+	f3.Subfields = []map[string]string{l3}
+
+	bib := Bib{VarFields: marc.MarcFields{f1, f2, f3}}
+	toc := bib.TableOfContents970()
+	expected := `[{"label":"Chapter 1","indent":"1","title":"The beginning","authors":["John Smith"],"page":"1"},` +
+		`{"indent":"2","title":"The end","page":"99"}]`
+	if len(toc) != 1 || toc[0] != expected {
+		t.Errorf("Unexpected values found: %#v", toc)
+	}
+
+	empty := Bib{}
+	if len(empty.TableOfContents970()) != 0 || len(empty.TableOfContents()) != 0 {
+		t.Errorf("Unexpected table of contents for a record without 505/970")
+	}
+}
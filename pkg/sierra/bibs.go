@@ -18,17 +18,10 @@ type marcFileResp struct {
 	ErrorCount  int    `json:"errors"`
 }
 
-// GetBibs retrieves the information about of a BIB record and its ITEM information.
-//
-// params is meant to include a key like
-//	"id" : "the-id"						to fetch one record
-//	"id" : "[fromId,toId]"				to fetch by ID range
-//	"updatedDate": "[dateFrom,dateTo]"	to fetch by date range
-//
-// TODO: make these explicit parameters instead.
-func (s *Sierra) GetBibs(params map[string]string, includeItems bool) (Bibs, error) {
-	fields := "fields=default,available,orders,normTitle,normAuthor,locations,varFields,fixedFields"
-	body, err := s.GetRaw(params, fields)
+// GetBibs retrieves the information about the BIB records that match
+// the query and (optionally) their ITEM information.
+func (s *Sierra) GetBibs(query BibQuery, includeItems bool) (Bibs, error) {
+	body, err := s.GetRaw(query)
 	if err != nil {
 		return Bibs{}, err
 	}
@@ -39,37 +32,33 @@ func (s *Sierra) GetBibs(params map[string]string, includeItems bool) (Bibs, err
 		return Bibs{}, err
 	}
 
-	for i, bib := range bibs.Entries {
-		if bib.Deleted {
-			continue
+	if includeItems {
+		ids := []string{}
+		for _, bib := range bibs.Entries {
+			if !bib.Deleted {
+				ids = append(ids, bib.Id)
+			}
+		}
+		items, err := s.ItemsForBibs(ids)
+		if err != nil {
+			return Bibs{}, err
 		}
-		if includeItems {
-			// TODO: Figure out a way to get items in batch.
-			//			 In a previous attempt I tried passing the BIBs as a comma
-			//			 delimited string but that did not work if any of the BIBs
-			//			 was deleted. Need to revisit this.
-			//
-			items, err := s.Items(bib.Id)
-			if err != nil {
-				// TODO: Figure out why some records return "404 not found"
-				// even though they have not been deleted (and I think they
-				// do have items)
-				errorMsg := fmt.Sprintf("Error fetching items for %s", bib.Id)
-				s.log(errorMsg, err.Error())
+		for i, bib := range bibs.Entries {
+			if !bib.Deleted {
+				bibs.Entries[i].Items = items.ForBib(bib.Id)
 			}
-			bibs.Entries[i].Items = items.ForBib(bib.Id)
 		}
 	}
-	return bibs, err
+	return bibs, nil
 }
 
 // GetBibsMinimal fetches minimal information about the records,
 // we could eventually return an []string but I need to
 // decide how to handle deleted records in that case.
-func (s *Sierra) GetBibsMinimal(params map[string]string) (Bibs, error) {
+func (s *Sierra) GetBibsMinimal(query BibQuery) (Bibs, error) {
 	// TODO: could I use "id,deleted"?
-	fields := "fields=default"
-	body, err := s.GetRaw(params, fields)
+	query.Fields = []string{"default"}
+	body, err := s.GetRaw(query)
 	if err != nil {
 		return Bibs{}, err
 	}
@@ -81,13 +70,8 @@ func (s *Sierra) GetBibsMinimal(params map[string]string) (Bibs, error) {
 
 // BibsUpdatedSince returns an array of BIB record updated since a given date.
 func (s *Sierra) BibsUpdatedSince(date string) (Bibs, error) {
-	err := s.authenticate()
-	if err != nil {
-		return Bibs{}, err
-	}
-
-	url := s.URL + "/bibs?updatedDate=" + date
-	body, err := s.httpGet(url, s.Authorization.AccessToken)
+	query := BibQuery{UpdatedFrom: date, Fields: []string{"default"}}
+	body, err := s.apiGet(query.URL(s.URL))
 	if err != nil {
 		return Bibs{}, err
 	}
@@ -115,22 +99,9 @@ func (s *Sierra) BibIDForItemID(itemID string) (string, error) {
 	return item.BibIds[0], nil
 }
 
-func (s *Sierra) GetRaw(params map[string]string, fields string) (string, error) {
-	err := s.authenticate()
-	if err != nil {
-		return "", err
-	}
-
-	if fields == "" {
-		fields = "fields=default,available,orders,normTitle,normAuthor,locations,varFields,fixedFields"
-	}
-
-	url := s.URL + "/bibs?"
-	for key, value := range params {
-		url += key + "=" + value + "&"
-	}
-	url += fields
-	return s.httpGet(url, s.Authorization.AccessToken)
+// GetRaw returns the raw response (a string) from Sierra for the query.
+func (s *Sierra) GetRaw(query BibQuery) (string, error) {
+	return s.apiGet(query.URL(s.URL))
 }
 
 // Marc fetches the MARC data for a given range of IDs.
@@ -142,11 +113,6 @@ func (s *Sierra) GetRaw(params map[string]string, fields string) (string, error)
 // chokes when the list is to long (e.g. it fails with 50 IDs).
 // It works OK with large ranges, though.
 func (s *Sierra) Marc(idRange string, limit int, toc bool) (string, error) {
-	err := s.authenticate()
-	if err != nil {
-		return "", err
-	}
-
 	// The default export table in Sierra ("b2mtab") does not include the table
 	// of contents information (MARC 970). The "b2mtab.toc" export table includes
 	// this data. By passing the suffix "toc" to the API we indicate Sierra to
@@ -159,7 +125,7 @@ func (s *Sierra) Marc(idRange string, limit int, toc bool) (string, error) {
 		url += fmt.Sprintf("&limit=%d", limit)
 	}
 
-	body, err := s.httpGet(url, s.Authorization.AccessToken)
+	body, err := s.apiGet(url)
 	if err != nil {
 		return body, err
 	}
@@ -170,7 +136,7 @@ func (s *Sierra) Marc(idRange string, limit int, toc bool) (string, error) {
 		return "", err
 	}
 
-	data, err := s.httpGet(marcFile.File, s.Authorization.AccessToken)
+	data, err := s.apiGet(marcFile.File)
 	return data, err
 }
 
@@ -178,29 +144,17 @@ func (s *Sierra) Marc(idRange string, limit int, toc bool) (string, error) {
 // but in my testing it always returns "HTTP 403 Forbidden".
 //
 // func (s *Sierra) MarcDelete() (string, error) {
-// 	err := s.authenticate()
-// 	if err != nil {
-// 		return "", err
-// 	}
-//
 // 	url := s.URL + "/bibs/marc"
-// 	body, err := s.httpDelete(url, s.Authorization.AccessToken)
-// 	return body, err
+// 	return s.apiRequest("DELETE", url)
 // }
 
-func (s *Sierra) Deleted(dateRange string) (string, error) {
-	err := s.authenticate()
-	if err != nil {
-		return "", err
-	}
-
-	url := s.URL
-	if dateRange == "" {
-		url += "/bibs?deleted=true"
-	} else {
-		// TODO: validate dateRange is in the form a,b
-		url += fmt.Sprintf("/bibs?deletedDate=[%s]", dateRange)
+// Deleted returns the raw response (a string) from Sierra with the BIB
+// records deleted in the given date range. If no date range is given it
+// returns all deleted records.
+func (s *Sierra) Deleted(fromDate, toDate string) (string, error) {
+	query := BibQuery{DeletedFrom: fromDate, DeletedTo: toDate, Fields: []string{"default"}}
+	if fromDate == "" && toDate == "" {
+		query.Deleted = true
 	}
-	body, err := s.httpGet(url, s.Authorization.AccessToken)
-	return body, err
+	return s.apiGet(query.URL(s.URL))
 }
@@ -1,7 +1,13 @@
 package sierra
 
 import (
+	"bibService/pkg/metrics"
+	"database/sql"
+	"log"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 var buildings map[string]string
@@ -43,14 +49,182 @@ func init() {
 	}
 }
 
+// Location maps a Sierra location code to its display name and building.
+type Location struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Building string `json:"building"`
+	Online   bool   `json:"online"`
+}
+
+// UnmappedLocation is a location code seen in the item data that is not in
+// the locations table. Building is the building guessed for it from the
+// built-in mappings (if any).
+type UnmappedLocation struct {
+	Code      string    `json:"code"`
+	Building  string    `json:"building"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Locations loaded via SetLocations (nil when only the built-in mappings
+// are used) and the codes seen that are not in them.
+var locations map[string]Location
+var unmappedLocations = map[string]*UnmappedLocation{}
+var locationsMutex sync.RWMutex
+
+// SetLocations replaces the locations table used to find the building
+// for a location code. The built-in mappings are still used for the codes
+// not in the table.
+func SetLocations(values []Location) {
+	table := map[string]Location{}
+	for _, location := range values {
+		code := strings.ToLower(strings.TrimSpace(location.Code))
+		if code != "" {
+			location.Code = code
+			table[code] = location
+		}
+	}
+
+	locationsMutex.Lock()
+	defer locationsMutex.Unlock()
+	locations = table
+	for code := range unmappedLocations {
+		if _, ok := table[code]; ok {
+			delete(unmappedLocations, code)
+		}
+	}
+}
+
+// LookupLocation returns the location for a code from the locations table.
+func LookupLocation(code string) (Location, bool) {
+	locationsMutex.RLock()
+	defer locationsMutex.RUnlock()
+	location, ok := locations[strings.ToLower(code)]
+	return location, ok
+}
+
+// BuiltInBuildings returns the names of the buildings in the built-in
+// mappings (sorted).
+func BuiltInBuildings() []string {
+	names := []string{}
+	for _, name := range buildings {
+		if !in(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isOnlineLocation returns true for the locations of online resources. For
+// codes not in the locations table we assume that the ones that start with
+// "es" (electronic resources) are online.
+func isOnlineLocation(code string) bool {
+	if location, ok := LookupLocation(code); ok {
+		return location.Online
+	}
+	return strings.HasPrefix(strings.ToLower(code), "es")
+}
+
+// UnmappedLocations returns the location codes seen since the given time
+// that are not in the locations table (or in the built-in mappings when
+// no table has been loaded).
+func UnmappedLocations(since time.Time) []UnmappedLocation {
+	locationsMutex.RLock()
+	defer locationsMutex.RUnlock()
+	values := []UnmappedLocation{}
+	for _, unmapped := range unmappedLocations {
+		if !unmapped.LastSeen.Before(since) {
+			values = append(values, *unmapped)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Code < values[j].Code })
+	return values
+}
+
 func buildingName(code string) string {
 	if code == "" {
 		return ""
 	}
 	code = strings.ToLower(code)
+	locationsMutex.RLock()
+	location, inTable := locations[code]
+	loaded := locations != nil
+	locationsMutex.RUnlock()
+	if inTable && location.Building != "" {
+		return location.Building
+	}
+
+	// fall back to the built-in mappings
 	name := buildings[code]
+	mapped := inTable || (name != "" && !loaded)
 	if name == "" {
 		name = buildings[code[0:1]]
 	}
+	if !mapped {
+		recordUnmapped(code, name)
+	}
 	return name
 }
+
+func recordUnmapped(code string, building string) {
+	now := time.Now()
+	locationsMutex.Lock()
+	defer locationsMutex.Unlock()
+	unmapped, ok := unmappedLocations[code]
+	if !ok {
+		unmapped = &UnmappedLocation{Code: code, FirstSeen: now}
+		unmappedLocations[code] = unmapped
+	}
+	unmapped.Building = building
+	unmapped.Count++
+	unmapped.LastSeen = now
+}
+
+// LocationsFromDB fetches the locations from the Sierra database. The
+// building for each location is the name of its branch. The database does
+// not indicate which locations are online so, like for the codes not in
+// the table, the ones that start with "es" are flagged as online.
+func LocationsFromDB(connString string) ([]Location, error) {
+	log.Printf("Connecting to Sierra DB")
+	db, err := sql.Open("postgres", connString)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	sqlSelect := `
+	SELECT l.code, COALESCE(ln.name, ''), COALESCE(bn.name, '')
+	FROM sierra_view.location AS l
+		LEFT OUTER JOIN sierra_view.location_name AS ln ON ln.location_id = l.id
+		LEFT OUTER JOIN sierra_view.branch AS b ON b.code_num = l.branch_code_num
+		LEFT OUTER JOIN sierra_view.branch_name AS bn ON bn.branch_id = b.id
+	ORDER BY l.code`
+	log.Printf("Running query: \r\n%s\r\n", sqlSelect)
+
+	started := time.Now()
+	rows, err := db.Query(sqlSelect)
+	if err != nil {
+		metrics.ObserveSQL("locations", started, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []Location{}
+	for rows.Next() {
+		var location Location
+		err = rows.Scan(&location.Code, &location.Name, &location.Building)
+		if err != nil {
+			break
+		}
+		location.Online = strings.HasPrefix(strings.ToLower(location.Code), "es")
+		values = append(values, location)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	metrics.ObserveSQL("locations", started, err)
+	return values, err
+}
@@ -0,0 +1,59 @@
+package sierra
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildingNameWithLocations(t *testing.T) {
+	defer func() {
+		locations = nil
+		unmappedLocations = map[string]*UnmappedLocation{}
+	}()
+
+	started := time.Now()
+	if buildingName("rstk") != "Rockefeller" || buildingName("cass") != "Rockefeller" {
+		t.Errorf("Unexpected built-in mappings")
+	}
+	unmapped := UnmappedLocations(started)
+	if len(unmapped) != 1 || unmapped[0].Code != "rstk" || unmapped[0].Building != "Rockefeller" {
+		t.Errorf("Unexpected unmapped locations: %v", unmapped)
+	}
+
+	SetLocations([]Location{
+		{Code: "RSTK", Name: "Rockefeller Stacks", Building: "Rockefeller Library"},
+		{Code: "es001", Name: "Online", Online: true},
+	})
+	if buildingName("rstk") != "Rockefeller Library" {
+		t.Errorf("Locations table not used: %s", buildingName("rstk"))
+	}
+	if location, ok := LookupLocation("ES001"); !ok || !location.Online {
+		t.Errorf("Location not found: %v", location)
+	}
+	item := Item{Location: map[string]string{"code": "rstk", "name": "ROCK STACKS"}}
+	if item.LocationName() != "Rockefeller Stacks" {
+		t.Errorf("Location name not taken from the table: %s", item.LocationName())
+	}
+	item.Location["code"] = "sci"
+	if item.LocationName() != "ROCK STACKS" {
+		t.Errorf("Location name from Sierra not used: %s", item.LocationName())
+	}
+	if !isOnlineLocation("es001") || isOnlineLocation("rstk") || !isOnlineLocation("es999") {
+		t.Errorf("Unexpected online locations")
+	}
+
+	// codes not in the table fall back to the built-in mappings
+	if buildingName("cass") != "Rockefeller" || buildingName("es001") != "" {
+		t.Errorf("Unexpected fallback")
+	}
+	codes := []string{}
+	for _, unmapped := range UnmappedLocations(started) {
+		codes = append(codes, unmapped.Code)
+	}
+	if len(codes) != 1 || codes[0] != "cass" {
+		t.Errorf("Unexpected unmapped locations: %v", codes)
+	}
+	if len(UnmappedLocations(time.Now().Add(time.Hour))) != 0 {
+		t.Errorf("Unexpected recent unmapped locations")
+	}
+}
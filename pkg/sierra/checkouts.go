@@ -39,13 +39,8 @@ func (e CheckoutEntry) IsBorrowDirect() bool {
 
 // Checkouts returns the checkout information for the given patron ID.
 func (s *Sierra) Checkouts(patronID string) (Checkouts, error) {
-	err := s.authenticate()
-	if err != nil {
-		return Checkouts{}, err
-	}
-
 	url := s.URL + "/patrons/" + patronID + "/checkouts"
-	body, err := s.httpGet(url, s.Authorization.AccessToken)
+	body, err := s.apiGet(url)
 	if err != nil {
 		return Checkouts{}, err
 	}
@@ -1,11 +1,13 @@
 package sierra
 
 import (
+	"bibService/pkg/metrics"
 	"database/sql"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -64,13 +66,13 @@ type CollectionItemRow struct {
 }
 
 func (row CollectionItemRow) String() string {
-	s := fmt.Sprintf("%s, %s, %s", row.BibRecordNum, row.ItemRecordNum, row.Title)
+	s := fmt.Sprintf("%d, %d, %s", row.BibRecordNum, row.ItemRecordNum, row.Title)
 	return s
 }
 
 // Returns the name of a Sierra List
 func CollectionName(connString string, listID int) (string, error) {
-	log.Printf("Connecting to DB: %s", connString)
+	log.Printf("Connecting to Sierra DB")
 	db, err := sql.Open("postgres", connString)
 	if err != nil {
 		return "", err
@@ -80,15 +82,17 @@ func CollectionName(connString string, listID int) (string, error) {
 	sqlSelect = strings.ReplaceAll(sqlSelect, "{listID}", strconv.Itoa(listID))
 	log.Printf("Running query: \r\n%s\r\n", sqlSelect)
 
+	started := time.Now()
 	row := db.QueryRow(sqlSelect)
 	var name string
 	err = row.Scan(&name)
+	metrics.ObserveSQL("collectionName", started, err)
 	return name, err
 }
 
 // Returns the items for a Sierra List
 func CollectionItemsForList(connString string, listID int) ([]CollectionItemRow, error) {
-	log.Printf("Connecting to DB: %s", connString)
+	log.Printf("Connecting to Sierra DB")
 	// https://godoc.org/github.com/lib/pq
 	db, err := sql.Open("postgres", connString)
 	if err != nil {
@@ -139,8 +143,10 @@ func CollectionItemsForList(connString string, listID int) ([]CollectionItemRow,
 	sqlSelect = strings.ReplaceAll(sqlSelect, "{listID}", strconv.Itoa(listID))
 	log.Printf("Running query: \r\n%s\r\n", sqlSelect)
 
+	started := time.Now()
 	rows, err := db.Query(sqlSelect)
 	if err != nil {
+		metrics.ObserveSQL("collectionItems", started, err)
 		return []CollectionItemRow{}, err
 	}
 	defer rows.Close()
@@ -150,6 +156,7 @@ func CollectionItemsForList(connString string, listID int) ([]CollectionItemRow,
 	for rows.Next() {
 		row, err := scanCollectionItemRow(rows)
 		if err != nil {
+			metrics.ObserveSQL("collectionItems", started, err)
 			return []CollectionItemRow{}, err
 		}
 		row.SierraList = listID
@@ -160,6 +167,7 @@ func CollectionItemsForList(connString string, listID int) ([]CollectionItemRow,
 			log.Printf("Fetched %d rows...", count)
 		}
 	}
+	metrics.ObserveSQL("collectionItems", started, nil)
 	log.Printf("Found %d rows\r\n", len(values))
 	return values, nil
 }
@@ -0,0 +1,186 @@
+package sierra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// APIError represents an error returned by the Sierra API. Sierra returns
+// errors as a JSON body in the form:
+//
+//	{"code":135,"specificCode":0,"httpStatus":500,"name":"External Process Failed","description":"..."}
+type APIError struct {
+	Code         int    `json:"code"`
+	SpecificCode int    `json:"specificCode"`
+	HttpStatus   int    `json:"httpStatus"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+}
+
+// newAPIError creates an APIError from the HTTP status and body of a
+// response. The body is not always JSON (e.g. when the error comes from
+// a proxy rather than Sierra) in which case we keep it as the description.
+func newAPIError(httpStatus int, body string) APIError {
+	var apiErr APIError
+	err := json.Unmarshal([]byte(body), &apiErr)
+	if err != nil {
+		apiErr = APIError{Description: strings.TrimSpace(body)}
+	}
+	if apiErr.HttpStatus == 0 {
+		apiErr.HttpStatus = httpStatus
+	}
+	return apiErr
+}
+
+// NewNotFoundError returns an APIError for records that were not found.
+// This is useful when Sierra returns an empty result set (rather than an
+// HTTP 404) but the caller expected to find a record.
+func NewNotFoundError(description string) APIError {
+	return APIError{HttpStatus: 404, Name: "Record not found", Description: description}
+}
+
+func (e APIError) Error() string {
+	if e.Name == "" && e.Description == "" {
+		return fmt.Sprintf("Status code %d", e.HttpStatus)
+	}
+	return fmt.Sprintf("Status code %d: %s (%d/%d) %s", e.HttpStatus, e.Name, e.Code, e.SpecificCode, e.Description)
+}
+
+// IsNotFound returns true if Sierra reported that the record(s) requested
+// were not found.
+func (e APIError) IsNotFound() bool {
+	return e.HttpStatus == 404
+}
+
+// IsUnauthorized returns true if Sierra rejected the access token.
+func (e APIError) IsUnauthorized() bool {
+	return e.HttpStatus == 401
+}
+
+// IsRateLimited returns true if Sierra reported that we have gone over the
+// number of requests allowed for an endpoint.
+func (e APIError) IsRateLimited() bool {
+	return e.HttpStatus == 429 || strings.Contains(e.Description, "Rate exceeded for endpoint")
+}
+
+// IsRetryable returns true for errors that are likely to go away if we
+// retry the request later.
+func (e APIError) IsRetryable() bool {
+	if e.IsRateLimited() {
+		return true
+	}
+	if e.Name == "External Process Failed" {
+		return true
+	}
+	return e.HttpStatus >= 500 && e.HttpStatus <= 599
+}
+
+// IsNotFound returns true if the error is a Sierra API "not found" error.
+func IsNotFound(err error) bool {
+	var apiErr APIError
+	return errors.As(err, &apiErr) && apiErr.IsNotFound()
+}
+
+// IsRetryable returns true if the error is a Sierra API error that is
+// worth retrying.
+func IsRetryable(err error) bool {
+	var apiErr APIError
+	return errors.As(err, &apiErr) && apiErr.IsRetryable()
+}
+
+// RetryPolicy indicates how many times and how often to retry a Sierra API
+// call that failed with a retryable error. The delay between retries starts
+// at InitialDelay and doubles on each attempt up to MaxDelay.
+//
+// Calls that hit the rate limit are only retried when RetryRateLimited is
+// set, waiting RateLimitDelay (when set) since the limit is not reset until
+// several minutes later. Otherwise they fail right away, which is what we
+// want when a client is waiting for the response.
+type RetryPolicy struct {
+	MaxRetries       int
+	InitialDelay     time.Duration
+	MaxDelay         time.Duration
+	RetryRateLimited bool
+	RateLimitDelay   time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is indicated.
+// It is meant for interactive requests so calls that hit the rate limit
+// are not retried.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:   5,
+		InitialDelay: 2 * time.Second,
+		MaxDelay:     time.Minute,
+	}
+}
+
+// BatchRetryPolicy returns the retry policy for long-running operations
+// (e.g. downloading all the MARC records) that are better off waiting for
+// the rate limit to reset than failing. When Sierra reports "Rate exceeded
+// for endpoint" it takes around 15 minutes to reset so we wait a bit longer
+// than that before retrying.
+func BatchRetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.RetryRateLimited = true
+	policy.RateLimitDelay = 16 * time.Minute
+	return policy
+}
+
+// Delay returns how long to wait before the given retry attempt (1-based).
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < attempt; i++ {
+		delay = delay * 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// delayFor returns how long to wait before retrying after the given error.
+func (p RetryPolicy) delayFor(err error, attempt int) time.Duration {
+	delay := p.Delay(attempt)
+	var apiErr APIError
+	if errors.As(err, &apiErr) && apiErr.IsRateLimited() && p.RateLimitDelay > delay {
+		return p.RateLimitDelay
+	}
+	return delay
+}
+
+// sleep is replaced in the tests to avoid waiting for real.
+var sleep = time.Sleep
+
+// shouldRetry returns true if a call that failed with the given error
+// must be retried.
+func (p RetryPolicy) shouldRetry(err error) bool {
+	var apiErr APIError
+	if errors.As(err, &apiErr) && apiErr.IsRateLimited() {
+		return p.RetryRateLimited
+	}
+	return IsRetryable(err)
+}
+
+// retry executes fn and retries it according to the policy as long
+// as it fails with a retryable error.
+func (p RetryPolicy) retry(info string, fn func() (string, error)) (string, error) {
+	attempt := 0
+	for {
+		body, err := fn()
+		if err == nil || !p.shouldRetry(err) || attempt >= p.MaxRetries {
+			return body, err
+		}
+		attempt++
+		delay := p.delayFor(err, attempt)
+		log.Printf("Retrying %s in %s (attempt %d of %d): %s", info, delay, attempt, p.MaxRetries, err)
+		sleep(delay)
+	}
+}
@@ -0,0 +1,128 @@
+package sierra
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIError(t *testing.T) {
+	body := `{"code":135,"specificCode":0,"httpStatus":500,"name":"External Process Failed","description":"bib2Marc process failed"}`
+	err := newAPIError(500, body)
+	if err.Code != 135 || err.Name != "External Process Failed" || err.Description != "bib2Marc process failed" {
+		t.Errorf("Unexpected values: %#v", err)
+	}
+	if !err.IsRetryable() {
+		t.Errorf("External Process Failed not detected as retryable")
+	}
+
+	err = newAPIError(403, `{"code":0,"specificCode":0,"name":"Rate exceeded for endpoint","description":"Rate exceeded for endpoint"}`)
+	if !err.IsRateLimited() || !err.IsRetryable() || err.HttpStatus != 403 {
+		t.Errorf("Rate limit not detected: %#v", err)
+	}
+
+	err = newAPIError(404, "Not found")
+	if !IsNotFound(err) || IsRetryable(err) || err.Description != "Not found" {
+		t.Errorf("Not found not detected: %#v", err)
+	}
+
+	if IsNotFound(fmt.Errorf("Status code 404")) {
+		t.Errorf("Plain errors should not be considered API errors")
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 10, InitialDelay: time.Second, MaxDelay: 5 * time.Second}
+	if p.Delay(1) != time.Second || p.Delay(2) != 2*time.Second || p.Delay(3) != 4*time.Second {
+		t.Errorf("Unexpected delays: %s, %s, %s", p.Delay(1), p.Delay(2), p.Delay(3))
+	}
+	if p.Delay(4) != 5*time.Second || p.Delay(20) != 5*time.Second {
+		t.Errorf("Delay not capped: %s, %s", p.Delay(4), p.Delay(20))
+	}
+}
+
+func TestHttpGetRetry(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	defer server.Close()
+
+	s := NewSierra(server.URL, "", "")
+	s.Retry = RetryPolicy{MaxRetries: 2, InitialDelay: time.Millisecond}
+	body, err := s.httpGet(server.URL, "")
+	if err != nil || body != "OK" || calls != 3 {
+		t.Errorf("Unexpected result: %s, %v, %d", body, err, calls)
+	}
+
+	calls = 0
+	s.Retry = RetryPolicy{MaxRetries: 1, InitialDelay: time.Millisecond}
+	_, err = s.httpGet(server.URL, "")
+	if err == nil || calls != 2 {
+		t.Errorf("Expected error after retries: %v, %d", err, calls)
+	}
+}
+
+func TestRateLimitedRetry(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"code":0,"httpStatus":403,"name":"Forbidden","description":"Rate exceeded for endpoint"}`)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	defer server.Close()
+
+	slept := []time.Duration{}
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	// interactive requests fail right away rather than waiting for the
+	// rate limit to reset
+	s := NewSierra(server.URL, "", "")
+	s.Retry = DefaultRetryPolicy()
+	_, err := s.httpGet(server.URL, "")
+	var apiErr APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsRateLimited() || calls != 1 || len(slept) != 0 {
+		t.Errorf("Expected rate limit error without retries: %v, %d, %v", err, calls, slept)
+	}
+
+	// batch requests wait past the ~15 minutes that the rate limit takes to
+	// reset rather than giving up after the regular (short) backoff
+	calls = 0
+	s.Retry = BatchRetryPolicy()
+	body, err := s.httpGet(server.URL, "")
+	if err != nil || body != "OK" || calls != 2 {
+		t.Errorf("Unexpected result: %s, %v, %d", body, err, calls)
+	}
+	if len(slept) != 1 || slept[0] <= 15*time.Minute {
+		t.Errorf("Did not wait for the rate limit to reset: %v", slept)
+	}
+
+	// without a rate limit delay the regular backoff is used
+	calls = 0
+	slept = []time.Duration{}
+	s.Retry.RateLimitDelay = 0
+	s.httpGet(server.URL, "")
+	if len(slept) != 1 || slept[0] != s.Retry.InitialDelay {
+		t.Errorf("Unexpected delay without a rate limit delay: %v", slept)
+	}
+}
+
+func TestNewNotFoundError(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", NewNotFoundError("No BIB was found for ID 123"))
+	if !IsNotFound(err) || IsRetryable(err) {
+		t.Errorf("Unexpected classification for not found error: %s", err)
+	}
+}
@@ -0,0 +1,59 @@
+package sierra
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Fines represents the result from the Sierra API /v5/patrons/{id}/fines endpoint
+type Fines struct {
+	Total   int         `json:"total"`
+	Entries []FineEntry `json:"entries"`
+}
+
+// FineEntry represents an individual entry in the Sierra API /v5/patrons/{id}/fines endpoint
+type FineEntry struct {
+	ID            string            `json:"id"`
+	Patron        string            `json:"patron"`
+	ItemURL       string            `json:"item"`
+	AssessedDate  string            `json:"assessedDate"`
+	Description   string            `json:"description"`
+	ChargeType    map[string]string `json:"chargeType"`
+	ItemCharge    float64           `json:"itemCharge"`
+	ProcessingFee float64           `json:"processingFee"`
+	BillingFee    float64           `json:"billingFee"`
+	PaidAmount    float64           `json:"paidAmount"`
+}
+
+// ItemID returns the ID of the item the fine is for (empty for fines not
+// related to an item, e.g. a replacement card).
+func (e FineEntry) ItemID() string {
+	if e.ItemURL == "" {
+		return ""
+	}
+	return lastSegment(e.ItemURL)
+}
+
+// IsBorrowDirect returns true if the fine is for a Borrow Direct item
+// (see CheckoutEntry.IsBorrowDirect)
+func (e FineEntry) IsBorrowDirect() bool {
+	return strings.Contains(e.ItemID(), "@")
+}
+
+// Amount returns the amount owed for the fine (charges minus payments).
+func (e FineEntry) Amount() float64 {
+	return e.ItemCharge + e.ProcessingFee + e.BillingFee - e.PaidAmount
+}
+
+// Fines returns the fines for the given patron ID.
+func (s *Sierra) Fines(patronID string) (Fines, error) {
+	url := s.URL + "/patrons/" + patronID + "/fines"
+	body, err := s.apiGet(url)
+	if err != nil {
+		return Fines{}, err
+	}
+
+	var fines Fines
+	err = json.Unmarshal([]byte(body), &fines)
+	return fines, err
+}
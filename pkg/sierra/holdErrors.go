@@ -0,0 +1,106 @@
+package sierra
+
+import (
+	"errors"
+	"strings"
+)
+
+// Reasons why a hold request (or a change to a hold) was rejected.
+const (
+	HoldReasonDuplicate      = "duplicate"      // already on hold for (or checked out to) the patron
+	HoldReasonNotRequestable = "notRequestable" // the item (or none of the items in the BIB) can be requested
+	HoldReasonPatronBlocked  = "patronBlocked"  // the patron is blocked (e.g. fines, expired card)
+	HoldReasonLimitReached   = "limitReached"   // the patron has reached the max number of holds
+	HoldReasonInvalidPickup  = "invalidPickup"  // invalid pickup location
+	HoldReasonInvalidRequest = "invalidRequest" // e.g. the item does not belong to the BIB
+	HoldReasonNotFound       = "notFound"       // the BIB, item, or hold does not exist
+	HoldReasonNotModifiable  = "notModifiable"  // e.g. the item is already waiting on the hold shelf
+	HoldReasonSierraRejected = "sierraRejected" // any other reason given by Sierra
+)
+
+// HoldError is returned when a hold request (or a change to a hold) is
+// rejected, either by our eligibility checks or by Sierra. Reason is one of
+// the HoldReason values so that clients can act on it (e.g. show a specific
+// message) and Sierra has the original error when it came from Sierra.
+type HoldError struct {
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+	Sierra  *APIError `json:"sierra,omitempty"`
+}
+
+func (e HoldError) Error() string {
+	return e.Message
+}
+
+// Unwrap allows callers to get to the Sierra error (e.g. via errors.As)
+func (e HoldError) Unwrap() error {
+	if e.Sierra == nil {
+		return nil
+	}
+	return *e.Sierra
+}
+
+// NewHoldError returns a HoldError for a request that we rejected.
+func NewHoldError(reason string, message string) HoldError {
+	return HoldError{Reason: reason, Message: message}
+}
+
+// Code of the errors reported by Sierra's circulation module (XCirc)
+const xcircErrorCode = 132
+
+// Sierra does not use specific codes for the reasons a hold is rejected,
+// they are only indicated in the description of the error, e.g.
+// "XCirc error : Request denied - already on hold for or checked out to you."
+var holdReasons = []struct {
+	text   string
+	reason string
+}{
+	{"already on hold", HoldReasonDuplicate},
+	{"checked out to you", HoldReasonDuplicate},
+	{"already requested", HoldReasonDuplicate},
+	{"no requestable items", HoldReasonNotRequestable},
+	{"not requestable", HoldReasonNotRequestable},
+	{"cannot be requested", HoldReasonNotRequestable},
+	{"problem with your library record", HoldReasonPatronBlocked},
+	{"patron blocked", HoldReasonPatronBlocked},
+	{"expired", HoldReasonPatronBlocked},
+	{"max number of holds", HoldReasonLimitReached},
+	{"maximum number of holds", HoldReasonLimitReached},
+	{"hold limit", HoldReasonLimitReached},
+	{"pickup location", HoldReasonInvalidPickup},
+}
+
+// newHoldError converts an error from Sierra into a HoldError with the
+// reason that it was rejected. Errors that did not come from Sierra (e.g.
+// network errors) are returned as-is, as are Sierra errors that are not
+// about the request (e.g. rate limiting or an outage).
+func newHoldError(err error) error {
+	var apiErr APIError
+	if err == nil || !errors.As(err, &apiErr) {
+		return err
+	}
+	// Sierra rejects holds with an HTTP 500 "XCirc error" so we cannot
+	// rely on the status to tell a rejection from an outage.
+	isXCirc := apiErr.Code == xcircErrorCode || strings.HasPrefix(apiErr.Name, "XCirc")
+	if !isXCirc && (apiErr.IsRetryable() || apiErr.IsUnauthorized()) {
+		return err
+	}
+
+	message := strings.TrimSpace(strings.TrimPrefix(apiErr.Description, "XCirc error :"))
+	if message == "" {
+		message = apiErr.Name
+	}
+	holdErr := HoldError{Reason: HoldReasonSierraRejected, Message: message, Sierra: &apiErr}
+	if apiErr.IsNotFound() {
+		holdErr.Reason = HoldReasonNotFound
+		return holdErr
+	}
+	description := strings.ToLower(apiErr.Description)
+	for _, candidate := range holdReasons {
+		if strings.Contains(description, candidate.text) {
+			holdErr.Reason = candidate.reason
+			break
+		}
+	}
+	return holdErr
+}
@@ -0,0 +1,225 @@
+package sierra
+
+import (
+	"bibService/pkg/marc"
+	"encoding/json"
+	"strings"
+)
+
+// Holding represents a Sierra holdings (checkin) record. These are used
+// for serials and include the MARC holdings fields (853/863 and 866-868)
+// that describe what issues the library has.
+type Holding struct {
+	Id          string            `json:"id"`
+	UpdatedDate string            `json:"updatedDate"`
+	CreatedDate string            `json:"createdDate"`
+	Deleted     bool              `json:"deleted"`
+	Suppressed  bool              `json:"suppressed"`
+	BibIds      []string          `json:"bibIds"`
+	Bibs        []string          `json:"bibs"` // links to the BIBs (e.g. ".../bibs/1000001")
+	Location    map[string]string `json:"location"`
+	Fields      []marc.MarcField  `json:"varFields"`
+}
+
+// Holdings represents a collection of Sierra holdings records.
+type Holdings struct {
+	Total   int       `json:"total"`
+	Entries []Holding `json:"entries"`
+}
+
+// Holdings fetches the holdings records for a comma delimited list of
+// Bib IDs.
+func (s *Sierra) Holdings(bibsList string) (Holdings, error) {
+	body, err := s.HoldingsRaw(bibsList)
+	if err != nil {
+		if IsNotFound(err) {
+			// Sierra returns "404 not found" when none of the BIBs have holdings.
+			return Holdings{}, nil
+		}
+		return Holdings{}, err
+	}
+
+	var holdings Holdings
+	err = json.Unmarshal([]byte(body), &holdings)
+	return holdings, err
+}
+
+// HoldingsRaw returns the raw holdings information (a string) for a comma
+// delimited list of Bib IDs.
+func (s *Sierra) HoldingsRaw(bibsList string) (string, error) {
+	url := s.URL + "/holdings?bibIds=" + bibsList
+	url += "&fields=default,varFields,fixedFields"
+	return s.apiGet(url)
+}
+
+// ForBib returns the holdings that belong to the specified BIB (excluding
+// the deleted and suppressed ones).
+func (holdings Holdings) ForBib(bib string) []Holding {
+	bibHoldings := []Holding{}
+	for _, holding := range holdings.Entries {
+		if holding.IsForBib(bib) && !holding.Deleted && !holding.Suppressed {
+			bibHoldings = append(bibHoldings, holding)
+		}
+	}
+	return bibHoldings
+}
+
+// IsForBib returns true if the holding belongs to the BIB ID passed.
+func (h Holding) IsForBib(bib string) bool {
+	for _, b := range h.BibIds {
+		if b == bib {
+			return true
+		}
+	}
+	for _, link := range h.Bibs {
+		if link == bib || strings.HasSuffix(link, "/"+bib) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Holding) LocationName() string {
+	return h.Location["name"]
+}
+
+// Statements returns the holdings statements in a human readable form,
+// e.g. "v.1-45 (1990-2020)". Statements are built from the captions (853)
+// and enumeration/chronology (863) pairs and from the textual holdings
+// (866 for the main run, 867 for supplements, and 868 for indexes).
+func (h Holding) Statements() []string {
+	statements := []string{}
+	captions := map[string]marc.MarcField{}
+	for _, field := range h.Fields {
+		if field.MarcTag == "853" {
+			captions[holdingsLink(field)] = field
+		}
+	}
+
+	for _, field := range h.Fields {
+		statement := ""
+		switch field.MarcTag {
+		case "863":
+			statement = enumerationStatement(captions[holdingsLink(field)], field)
+		case "866":
+			statement = field.StringFor("a")
+		case "867":
+			statement = prefixStatement("Supplements: ", field.StringFor("a"))
+		case "868":
+			statement = prefixStatement("Indexes: ", field.StringFor("a"))
+		}
+		statement = strings.TrimSpace(statement)
+		if statement == "" {
+			continue
+		}
+		if note := field.StringFor("z"); note != "" {
+			statement += " (" + note + ")"
+		}
+		safeAppend(&statements, statement)
+	}
+	return statements
+}
+
+// holdingsLink returns the link number that pairs an 863 with its 853,
+// e.g. "1" for a $8 of "1.3"
+func holdingsLink(field marc.MarcField) string {
+	link := field.StringFor("8")
+	if i := strings.Index(link, "."); i != -1 {
+		return link[:i]
+	}
+	return link
+}
+
+func prefixStatement(prefix string, value string) string {
+	if strings.TrimSpace(value) == "" {
+		return ""
+	}
+	return prefix + value
+}
+
+// enumerationStatement builds the statement for an 863 field using the
+// captions in its 853, e.g. $a "v." $i "(year)" and $a "1-45" $i "1990-2020"
+// become "v.1-45 (1990-2020)".
+func enumerationStatement(captions marc.MarcField, field marc.MarcField) string {
+	enumeration := holdingsLevels(captions, field, "abcdef", false)
+	chronology := holdingsLevels(captions, field, "ijkl", true)
+	if chronology == "" {
+		return enumeration
+	}
+	if enumeration == "" {
+		return chronology
+	}
+	return enumeration + " (" + chronology + ")"
+}
+
+// holdingsLevels formats the given levels (subfields) of an 863 field. A
+// single level is shown as "v.1-45" and several levels as
+// "v.1:no.1-v.45:no.12" (or "v.46:no.1-12" when only the last level
+// changes). Open ranges are shown as "v.1-".
+func holdingsLevels(captions marc.MarcField, field marc.MarcField, tags string, isChronology bool) string {
+	starts := []string{}
+	ends := []string{}
+	endValues := []string{}
+	ranged := false
+	for _, tag := range strings.Split(tags, "") {
+		value := field.StringFor(tag)
+		if value == "" {
+			continue
+		}
+		caption := holdingsCaption(captions.StringFor(tag))
+		start, end := value, value
+		if i := strings.Index(value, "-"); i != -1 {
+			start, end = value[:i], value[i+1:]
+			ranged = true
+		}
+		if isChronology && tag == "j" {
+			start, end = monthName(start), monthName(end)
+		}
+		starts = append(starts, caption+start)
+		if end != "" {
+			ends = append(ends, caption+end)
+			endValues = append(endValues, end)
+		}
+	}
+
+	switch {
+	case len(starts) == 0:
+		return ""
+	case !ranged:
+		return strings.Join(starts, ":")
+	case len(ends) < len(starts):
+		return strings.Join(starts, ":") + "-"
+	}
+
+	// when only the last level changes show just its end value, e.g.
+	// "v.46:no.1-12" rather than "v.46:no.1-v.46:no.12"
+	last := len(starts) - 1
+	if strings.Join(starts[:last], ":") == strings.Join(ends[:last], ":") {
+		return strings.Join(starts, ":") + "-" + endValues[last]
+	}
+	return strings.Join(starts, ":") + "-" + strings.Join(ends, ":")
+}
+
+// holdingsCaption returns the caption to display for a level. Captions in
+// parentheses (e.g. "(year)") are not displayed.
+func holdingsCaption(caption string) string {
+	caption = strings.TrimSpace(caption)
+	if strings.HasPrefix(caption, "(") && strings.HasSuffix(caption, ")") {
+		return ""
+	}
+	return caption
+}
+
+var monthNames = map[string]string{
+	"01": "Jan.", "02": "Feb.", "03": "Mar.", "04": "Apr.", "05": "May", "06": "June",
+	"07": "July", "08": "Aug.", "09": "Sept.", "10": "Oct.", "11": "Nov.", "12": "Dec.",
+	"21": "Spring", "22": "Summer", "23": "Autumn", "24": "Winter",
+}
+
+// monthName returns the name for a month (or season) code in an 863 $j
+func monthName(code string) string {
+	if name, ok := monthNames[code]; ok {
+		return name
+	}
+	return code
+}
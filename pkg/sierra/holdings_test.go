@@ -0,0 +1,78 @@
+package sierra
+
+import (
+	"bibService/pkg/marc"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func holdingsField(tag string, values ...string) marc.MarcField {
+	field := marc.MarcField{MarcTag: tag}
+	for i := 0; i < len(values); i += 2 {
+		field.Subfields = append(field.Subfields, map[string]string{"tag": values[i], "content": values[i+1]})
+	}
+	return field
+}
+
+func TestHoldingStatements(t *testing.T) {
+	holding := Holding{Fields: []marc.MarcField{
+		holdingsField("853", "8", "1", "a", "v.", "b", "no.", "i", "(year)", "j", "(month)"),
+		holdingsField("853", "8", "2", "a", "v.", "i", "(year)"),
+		holdingsField("863", "8", "2.1", "a", "1-45", "i", "1990-2020"),
+		holdingsField("863", "8", "1.1", "a", "46", "b", "1-12", "i", "2021", "j", "01-12"),
+		holdingsField("863", "8", "1.2", "a", "10-12", "b", "1-6", "i", "1999-2001"),
+		holdingsField("863", "8", "2.2", "a", "47-", "i", "2022-", "z", "Current issues in the reading room"),
+		holdingsField("866", "a", "v.1-45 (1990-2020)"),
+		holdingsField("868", "a", "v.1-40"),
+	}}
+	expected := []string{
+		"v.1-45 (1990-2020)",
+		"v.46:no.1-12 (2021:Jan.-Dec.)",
+		"v.10:no.1-v.12:no.6 (1999-2001)",
+		"v.47- (2022-) (Current issues in the reading room)",
+		"Indexes: v.1-40",
+	}
+	statements := holding.Statements()
+	if strings.Join(statements, "|") != strings.Join(expected, "|") {
+		t.Errorf("Unexpected statements:\n%s", strings.Join(statements, "\n"))
+	}
+}
+
+func TestHoldingsForBib(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+			return
+		}
+		if r.URL.Query().Get("bibIds") == "2" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"code":107,"httpStatus":404,"name":"Record not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"total":3,"entries":[
+			{"id":"1","bibs":["https://sierra.example.edu/v5/bibs/1000001"],"location":{"code":"rstk","name":"Rockefeller Stacks"}},
+			{"id":"2","bibIds":["1000001"],"suppressed":true},
+			{"id":"3","bibIds":["1000009"]}]}`)
+	}))
+	defer server.Close()
+
+	s := NewSierra(server.URL, "key:secret", "")
+	s.Retry = RetryPolicy{MaxRetries: 0, InitialDelay: time.Millisecond}
+	holdings, err := s.Holdings("1000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forBib := holdings.ForBib("1000001")
+	if len(forBib) != 1 || forBib[0].LocationName() != "Rockefeller Stacks" {
+		t.Errorf("Unexpected holdings for BIB: %v", forBib)
+	}
+
+	holdings, err = s.Holdings("2")
+	if err != nil || len(holdings.Entries) != 0 {
+		t.Errorf("Unexpected result for BIB without holdings: %v, %v", holdings, err)
+	}
+}
@@ -0,0 +1,129 @@
+package sierra
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Holds represents the result from the Sierra API /v5/patrons/{id}/holds endpoint
+type Holds struct {
+	Total   int         `json:"total"`
+	Entries []HoldEntry `json:"entries"`
+}
+
+// HoldEntry represents an individual entry in the Sierra API /v5/patrons/{id}/holds endpoint
+type HoldEntry struct {
+	ID                  string            `json:"id"`
+	RecordURL           string            `json:"record"`
+	Patron              string            `json:"patron"`
+	Frozen              bool              `json:"frozen"`
+	Placed              string            `json:"placed"`
+	NotNeededAfterDate  string            `json:"notNeededAfterDate"`
+	PickupByDate        string            `json:"pickupByDate"`
+	Location            map[string]string `json:"location"`
+	PickupLocation      map[string]string `json:"pickupLocation"`
+	Status              map[string]string `json:"status"`
+	RecordType          string            `json:"recordType"` // b (bib), i (item), or j (volume)
+	Priority            int               `json:"priority"`
+	PriorityQueueLength int               `json:"priorityQueueLength"`
+}
+
+// HoldID returns the ID of the hold (the ID in the entry is a URL).
+func (e HoldEntry) HoldID() string {
+	return lastSegment(e.ID)
+}
+
+// RecordID returns the ID of the BIB, item, or volume on hold.
+func (e HoldEntry) RecordID() string {
+	return lastSegment(e.RecordURL)
+}
+
+// IsBorrowDirect returns true if the hold is for a Borrow Direct item
+// (see CheckoutEntry.IsBorrowDirect)
+func (e HoldEntry) IsBorrowDirect() bool {
+	return strings.Contains(e.RecordID(), "@")
+}
+
+// IsReady returns true if the item is ready for pickup.
+func (e HoldEntry) IsReady() bool {
+	code := e.Status["code"]
+	return code == "b" || code == "i" || code == "j"
+}
+
+// Holds returns the holds for the given patron ID.
+func (s *Sierra) Holds(patronID string) (Holds, error) {
+	url := s.URL + "/patrons/" + patronID + "/holds"
+	body, err := s.apiGet(url)
+	if err != nil {
+		return Holds{}, err
+	}
+
+	var holds Holds
+	err = json.Unmarshal([]byte(body), &holds)
+	return holds, err
+}
+
+// HoldRequest represents a request to place a hold via the Sierra API
+// /v5/patrons/{id}/holds/requests endpoint.
+type HoldRequest struct {
+	RecordType     string `json:"recordType"`   // b (bib) or i (item)
+	RecordNumber   int    `json:"recordNumber"` // without the "b" or "i" prefix
+	PickupLocation string `json:"pickupLocation"`
+	NeededBy       string `json:"neededBy,omitempty"` // yyyy-mm-dd
+	Note           string `json:"note,omitempty"`
+}
+
+// HoldUpdate represents the changes to a hold via the Sierra API
+// /v5/patrons/holds/{holdId} endpoint.
+type HoldUpdate struct {
+	PickupLocation string `json:"pickupLocation,omitempty"`
+}
+
+// PatronID returns the ID of the patron that placed the hold.
+func (e HoldEntry) PatronID() string {
+	return lastSegment(e.Patron)
+}
+
+// Hold returns the hold with the given ID.
+func (s *Sierra) Hold(holdID string) (HoldEntry, error) {
+	url := s.URL + "/patrons/holds/" + holdID
+	body, err := s.apiGet(url)
+	if err != nil {
+		return HoldEntry{}, err
+	}
+
+	var hold HoldEntry
+	err = json.Unmarshal([]byte(body), &hold)
+	return hold, err
+}
+
+// PlaceHold places a hold for the given patron. Errors from Sierra are
+// returned as a HoldError.
+func (s *Sierra) PlaceHold(patronID string, request HoldRequest) error {
+	url := s.URL + "/patrons/" + patronID + "/holds/requests"
+	_, err := s.apiSend("POST", url, request)
+	return newHoldError(err)
+}
+
+// CancelHold cancels the hold with the given ID. Errors from Sierra are
+// returned as a HoldError.
+func (s *Sierra) CancelHold(holdID string) error {
+	url := s.URL + "/patrons/holds/" + holdID
+	_, err := s.apiSend("DELETE", url, nil)
+	return newHoldError(err)
+}
+
+// UpdateHold changes the hold with the given ID (e.g. its pickup
+// location). Errors from Sierra are returned as a HoldError.
+func (s *Sierra) UpdateHold(holdID string, update HoldUpdate) error {
+	url := s.URL + "/patrons/holds/" + holdID
+	_, err := s.apiSend("PUT", url, update)
+	return newHoldError(err)
+}
+
+// lastSegment returns the last segment of a URL, e.g. "1234567" for
+// "https://.../v5/items/1234567"
+func lastSegment(url string) string {
+	tokens := strings.Split(url, "/")
+	return tokens[len(tokens)-1]
+}
@@ -0,0 +1,111 @@
+package sierra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHoldEntry(t *testing.T) {
+	hold := HoldEntry{
+		ID:        "https://sierra.example.edu/iii/sierra-api/v5/patrons/holds/123",
+		RecordURL: "https://sierra.example.edu/iii/sierra-api/v5/items/1000001",
+		Status:    map[string]string{"code": "i", "name": "item ready for pickup"},
+	}
+	if hold.HoldID() != "123" || hold.RecordID() != "1000001" || !hold.IsReady() || hold.IsBorrowDirect() {
+		t.Errorf("Unexpected hold values: %s %s %v", hold.HoldID(), hold.RecordID(), hold.IsReady())
+	}
+
+	hold = HoldEntry{RecordURL: "https://sierra.example.edu/v5/items/1000001@ncip", Status: map[string]string{"code": "0"}}
+	if !hold.IsBorrowDirect() || hold.IsReady() {
+		t.Errorf("Borrow Direct hold not detected")
+	}
+}
+
+func TestFineEntry(t *testing.T) {
+	fine := FineEntry{ItemCharge: 10, ProcessingFee: 5, BillingFee: 1, PaidAmount: 6.5}
+	if fine.Amount() != 9.5 || fine.ItemID() != "" || fine.IsBorrowDirect() {
+		t.Errorf("Unexpected fine values: %v %s", fine.Amount(), fine.ItemID())
+	}
+	fine.ItemURL = "https://sierra.example.edu/v5/items/1000001@ncip"
+	if !fine.IsBorrowDirect() {
+		t.Errorf("Borrow Direct fine not detected")
+	}
+}
+
+func TestPatronIsBlocked(t *testing.T) {
+	if (Patron{BlockInfo: map[string]string{"code": "-"}}).IsBlocked() || (Patron{}).IsBlocked() {
+		t.Errorf("Patron without block reported as blocked")
+	}
+	if !(Patron{BlockInfo: map[string]string{"code": "c"}}).IsBlocked() {
+		t.Errorf("Blocked patron not detected")
+	}
+}
+
+func TestNewHoldError(t *testing.T) {
+	duplicate := newAPIError(500, `{"code":132,"specificCode":2,"httpStatus":500,"name":"XCirc error","description":"XCirc error : Request denied - already on hold for or checked out to you."}`)
+	err := newHoldError(duplicate)
+	var holdErr HoldError
+	if !errors.As(err, &holdErr) || holdErr.Reason != HoldReasonDuplicate || holdErr.Message != "Request denied - already on hold for or checked out to you." {
+		t.Errorf("Unexpected error for duplicate hold: %#v", err)
+	}
+	if !errors.As(err, &APIError{}) {
+		t.Errorf("Sierra error not available via errors.As")
+	}
+
+	err = newHoldError(newAPIError(404, `{"code":107,"httpStatus":404,"name":"Record not found"}`))
+	if !errors.As(err, &holdErr) || holdErr.Reason != HoldReasonNotFound {
+		t.Errorf("Unexpected error for missing record: %#v", err)
+	}
+
+	// outages are not reported as rejected holds
+	outage := newAPIError(503, "Service Unavailable")
+	if err = newHoldError(outage); errors.As(err, &holdErr) {
+		t.Errorf("Outage reported as a rejected hold: %#v", err)
+	}
+	if newHoldError(nil) != nil {
+		t.Errorf("Error created for nil")
+	}
+}
+
+func TestPlaceHold(t *testing.T) {
+	var received HoldRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+			return
+		}
+		if r.Method != "POST" || r.URL.Path != "/patrons/111/holds/requests" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		if received.RecordNumber == 1000002 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"code":132,"httpStatus":500,"name":"XCirc error","description":"XCirc error : Request denied - you have reached the max number of holds"}`)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	s := NewSierra(server.URL, "key:secret", "")
+
+	request := HoldRequest{RecordType: "b", RecordNumber: 1000001, PickupLocation: "r"}
+	if err := s.PlaceHold("111", request); err != nil {
+		t.Fatal(err)
+	}
+	if received != request {
+		t.Errorf("Unexpected payload received: %+v", received)
+	}
+
+	request.RecordNumber = 1000002
+	err := s.PlaceHold("111", request)
+	var holdErr HoldError
+	if !errors.As(err, &holdErr) || holdErr.Reason != HoldReasonLimitReached {
+		t.Errorf("Unexpected error: %#v", err)
+	}
+}
@@ -3,19 +3,42 @@ package sierra
 import (
 	"bibService/pkg/marc"
 	"encoding/json"
+	"fmt"
+	"strconv"
 	"strings"
 )
 
 type Item struct {
-	Id          string            `json:"id"`
-	UpdatedDate string            `json:"updatedDate"`
-	CreatedDate string            `json:"createdDate"`
-	Deleted     bool              `json:"deleted"`
-	BibIds      []string          `json:"bibIds"`
-	Location    map[string]string `json:"location"`
-	Status      map[string]string `json:"status"`
-	Barcode     string            `json:"barcode"`
-	Fields      []marc.MarcField  `json:"varFields"`
+	Id          string                `json:"id"`
+	UpdatedDate string                `json:"updatedDate"`
+	CreatedDate string                `json:"createdDate"`
+	Deleted     bool                  `json:"deleted"`
+	BibIds      []string              `json:"bibIds"`
+	Location    map[string]string     `json:"location"`
+	Status      map[string]string     `json:"status"`
+	Barcode     string                `json:"barcode"`
+	Fields      []marc.MarcField      `json:"varFields"`
+	FixedFields map[string]FixedField `json:"fixedFields"`
+}
+
+// FixedField is a fixed-length field in a Sierra record. The value can be
+// a string or a number depending on the field.
+type FixedField struct {
+	Label   string      `json:"label"`
+	Value   interface{} `json:"value"`
+	Display string      `json:"display"`
+}
+
+// String returns the value of the field as a string.
+func (f FixedField) String() string {
+	switch value := f.Value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
 }
 
 // IsForBib returns true if the item belongs to the BIB ID passed.
@@ -32,7 +55,12 @@ func (i Item) BarcodeClean() string {
 	return strings.Replace(i.Barcode, " ", "", -1)
 }
 
+// LocationName returns the name of the item's location from the locations
+// table or, if the location is not in the table, the name given by Sierra.
 func (i Item) LocationName() string {
+	if location, ok := LookupLocation(i.Location["code"]); ok && location.Name != "" {
+		return location.Name
+	}
 	return i.Location["name"]
 }
 
@@ -49,6 +77,66 @@ func (i Item) StatusDisplay() string {
 	return i.Status["display"]
 }
 
+// StatusCode returns the Sierra status code of the item (e.g. "-" for
+// available or "m" for missing).
+func (i Item) StatusCode() string {
+	return i.Status["code"]
+}
+
+// IsAvailable returns true if the item is on the shelf, i.e. its status
+// is available and it is not checked out.
+func (i Item) IsAvailable() bool {
+	return i.StatusCode() == "-" && i.Status["duedate"] == ""
+}
+
+// Itype returns the item type code (fixed field 61) of the item.
+func (i Item) Itype() string {
+	return i.FixedFields["61"].String()
+}
+
+// LocationCode returns the Sierra location code of the item.
+func (i Item) LocationCode() string {
+	return i.Location["code"]
+}
+
+// CallNumber returns the call number of the item (from the "c" varField)
+// with the Sierra subfield delimiters removed, e.g. "PS3545.H16 Z5 1990"
+func (i Item) CallNumber() string {
+	for _, field := range i.Fields {
+		if field.FieldTag != "c" {
+			continue
+		}
+		if field.Content != "" {
+			return cleanCallNumber(field.Content)
+		}
+		values := field.Values([]string{"a", "b"}).Strings()
+		return strings.Join(strings.Fields(strings.Join(values, " ")), " ")
+	}
+	return ""
+}
+
+// Volume returns the volume of the item (from the "v" varField), e.g. "v.2"
+func (i Item) Volume() string {
+	for _, field := range i.Fields {
+		if field.FieldTag == "v" {
+			return strings.TrimSpace(field.String())
+		}
+	}
+	return ""
+}
+
+// cleanCallNumber removes the subfield delimiters from a call number
+// stored as a single value (e.g. "|aPS3545.H16 |bZ5 1990")
+func cleanCallNumber(value string) string {
+	tokens := strings.Split(value, "|")
+	for i, token := range tokens {
+		if i > 0 && len(token) > 0 {
+			tokens[i] = token[1:]
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(tokens, " ")), " ")
+}
+
 func (i Item) BookplateCodes() []string {
 	values := []string{}
 	for _, field := range i.Fields {
@@ -61,13 +149,8 @@ func (i Item) BookplateCodes() []string {
 
 // Item fetches information about an individual item by ID.
 func (s *Sierra) Item(itemID string) (Item, error) {
-	err := s.authenticate()
-	if err != nil {
-		return Item{}, err
-	}
-
-	url := s.URL + "/items/" + itemID
-	body, err := s.httpGet(url, s.Authorization.AccessToken)
+	url := s.URL + "/items/" + itemID + "?fields=default,varFields,fixedFields"
+	body, err := s.apiGet(url)
 	if err != nil {
 		return Item{}, err
 	}
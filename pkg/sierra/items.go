@@ -1,6 +1,17 @@
 package sierra
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Number of BIB IDs to request items for in a single call. Sierra chokes
+// when the list of IDs in the URL is too long.
+const itemsBatchSize = 40
+
+// Number of items to request per page.
+const itemsPageSize = 1000
 
 // Items represents a collection of Sierra items.
 type Items struct {
@@ -23,11 +34,6 @@ func (items Items) ForBib(bib string) []Item {
 
 // Items fetches item information for a comma delimited list of Bib IDs.
 func (s *Sierra) Items(bibsList string) (Items, error) {
-	err := s.authenticate()
-	if err != nil {
-		return Items{}, err
-	}
-
 	body, err := s.ItemsRaw(bibsList)
 	if err != nil {
 		return Items{}, err
@@ -38,14 +44,92 @@ func (s *Sierra) Items(bibsList string) (Items, error) {
 	return items, err
 }
 
-// ItemsRaw returns the raw item information (a string) for a comma delimited list of Bib IDs.
-func (s *Sierra) ItemsRaw(bibsList string) (string, error) {
-	err := s.authenticate()
+// ItemsForBibs fetches the items for the given Bib IDs. Items are requested
+// in batches (rather than one request per BIB) and the result includes the
+// items for all the BIBs.
+//
+// If Sierra rejects a batch (e.g. because one of the BIBs was deleted) the
+// batch is split in half and each half is retried so that a single bad ID
+// does not prevent us from getting the items for the rest of the BIBs.
+func (s *Sierra) ItemsForBibs(bibIds []string) (Items, error) {
+	items := Items{}
+	for start := 0; start < len(bibIds); start += itemsBatchSize {
+		end := start + itemsBatchSize
+		if end > len(bibIds) {
+			end = len(bibIds)
+		}
+		batch, err := s.itemsForBatch(bibIds[start:end])
+		if err != nil {
+			return Items{}, err
+		}
+		items.Total += batch.Total
+		items.Entries = append(items.Entries, batch.Entries...)
+	}
+	return items, nil
+}
+
+func (s *Sierra) itemsForBatch(bibIds []string) (Items, error) {
+	items, err := s.itemsPaginated(strings.Join(bibIds, ","))
+	if err == nil || IsNotFound(err) {
+		// Sierra returns "404 not found" when none of the BIBs have items.
+		return items, nil
+	}
+
+	if IsRetryable(err) {
+		// Splitting the batch won't help if Sierra is having problems.
+		return Items{}, err
+	}
+
+	if len(bibIds) == 1 {
+		errorMsg := fmt.Sprintf("Error fetching items for %s", bibIds[0])
+		s.log(errorMsg, err.Error())
+		return Items{}, nil
+	}
+
+	half := len(bibIds) / 2
+	items1, err := s.itemsForBatch(bibIds[:half])
 	if err != nil {
-		return "", err
+		return Items{}, err
 	}
+	items2, err := s.itemsForBatch(bibIds[half:])
+	if err != nil {
+		return Items{}, err
+	}
+	items1.Total += items2.Total
+	items1.Entries = append(items1.Entries, items2.Entries...)
+	return items1, nil
+}
 
+func (s *Sierra) itemsPaginated(bibsList string) (Items, error) {
+	items := Items{}
+	offset := 0
+	for {
+		url := s.URL + "/items?bibIds=" + bibsList
+		url += fmt.Sprintf("&limit=%d&offset=%d", itemsPageSize, offset)
+		url += "&fields=default,varFields,fixedFields"
+		body, err := s.apiGet(url)
+		if err != nil {
+			return items, err
+		}
+
+		var page Items
+		err = json.Unmarshal([]byte(body), &page)
+		if err != nil {
+			return Items{}, err
+		}
+		items.Total += len(page.Entries)
+		items.Entries = append(items.Entries, page.Entries...)
+		if len(page.Entries) < itemsPageSize {
+			break
+		}
+		offset += len(page.Entries)
+	}
+	return items, nil
+}
+
+// ItemsRaw returns the raw item information (a string) for a comma delimited list of Bib IDs.
+func (s *Sierra) ItemsRaw(bibsList string) (string, error) {
 	url := s.URL + "/items?bibIds=" + bibsList
 	url += "&fields=default,varFields,fixedFields"
-	return s.httpGet(url, s.Authorization.AccessToken)
+	return s.apiGet(url)
 }
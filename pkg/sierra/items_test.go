@@ -0,0 +1,68 @@
+package sierra
+
+import (
+	"bibService/pkg/marc"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestItemsForBibsSplitsBadBatch(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+			return
+		}
+		calls++
+		bibIds := strings.Split(r.URL.Query().Get("bibIds"), ",")
+		for _, id := range bibIds {
+			if id == "bad" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"code":108,"httpStatus":400,"name":"Invalid parameter"}`)
+				return
+			}
+		}
+		entries := []string{}
+		for _, id := range bibIds {
+			entries = append(entries, fmt.Sprintf(`{"id":"i%s","bibIds":["%s"]}`, id, id))
+		}
+		fmt.Fprintf(w, `{"total":%d,"entries":[%s]}`, len(entries), strings.Join(entries, ","))
+	}))
+	defer server.Close()
+
+	s := NewSierra(server.URL, "key:secret", "")
+	s.Retry = RetryPolicy{MaxRetries: 0, InitialDelay: time.Millisecond}
+	items, err := s.ItemsForBibs([]string{"1", "2", "bad", "4"})
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if len(items.Entries) != 3 || len(items.ForBib("4")) != 1 || len(items.ForBib("bad")) != 0 {
+		t.Errorf("Unexpected items: %#v", items)
+	}
+	// [1,2,bad,4] -> [1,2] + [bad,4] -> [bad] + [4]
+	if calls != 5 {
+		t.Errorf("Unexpected number of calls: %d", calls)
+	}
+}
+
+func TestItemCallNumber(t *testing.T) {
+	item := Item{Fields: []marc.MarcField{
+		{FieldTag: "c", Content: "|aPS3545.H16 |bZ5  1990"},
+		{FieldTag: "v", Content: "v.2"},
+	}}
+	if item.CallNumber() != "PS3545.H16 Z5 1990" {
+		t.Errorf("Unexpected call number: %s", item.CallNumber())
+	}
+	if item.Volume() != "v.2" {
+		t.Errorf("Unexpected volume: %s", item.Volume())
+	}
+
+	item = Item{}
+	if item.CallNumber() != "" || item.Volume() != "" {
+		t.Errorf("Unexpected call number for item without varFields: %s %s", item.CallNumber(), item.Volume())
+	}
+}
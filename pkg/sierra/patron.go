@@ -0,0 +1,35 @@
+package sierra
+
+import (
+	"encoding/json"
+)
+
+// Patron represents the result from the Sierra API /v5/patrons/{id} endpoint
+// (only the fields that we use).
+type Patron struct {
+	ID             int               `json:"id"`
+	ExpirationDate string            `json:"expirationDate"`
+	PatronType     int               `json:"patronType"`
+	HomeLibrary    string            `json:"homeLibraryCode"`
+	MoneyOwed      float64           `json:"moneyOwed"`
+	BlockInfo      map[string]string `json:"blockInfo"` // code ("-" when not blocked) and until
+}
+
+// IsBlocked returns true if the patron has a manual block.
+func (p Patron) IsBlocked() bool {
+	code := p.BlockInfo["code"]
+	return code != "" && code != "-"
+}
+
+// Patron returns the patron record for the given patron ID.
+func (s *Sierra) Patron(patronID string) (Patron, error) {
+	url := s.URL + "/patrons/" + patronID
+	body, err := s.apiGet(url)
+	if err != nil {
+		return Patron{}, err
+	}
+
+	var patron Patron
+	err = json.Unmarshal([]byte(body), &patron)
+	return patron, err
+}
@@ -1,11 +1,13 @@
 package sierra
 
 import (
+	"bibService/pkg/metrics"
 	"database/sql"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -42,7 +44,7 @@ func (row PullSlipRow) String() string {
 }
 
 func PullSlipsForList(connString string, listID int) ([]PullSlipRow, error) {
-	log.Printf("Connecting to DB: %s", connString)
+	log.Printf("Connecting to Sierra DB")
 	// https://godoc.org/github.com/lib/pq
 	db, err := sql.Open("postgres", connString)
 	if err != nil {
@@ -170,8 +172,10 @@ func PullSlipsForList(connString string, listID int) ([]PullSlipRow, error) {
 	sqlSelect = strings.ReplaceAll(sqlSelect, "{listID}", strconv.Itoa(listID))
 	log.Printf("Running query: \r\n%s\r\n", sqlSelect)
 
+	started := time.Now()
 	rows, err := db.Query(sqlSelect)
 	if err != nil {
+		metrics.ObserveSQL("pullSlips", started, err)
 		return []PullSlipRow{}, err
 	}
 	defer rows.Close()
@@ -181,6 +185,7 @@ func PullSlipsForList(connString string, listID int) ([]PullSlipRow, error) {
 	for rows.Next() {
 		row, err := scanPullSlipRow(rows)
 		if err != nil {
+			metrics.ObserveSQL("pullSlips", started, err)
 			return []PullSlipRow{}, err
 		}
 		values = append(values, row)
@@ -190,6 +195,7 @@ func PullSlipsForList(connString string, listID int) ([]PullSlipRow, error) {
 			log.Printf("Fetched %d rows...", count)
 		}
 	}
+	metrics.ObserveSQL("pullSlips", started, nil)
 	log.Printf("Found %d rows\r\n", len(values))
 	return values, nil
 }
@@ -0,0 +1,106 @@
+package sierra
+
+import (
+	"bibService/pkg/marc"
+	"strings"
+)
+
+// TocEntry represents a chapter in the table of contents of a record.
+type TocEntry struct {
+	Label   string   `json:"label,omitempty"`
+	Indent  string   `json:"indent,omitempty"`
+	Title   string   `json:"title"`
+	Authors []string `json:"authors,omitempty"`
+	Page    string   `json:"page,omitempty"`
+}
+
+// tocFrom505 returns the chapters in a MARC 505 (formatted contents note).
+//
+// Basic 505 fields have all the chapters in subfield "a" separated by " -- "
+// whereas enhanced 505 fields have one subfield "t" per chapter title with
+// its authors in subfield "r" and miscellaneous information (typically the
+// chapter number or the pages) in subfield "g".
+func tocFrom505(field marc.MarcField) []TocEntry {
+	entries := []TocEntry{}
+	var entry *TocEntry
+	for _, sub := range field.Subfields {
+		value := cleanTocValue(sub["content"])
+		switch sub["tag"] {
+		case "a":
+			for _, title := range strings.Split(sub["content"], "--") {
+				title = cleanTocValue(title)
+				if title != "" {
+					entries = append(entries, TocEntry{Title: title})
+				}
+			}
+			entry = nil
+		case "t":
+			if entry != nil && entry.Title == "" {
+				// we already started this chapter (via its "g" value)
+				entry.Title = value
+			} else {
+				entries = append(entries, TocEntry{Title: value})
+				entry = &entries[len(entries)-1]
+			}
+		case "r":
+			if entry != nil {
+				safeAppend(&entry.Authors, value)
+			}
+		case "g":
+			if entry != nil && entry.Title != "" {
+				// "g" after the title is usually the page number
+				entry.Page = value
+			} else {
+				// "g" before the title is usually the chapter number
+				entries = append(entries, TocEntry{Label: value})
+				entry = &entries[len(entries)-1]
+			}
+		}
+	}
+
+	// Drop entries that only had a label and never got a title
+	tocs := []TocEntry{}
+	for _, entry := range entries {
+		if entry.Title != "" {
+			tocs = append(tocs, entry)
+		}
+	}
+	return tocs
+}
+
+// tocFrom970 returns the chapter in a MARC 970 (our local table of contents
+// field). Each 970 represents one chapter:
+//
+//	l - label (e.g. chapter number)
+//	t - title
+//	c, d, e - authors
+//	p - page number
+//
+// The first indicator is used as the indentation level.
+func tocFrom970(field marc.MarcField) (TocEntry, bool) {
+	entry := TocEntry{Indent: strings.TrimSpace(field.Ind1)}
+	for _, sub := range field.Subfields {
+		value := cleanTocValue(sub["content"])
+		switch sub["tag"] {
+		case "l":
+			entry.Label = value
+		case "t":
+			if entry.Title == "" {
+				entry.Title = value
+			} else {
+				entry.Title += " " + value
+			}
+		case "c", "d", "e":
+			safeAppend(&entry.Authors, value)
+		case "p":
+			entry.Page = value
+		}
+	}
+	return entry, entry.Title != ""
+}
+
+func cleanTocValue(value string) string {
+	value = strings.TrimSpace(value)
+	value = strings.TrimSuffix(value, "--")
+	return strings.TrimSpace(marc.TrimPunct(strings.TrimSpace(value)))
+}
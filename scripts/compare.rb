@@ -70,10 +70,7 @@ def compare_one(id, json1, json2, show_equal, sequence)
     "new_uniform_title_author_display", # minor punctuation differences (including encoding of ampersand)
     "uniform_title_author_display", # minor punctuation differences (including encoding of ampersand)
     "uniform_related_works_display", # minor punctuation differences (including encoding of ampersand)
-    "text",             # pending
-    "toc_display",      # pending
-    "toc_970_display",  # pending
-    "marc_display"      # pending
+    "text"              # pending
   ]
 
   id_shown = false